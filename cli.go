@@ -2,27 +2,71 @@ package main
 
 import (
 	"bytes"
+	"cmp"
 	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"math"
+	mathrand "math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"gopkg.in/yaml.v3"
 )
 
 type contextKey string
 
+// defaultOversample is how many times max-items are fetched to sort before trimming.
+const defaultOversample = 5
+
+// defaultRelaxStep is how much --min-results lowers the --average minimum per query.
+const defaultRelaxStep = 0.5
+
 const dependencies contextKey = "deps"
 
 // Dependencies provides shared services for CLI commands to access TMDB API.
 type Dependencies struct {
 	URLBuilder *urlBuilder
 	Client     *httpClient
+	ConfigDir  string
 }
 
 // newRootCmd creates the root command to organize all subcommands and CLI setup.
 func newRootCmd(fileName string) *cobra.Command {
+	var (
+		cfgDir         string
+		apiKeyFile     string
+		networkRetries int
+		retryBudget    int
+		fetchTimeout   time.Duration
+		connectTimeout time.Duration
+		totalTimeout   time.Duration
+		noRetry        bool
+		verbose        bool
+		locale         string
+		batchSize      int
+		batchDelay     time.Duration
+		apiVersion     int
+		header         bool
+	)
 	rootCmd := &cobra.Command{
 		Use:   "go-tmdb-cli",
 		Args:  cobra.NoArgs,
@@ -30,38 +74,238 @@ func newRootCmd(fileName string) *cobra.Command {
 		Long: `A simple command-line interface (CLI) to fetch data from The
 Movie Database (TMDB), and display it in the terminal.`,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-			err := initialize(&defaultUserHome{}, fileName)
+			dir, err := configDir(&defaultUserHome{}, cfgDir)
+			if err != nil {
+				return err
+			}
+			if err := initialize(&defaultUserHome{}, dir, fileName); err != nil {
+				return err
+			}
+			apiKey, err := resolveAPIKey(apiKeyFile)
 			if err != nil {
 				return err
 			}
-			apiKey := viper.GetString("api_key")
 			if apiKey == "" {
-				return fmt.Errorf(`missing API key in ~/.go-tmdb-cli/%s,
+				return fmt.Errorf(`missing API key in %s,
 please ensure you include your API key in the following format:
-  api_key: YOUR_API_KEY`, fileName)
+  api_key: YOUR_API_KEY
+or point to a file holding it:
+  api_key_file: /run/secrets/tmdb`, filepath.Join(dir, fileName))
+			}
+			if tz := viper.GetString("timezone"); tz != "" {
+				loc, err := time.LoadLocation(tz)
+				if err != nil {
+					return fmt.Errorf("validation error: timezone must be an IANA name like \"UTC\" or \"Europe/Paris\": %w", err)
+				}
+				yearLocation = loc
+			}
+			if err := setOutputEncoding(cmd); err != nil {
+				return err
+			}
+			if networkRetries < 0 {
+				return fmt.Errorf("validation error: network retries must be ≥ 0")
+			}
+			client := newHTTPClient(apiKey)
+			client.NetworkRetries = networkRetries
+			client.NoRetry = noRetry
+			if batchSize < 0 || batchDelay < 0 {
+				return fmt.Errorf("validation error: batch size and batch delay must be ≥ 0")
+			}
+			client.BatchSize = batchSize
+			client.BatchDelay = batchDelay
+			if fetchTimeout < 0 {
+				return fmt.Errorf("validation error: fetch timeout must be ≥ 0")
+			}
+			client.RetryBudget = retryBudget
+			client.FetchTimeout = fetchTimeout
+			if connectTimeout < 0 || totalTimeout < 0 {
+				return fmt.Errorf("validation error: connect and total timeouts must be ≥ 0")
+			}
+			client.Client.Transport = newTransport(connectTimeout)
+			client.TotalTimeout = totalTimeout
+			if locale != "" {
+				tag, err := language.Parse(locale)
+				if err != nil {
+					return fmt.Errorf(`validation error: locale must be a BCP 47 tag like "fr" or "pt-BR": %w`, err)
+				}
+				client.Locale = tag.String()
+			}
+			if verbose {
+				client.Logger = log.New(cmd.ErrOrStderr(), "", log.LstdFlags)
+			}
+			ub := newURLBuilder()
+			if err := ub.setVersion(apiVersion); err != nil {
+				return err
+			}
+			if !supportsAPIVersion(cmd, apiVersion) {
+				return fmt.Errorf("validation error: %q only supports --api-version=%d", cmd.CommandPath(),
+					defaultAPIVersion)
 			}
 			deps := &Dependencies{
-				URLBuilder: newURLBuilder(),
-				Client:     newHTTPClient(apiKey),
+				URLBuilder: ub,
+				Client:     client,
+				ConfigDir:  dir,
 			}
 			ctx := context.WithValue(cmd.Context(), dependencies, deps)
 			cmd.SetContext(ctx)
 			return nil
 		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			printStats, _ := cmd.Flags().GetBool("print-stats")
+			if deps, err := getDependencies(cmd); printStats && err == nil {
+				cmd.PrintErrln(deps.Client.Stats)
+			}
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			_ = cmd.Help()
 		},
 	}
+	rootCmd.PersistentFlags().StringVar(&cfgDir, "config-dir", "",
+		"directory holding the configuration file (default ~/.go-tmdb-cli)")
+	rootCmd.PersistentFlags().StringVar(&apiKeyFile, "api-key-file", "",
+		"file holding the API key, overrides api_key_file and api_key in the configuration file")
+	rootCmd.PersistentFlags().IntVar(&networkRetries, "retries-on-network", defaultNetworkRetries,
+		"retries on transient network failures, apart from API rate limit retries")
+	rootCmd.PersistentFlags().IntVar(&retryBudget, "retry-budget", defaultRetryBudget,
+		"retries shared by all the pages of a fetch, -1 for no limit")
+	rootCmd.PersistentFlags().DurationVar(&fetchTimeout, "fetch-timeout", 0,
+		"give up on a whole multi-page fetch after this duration, e.g. 1m, 0 for no limit")
+	rootCmd.PersistentFlags().DurationVar(&connectTimeout, "connect-timeout", defaultConnectTimeout,
+		"give up connecting to TMDB after this duration, 0 for no limit")
+	rootCmd.PersistentFlags().DurationVar(&totalTimeout, "total-timeout", defaultTotalTimeout,
+		"give up on each request, from connecting to reading the response, after this duration, 0 for no limit")
+	rootCmd.PersistentFlags().String("output-encoding", "utf-8",
+		"character encoding of the output for legacy terminals, e.g. cp1252")
+	rootCmd.PersistentFlags().BoolVar(&noRetry, "no-retry", false, "fail on the first error instead of retrying")
+	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "log HTTP requests to stderr")
+	rootCmd.PersistentFlags().IntVar(&batchSize, "batch-size", 0,
+		"pages fetched in parallel per wave, 0 for all at once")
+	rootCmd.PersistentFlags().DurationVar(&batchDelay, "batch-delay", 0, "pause between waves of pages, e.g. 500ms")
+	rootCmd.PersistentFlags().IntVar(&apiVersion, "api-version", defaultAPIVersion,
+		"version of the TMDB API, 3 or 4 for the commands supporting it, e.g. watchlist list")
+	rootCmd.PersistentFlags().StringVar(&locale, "locale", "",
+		`preferred language of the responses, sent as Accept-Language, e.g. "fr-FR"`)
+	rootCmd.PersistentFlags().Bool("thousands-sep", false,
+		`group the digits of the table numbers by --locale, e.g. "1,000" by default or "1.000" for "de"`)
+	rootCmd.PersistentFlags().Bool("json-compact", false, "minify the JSON output")
+	rootCmd.PersistentFlags().String("output-sort-key", "id",
+		`order the JSON, YAML and CSV records by this field, ascending, for diffs; empty to follow --sort`)
+	rootCmd.PersistentFlags().String("theme", "default",
+		fmt.Sprintf("style of the table borders and lines, one of: %v", themeNames))
+	rootCmd.PersistentFlags().Bool("ascii", false,
+		"draw the tables with ASCII characters only, by default when the locale names a charset other than UTF-8")
+	rootCmd.PersistentFlags().BoolVar(&header, "header", true, "print the header row of the table and CSV outputs")
+	rootCmd.PersistentFlags().Var(negatedBool{&header}, "no-header",
+		"omit the header row of the table and CSV outputs, as --header=false")
+	rootCmd.PersistentFlags().Lookup("no-header").NoOptDefVal = "true"
+	rootCmd.PersistentFlags().String("fields", "",
+		"comma-separated fields of the table, CSV, JSON and YAML outputs, e.g. title,average")
+	rootCmd.PersistentFlags().Bool("urls-only", false, "print only one URL per movie, e.g. for a download script")
+	rootCmd.PersistentFlags().String("url-kind", "tmdb-page",
+		fmt.Sprintf("URL printed by --urls-only, one of: %v", urlKinds))
+	rootCmd.PersistentFlags().Bool("print-stats", false, "print a summary of the API usage to stderr")
+	rootCmd.PersistentFlags().Bool("quiet", false, "suppress the hints printed to stderr")
+	rootCmd.PersistentFlags().Bool("json-errors", false,
+		`print errors to stderr as JSON, e.g. {"field":"year","message":"..."}, for form integrations`)
+	rootCmd.SetFlagErrorFunc(gluedFlagHint)
 	rootCmd.SetHelpCommand(&cobra.Command{Hidden: true})
 	rootCmd.AddCommand(
 		completionCommand(),
 		newListCmd(),
 		newDiscoverCmd(),
 		newInfoCmd(),
+		newMergeCmd(),
+		newCollectionCmd(),
+		newFindCmd(),
+		newCompareCmd(),
+		newWatchlistCmd(),
+		newRateCmd(),
+		newLanguagesCmd(),
+		newDoctorCmd(fileName),
 	)
 	return rootCmd
 }
 
+// negatedBool is a bool flag setting the opposite of another one, e.g. --no-header
+// for --header, so that the last of the two given wins.
+type negatedBool struct{ target *bool }
+
+func (n negatedBool) Set(value string) error {
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	*n.target = !v
+	return nil
+}
+
+func (n negatedBool) String() string {
+	if n.target == nil {
+		return "false"
+	}
+	return strconv.FormatBool(!*n.target)
+}
+
+func (n negatedBool) Type() string {
+	return "bool"
+}
+
+// execute runs the root command and prints its error, if any, as cobra does, or as a
+// {"field": ..., "message": ...} JSON object with --json-errors, the field naming the
+// flag of a discover filter failing validation.
+func execute(root *cobra.Command) error {
+	root.SilenceErrors = true
+	root.SilenceUsage = true
+	cmd, err := root.ExecuteC()
+	if err == nil {
+		return nil
+	}
+	if quiet, _ := root.PersistentFlags().GetBool("quiet"); quiet && errors.Is(err, errNoResults) {
+		return err
+	}
+	if jsonErrors, _ := root.PersistentFlags().GetBool("json-errors"); jsonErrors {
+		validationErr := &ValidationError{Message: err.Error()}
+		errors.As(err, &validationErr)
+		byt, _ := json.Marshal(validationErr)
+		root.PrintErrln(string(byt))
+		return err
+	}
+	root.PrintErrln(root.ErrPrefix(), err.Error())
+	if !errors.Is(err, errNoResults) {
+		root.Println(cmd.UsageString())
+	}
+	return err
+}
+
+// quietEmpty reports whether the empty-result message of m goes unprinted, --quiet
+// leaving the report of an empty result set to the exit code of --fail-on-empty.
+func quietEmpty(cmd *cobra.Command, m movies) bool {
+	failOnEmpty, _ := cmd.Flags().GetBool("fail-on-empty")
+	quiet, _ := cmd.Flags().GetBool("quiet")
+	return len(m) == 0 && failOnEmpty && quiet
+}
+
+// errNoResults fails the commands run with --fail-on-empty that find no movie, e.g.
+// for CI checks asserting a query returns something.
+var errNoResults = errors.New("no results found")
+
+const (
+	exitError     = 1
+	exitNoResults = 2
+)
+
+// exitCode maps the error returned by execute to the exit code of the CLI, telling
+// an empty result set of --fail-on-empty from the other errors.
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errNoResults):
+		return exitNoResults
+	}
+	return exitError
+}
+
 // newInfoCmd defines the command to show CLI version and authorship details.
 func newInfoCmd() *cobra.Command {
 	versionCmd := &cobra.Command{
@@ -80,73 +324,196 @@ func newInfoCmd() *cobra.Command {
 
 // newListCmd creates the command to display pre-defined movie categories.
 func newListCmd() *cobra.Command {
-	var isNowPlaying, isPopular, isTopRated, isUpcoming bool
+	var alsoDiscover bool
+	var format, maxItems string
+	categories := []struct {
+		name, alias, help string
+		category, heading string
+		enabled           bool
+	}{
+		{name: "now", alias: "n", help: "now playing movies", category: "now_playing", heading: "Now Playing"},
+		{name: "pop", alias: "p", help: "popular movies", category: "popular", heading: "Popular"},
+		{name: "top", alias: "t", help: "top rated movies", category: "top_rated", heading: "Top Rated"},
+		{name: "up", alias: "u", help: "upcoming movies", category: "upcoming", heading: "Upcoming"},
+	}
 	movieListCmd := &cobra.Command{
 		Use:   "list",
 		Short: "Display a ready-made movie list",
 		Long: `Retrieve and display a curated list of movies from The Movie 
 Database (TMDB), including categories such as now playing, popular, top rated, 
-and upcoming, formatted as a user-friendly table.`,
+and upcoming, formatted as a user-friendly table. Several categories are shown 
+one after the other, each under its heading.`,
 		Example: `  go-tmdb-cli list -n
   go-tmdb-cli list -p
   go-tmdb-cli list -t
-  go-tmdb-cli list -u`,
+  go-tmdb-cli list -u
+  go-tmdb-cli list -p -m=60
+  go-tmdb-cli list -n -t
+  go-tmdb-cli list -t --also-discover -g=horror -y=2000,gte`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if cmd.Flags().NFlag() == 0 {
 				_ = cmd.Help()
 				return nil
 			}
+			format = configDefault(cmd, "format", "default_format")
+			maxItems = configDefault(cmd, "max-items", "default_max_items")
+			if err := validateFormat(format); err != nil {
+				return err
+			}
+			opts, err := newOutputOptions(cmd)
+			if err != nil {
+				return err
+			}
 			deps, err := getDependencies(cmd)
 			if err != nil {
 				return err
 			}
-			var url string
-			switch {
-			case isNowPlaying:
-				url, _ = deps.URLBuilder.list("now_playing")
-			case isPopular:
-				url, _ = deps.URLBuilder.list("popular")
-			case isTopRated:
-				url, _ = deps.URLBuilder.list("top_rated")
-			case isUpcoming:
-				url, _ = deps.URLBuilder.list("upcoming")
+			if !alsoDiscover {
+				for _, flag := range filterFlags {
+					if cmd.Flags().Changed(flag.name) {
+						return fmt.Errorf("validation error: --%s requires --also-discover", flag.name)
+					}
+				}
+			}
+			var urls, headings, sources []string
+			for _, c := range categories {
+				if !c.enabled {
+					continue
+				}
+				url, err := deps.URLBuilder.list(c.category)
+				if alsoDiscover {
+					var q queryParams
+					if q, err = readFilterFlags(cmd); err != nil {
+						return err
+					}
+					if q.SortBy, err = listSortBy(c.category); err != nil {
+						return err
+					}
+					url, err = deps.URLBuilder.discover(q)
+				}
+				if err != nil {
+					return err
+				}
+				urls = append(urls, url)
+				headings = append(headings, c.heading)
+				sources = append(sources, c.category)
 			}
-			tmdbRes, err := asyncFetchMovies(deps.Client, url, 20)
+			if len(urls) == 0 {
+				return fmt.Errorf("validation error: select a list with --now, --pop, --top or --up")
+			}
+			if len(urls) > 1 && format != "table" && !opts.SourceColumn {
+				return fmt.Errorf("validation error: several lists can only be shown as tables, " +
+					"or merged with --source-column")
+			}
+			wantItems, err := parseMaxItems(maxItems)
 			if err != nil {
 				return err
 			}
-			got := formatResults(tmdbRes)
-			cmd.Println(got)
+			if err := setMaxPages(cmd, deps.Client); err != nil {
+				return err
+			}
+			hasPoster, _ := cmd.Flags().GetBool("has-poster")
+			onlyReleased, _ := cmd.Flags().GetBool("only-released")
+			releasedBy := today()
+			var merged movies
+			shown := 0
+			for i, url := range urls {
+				tmdbRes, total, err := asyncFetchMovies(cmd.Context(), deps.Client, url, wantItems, func(m movie) bool {
+					return (!hasPoster || m.hasPoster()) && (!onlyReleased || m.isReleased(releasedBy))
+				})
+				interrupted := errors.Is(err, errInterrupted)
+				if err != nil && !interrupted {
+					return err
+				}
+				if opts.Enriched {
+					if err := enrichMovies(cmd.Context(), deps.Client, deps.URLBuilder, tmdbRes); err != nil {
+						return err
+					}
+				}
+				if opts.SourceColumn {
+					merged = append(merged, tmdbRes.withSource(sources[i])...)
+					if interrupted {
+						cmd.PrintErrln(errInterrupted)
+						break
+					}
+					continue
+				}
+				got, err := formatMovies(tmdbRes, format, opts)
+				if err != nil {
+					return err
+				}
+				shown += len(tmdbRes)
+				if !quietEmpty(cmd, tmdbRes) {
+					if len(urls) > 1 {
+						cmd.Println(headings[i])
+					}
+					cmd.Println(got)
+				}
+				if interrupted {
+					cmd.PrintErrln(errInterrupted)
+					return nil
+				}
+				if len(urls) == 1 {
+					printMoreResultsHint(cmd, len(tmdbRes), total, wantItems)
+				}
+			}
+			if opts.SourceColumn {
+				merged = merged.deduplicate()
+				got, err := formatMovies(merged, format, opts)
+				if err != nil {
+					return err
+				}
+				if !quietEmpty(cmd, merged) {
+					cmd.Println(got)
+				}
+				shown = len(merged)
+			}
+			if failOnEmpty, _ := cmd.Flags().GetBool("fail-on-empty"); failOnEmpty && shown == 0 {
+				return errNoResults
+			}
 			return nil
 		},
 	}
-	flags := map[string]struct {
-		alias   string
-		help    string
-		enabled *bool
-	}{
-		"now": {"n", "now playing movies", &isNowPlaying},
-		"pop": {"p", "popular movies", &isPopular},
-		"top": {"t", "top rated movies", &isTopRated},
-		"up":  {"u", "upcoming movies", &isUpcoming},
-	}
-	for name, flag := range flags {
-		movieListCmd.Flags().BoolVarP(flag.enabled, name, flag.alias, false, flag.help)
+	for i, c := range categories {
+		movieListCmd.Flags().BoolVarP(&categories[i].enabled, c.name, c.alias, false, c.help)
 	}
+	movieListCmd.Flags().StringVar(&format, "format", "table", fmt.Sprintf("output format, one of: %v", outputFormats))
+	movieListCmd.Flags().StringVarP(&maxItems, "max-items", "m", "",
+		fmt.Sprintf("maximum number of movies, default 20, max %d", APIMaxItems))
+	movieListCmd.Flags().Int("max-pages", 0, "maximum number of API pages fetched, 0 for no limit")
+	movieListCmd.Flags().Bool("only-released", false, "drop movies released after today or without a release date")
+	movieListCmd.Flags().Bool("has-poster", false, "drop movies without a poster")
+	movieListCmd.Flags().Bool("fail-on-empty", false, "exit with code 2 when no movie is found, e.g. for CI checks")
+	movieListCmd.Flags().Bool("show-genres", false, "add a genres column to the table")
+	movieListCmd.Flags().Bool("enrich", false,
+		"fetch each movie's details for its runtime, overview and genres, one request per movie")
+	movieListCmd.Flags().Bool("source-column", false,
+		"merge the lists into one deduplicated list with a source column naming their lists")
+	movieListCmd.Flags().BoolVar(&alsoDiscover, "also-discover", false,
+		"refine the popular or top rated list with discover filters")
+	addFilterFlags(movieListCmd)
 	return movieListCmd
 }
 
 // newDiscoverCmd builds the command for advanced movie searches with filters.
 func newDiscoverCmd() *cobra.Command {
 	discoverCmd := &cobra.Command{
-		Use:   "discover",
+		Use:   "discover [genre...] [year]",
 		Short: "Discover movies based on various criteria",
 		Long: `Discover enables users to explore a diverse selection of films 
 that align with their interests and preferences, for more refined searches.`,
 		Example: `  go-tmdb-cli discover  -l=en  -y=2000,2005  -g=comedy,action  -a=6.5,10   -v=100,50000  -m=100  -s=average,desc
-  go-tmdb-cli discover  -l=fr  -y=1960,gte   -g=history        -a=7,gte    -v100,gte     -m=50   -s=title,asc
+  go-tmdb-cli discover  -l=fr  -y=1960,gte   -g=history        -a=7,gte    -v=100,gte    -m=50   -s=title,asc
   go-tmdb-cli discover  -l=pt  -y=1960,lte   -w=comedy         -a=9.0,lte  -v=2000,lte   -m=10   -s=votes,asc
+  go-tmdb-cli discover  drama action 2020
 		`,
+		Args: cobra.ArbitraryArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := gluedShorthandHint(cmd); err != nil {
+				return err
+			}
+			return inferPositionals(cmd, args)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if cmd.Flags().NFlag() == 0 {
 				_ = cmd.Help()
@@ -156,118 +523,1942 @@ that align with their interests and preferences, for more refined searches.`,
 			if err != nil {
 				return err
 			}
-			var url, sort, maxItems string
-			q := queryParams{}
+			var url, excludeIDs, excludeIDsFile string
 			flags := map[string]*string{
-				"language":       &q.Language,
-				"year":           &q.Year,
-				"average":        &q.VoteAverage,
-				"votes":          &q.VoteCount,
-				"genres":         &q.WithGenres,
-				"without-genres": &q.WithoutGenres,
-				"sort":           &sort,
-				"max-items":      &maxItems,
+				"exclude-ids":      &excludeIDs,
+				"exclude-ids-file": &excludeIDsFile,
 			}
 			for name, value := range flags {
 				if flagValue, _ := cmd.Flags().GetString(name); flagValue != "" {
 					*value = flagValue
 				}
 			}
+			format := configDefault(cmd, "format", "default_format")
+			maxItems := configDefault(cmd, "max-items", "default_max_items")
+			sort := configDefault(cmd, "sort", "default_sort")
+			if err := validateFormat(format); err != nil {
+				return err
+			}
+			opts, err := newOutputOptions(cmd)
+			if err != nil {
+				return err
+			}
+			withMeta, _ := cmd.Flags().GetBool("with-meta")
+			if withMeta && format != "json" {
+				return fmt.Errorf("validation error: --with-meta requires --format=json")
+			}
+			if err := checkDateFlags(cmd); err != nil {
+				return err
+			}
+			q, err := readFilterFlags(cmd)
+			if err != nil {
+				return err
+			}
+			var runKey string
+			if sinceLastRun, _ := cmd.Flags().GetBool("since-last-run"); sinceLastRun {
+				if url, err = deps.URLBuilder.discover(q); err != nil {
+					return err
+				}
+				runKey = queryKey(url)
+				last, ok, err := readLastRun(deps.ConfigDir, runKey)
+				if err != nil {
+					return err
+				}
+				if ok {
+					q.ReleasedSince = sinceBoundary(last)
+				}
+			}
+			if onlyReleased, _ := cmd.Flags().GetBool("only-released"); onlyReleased {
+				q.ReleasedBefore = today()
+			}
+			if serverSort, _ := cmd.Flags().GetBool("server-sort"); serverSort && sort != "" {
+				sortBy, ok, err := serverSortBy(sort)
+				if err != nil {
+					return err
+				}
+				if ok {
+					q.SortBy = sortBy
+				} else {
+					field, _, _ := strings.Cut(cleanString(sort), ",")
+					cmd.PrintErrf("warning: TMDB can't sort by %s, sorting the fetched movies only\n", field)
+				}
+			}
 			url, err = deps.URLBuilder.discover(q)
 			if err != nil {
 				return err
 			}
-			var wantItems int
-			if maxItems == "" {
-				wantItems = 20
-			} else {
-				wantItems, err = strconv.Atoi(maxItems)
+			if preview, _ := cmd.Flags().GetBool("preview"); preview {
+				if isAnyGenre(q.WithGenres) {
+					return fmt.Errorf("validation error: --preview requires --genres")
+				}
+				counts, err := previewGenres(cmd.Context(), deps.Client, deps.URLBuilder, q)
 				if err != nil {
-					return fmt.Errorf(`validation error: items must be an integer, e.g. "50"`)
+					return err
+				}
+				cmd.Println(formatGenreCounts(counts, opts))
+				return nil
+			}
+			excluded, err := readExcludedIDs(excludeIDs, excludeIDsFile)
+			if err != nil {
+				return err
+			}
+			fetchRuntime, _ := cmd.Flags().GetBool("fetch-runtime")
+			sortField, _, _ := strings.Cut(cleanString(sort), ",")
+			if sortField == "runtime" && !fetchRuntime && !opts.Enriched {
+				return fmt.Errorf("validation error: sorting by runtime requires --fetch-runtime or --enrich")
+			}
+			dedupeBy, _ := cmd.Flags().GetString("dedupe-by")
+			key, err := dedupeKey(dedupeBy)
+			if err != nil {
+				return err
+			}
+			groupBy, _ := cmd.Flags().GetString("group-by")
+			groupBy = strings.ToLower(cleanString(groupBy))
+			if groupBy != "" {
+				if err := validateGroupField(groupBy); err != nil {
+					return err
 				}
 			}
-			movies, err := asyncFetchMovies(deps.Client, url, wantItems)
+			if countByYear, _ := cmd.Flags().GetBool("count-by-year"); countByYear && groupBy != "" {
+				return fmt.Errorf("validation error: --count-by-year and --group-by are mutually exclusive")
+			}
+			wantItems, err := parseMaxItems(maxItems)
 			if err != nil {
 				return err
 			}
+			if err := setMaxPages(cmd, deps.Client); err != nil {
+				return err
+			}
+			fetchItems := wantItems
+			if sortBeforeTrim, _ := cmd.Flags().GetBool("sort-before-trim"); sortBeforeTrim && sort != "" {
+				oversample, _ := cmd.Flags().GetInt("oversample")
+				if oversample < 1 {
+					return fmt.Errorf("validation error: oversample must be ≥ 1")
+				}
+				fetchItems = min(wantItems*oversample, APIMaxItems)
+			}
+			hasPoster, _ := cmd.Flags().GetBool("has-poster")
+			var onlyGenres map[int]bool
+			if only, _ := cmd.Flags().GetString("only-genres"); only != "" {
+				if onlyGenres, err = parseOnlyGenres(only); err != nil {
+					return err
+				}
+			}
+			minResults, _ := cmd.Flags().GetInt("min-results")
+			relaxStep, _ := cmd.Flags().GetFloat64("relax-step")
+			relaxFloor, _ := cmd.Flags().GetFloat64("relax-floor")
+			if err := validateMinResults(minResults, wantItems, relaxStep, q.VoteAverage); err != nil {
+				return err
+			}
+			keep := func(m movie) bool {
+				return !excluded[m.ID] && (!hasPoster || m.hasPoster()) &&
+					(onlyGenres == nil || m.hasOnlyGenres(onlyGenres))
+			}
+			var duplicates []int
+			deps.Client.OnDuplicates = func(ids []int) { duplicates = ids }
+			movies, total, err := asyncFetchMovies(cmd.Context(), deps.Client, url, fetchItems, keep)
+			for initial := q.VoteAverage; err == nil && len(movies) < minResults; {
+				relaxed, ok := relaxVoteAverage(q.VoteAverage, relaxStep, relaxFloor)
+				if !ok {
+					cmd.PrintErrf("warning: found only %d of %d movies, even with --average=%s\n",
+						len(movies), minResults, q.VoteAverage)
+					break
+				}
+				q.VoteAverage = relaxed
+				if url, err = deps.URLBuilder.discover(q); err != nil {
+					return err
+				}
+				movies, total, err = asyncFetchMovies(cmd.Context(), deps.Client, url, fetchItems, keep)
+				if quiet, _ := cmd.Flags().GetBool("quiet"); !quiet && err == nil && len(movies) >= minResults {
+					cmd.PrintErrf("relaxed --average from %s to %s to find %d movies\n", initial, relaxed, minResults)
+				}
+			}
+			interrupted := errors.Is(err, errInterrupted)
+			if err != nil && !interrupted {
+				return err
+			}
+			movies, collapsed := movies.collapseBy(key)
+			if dedupReport, _ := cmd.Flags().GetBool("dedup-report"); dedupReport {
+				printDedupReport(cmd, append(duplicates, collapsed...))
+			}
+			switch {
+			case opts.Enriched:
+				if err := enrichMovies(cmd.Context(), deps.Client, deps.URLBuilder, movies); err != nil {
+					return err
+				}
+			case fetchRuntime:
+				if err := fetchRuntimes(cmd.Context(), deps.Client, deps.URLBuilder, movies); err != nil {
+					return err
+				}
+			}
 			if sort != "" {
-				_, err = movies.sortByField(sort)
+				nulls, _ := cmd.Flags().GetString("sort-nulls")
+				_, err = movies.sortByFieldNulls(sort, nulls)
 				if err != nil {
 					return err
 				}
 			}
-			output := formatResults(movies)
-			cmd.Println(output)
+			if reverse, _ := cmd.Flags().GetBool("reverse"); reverse {
+				movies.reverse()
+			}
+			if len(movies) > wantItems {
+				movies = movies[:wantItems]
+			}
+			if cmd.Flags().Changed("random") {
+				random, _ := cmd.Flags().GetInt("random")
+				seed, _ := cmd.Flags().GetUint64("seed")
+				if !cmd.Flags().Changed("seed") {
+					seed = mathrand.Uint64()
+				}
+				movies, err = movies.sample(random, mathrand.New(mathrand.NewPCG(seed, seed)))
+				if err != nil {
+					return err
+				}
+			}
+			if dumpDir, _ := cmd.Flags().GetString("dump"); dumpDir != "" {
+				if err := dumpMovies(dumpDir, movies); err != nil {
+					return err
+				}
+				if quiet, _ := cmd.Flags().GetBool("quiet"); !quiet {
+					cmd.PrintErrf("dumped %d movies to %s\n", len(movies), dumpDir)
+				}
+			}
+			countByYear, _ := cmd.Flags().GetBool("count-by-year")
+			switch {
+			case quietEmpty(cmd, movies):
+				// Reported by the exit code of --fail-on-empty alone
+			case countByYear:
+				cmd.Println(formatYearCounts(movies.countByYear(), opts))
+			case groupBy != "":
+				groups, _ := movies.groupBy(groupBy)
+				cmd.Println(formatGroups(groupBy, groups, opts))
+			default:
+				opts.HighlightGenres = genreIDs(q.WithGenres)
+				if withMeta {
+					q.MaxItems = wantItems
+					opts.Meta = &resultsEnvelope{Query: q, URL: redactURL(url), TotalResults: total}
+				}
+				output, err := formatMovies(movies, format, opts)
+				if err != nil {
+					return err
+				}
+				cmd.Println(output)
+			}
+			if interrupted {
+				cmd.PrintErrln(errInterrupted)
+				return nil
+			}
+			// Sampled, aggregated or date-bounded results aren't a prefix a higher --max-items extends
+			if !cmd.Flags().Changed("random") && groupBy == "" && !countByYear && runKey == "" {
+				printMoreResultsHint(cmd, len(movies), total, wantItems)
+			}
+			if runKey != "" {
+				if err := writeLastRun(deps.ConfigDir, runKey, nowFunc()); err != nil {
+					return err
+				}
+			}
+			if failOnEmpty, _ := cmd.Flags().GetBool("fail-on-empty"); failOnEmpty && len(movies) == 0 {
+				return errNoResults
+			}
 			return nil
 		},
 	}
+	addFilterFlags(discoverCmd)
 	flags := []struct {
 		name  string
 		alias string
 		help  string
 	}{
-		{"language", "l", "original language (not the country!)"},
-		{"year", "y", "primary release year or dates"},
-		{"average", "a", "votes average"},
-		{"votes", "v", "vote counts"},
-		{"genres", "g", "with one or many genres"},
-		{"without-genres", "w", "without one or many genres"},
 		{"sort", "s", "sort by field and order"},
 		{"max-items", "m", fmt.Sprintf("maximum number of movies, default 20, max %d", APIMaxItems)},
+		{"exclude-ids", "", "exclude movies by comma-separated TMDB IDs"},
+		{"exclude-ids-file", "", "exclude movies by TMDB IDs read from a file, one per line"},
+		{"format", "", fmt.Sprintf("output format, one of: %v", outputFormats)},
 	}
 	for _, flag := range flags {
 		discoverCmd.Flags().StringP(flag.name, flag.alias, "", flag.help)
 	}
+	discoverCmd.Flags().String("sort-nulls", "", `place movies missing the sort field "first" or "last"`)
+	discoverCmd.Flags().BoolP("reverse", "R", false, "reverse the sort order, or the natural order without --sort")
+	discoverCmd.Flags().String("dedupe-by", "id", `drop repeated movies by "id" or normalized "title"`)
+	discoverCmd.Flags().Bool("dedup-report", false,
+		"print to stderr how many repeated movies were dropped, and their IDs")
+	discoverCmd.Flags().Bool("fetch-runtime", false, "fetch each movie's details for its runtime, one request per movie")
+	discoverCmd.Flags().Bool("enrich", false,
+		"fetch each movie's details for its runtime, overview and genres, one request per movie")
+	discoverCmd.Flags().Int("max-pages", 0, "maximum number of API pages fetched, 0 for no limit")
+	discoverCmd.Flags().Bool("only-released", false, "only movies released up to today")
+	discoverCmd.Flags().Bool("has-poster", false, "drop movies without a poster")
+	discoverCmd.Flags().Bool("fail-on-empty", false, "exit with code 2 when no movie is found, e.g. for CI checks")
+	discoverCmd.Flags().String("only-genres", "",
+		"drop movies carrying any genre outside this comma-separated list, e.g. drama,romance")
+	discoverCmd.Flags().Bool("show-genres", false, "add a genres column, highlighting the filtered genres")
+	discoverCmd.Flags().Bool("since-last-run", false, "only show movies released since the last run of the same query")
+	discoverCmd.Flags().Bool("count-by-year", false, "count matching movies per release year")
+	discoverCmd.Flags().Bool("preview", false,
+		"count TMDB's results with each group of --genres alone and combined, instead of listing movies")
+	discoverCmd.Flags().String("dump", "",
+		"write one JSON file per movie, named by ID, and an index.json into this directory")
+	discoverCmd.Flags().Bool("with-meta", false, "wrap the JSON results with the query and URL that produced them")
+	discoverCmd.Flags().Int("random", 0, "show only N movies picked at random among the fetched ones")
+	discoverCmd.Flags().Uint64("seed", 0, "seed of --random for a reproducible pick, random by default")
+	discoverCmd.Flags().String("group-by", "", fmt.Sprintf("count movies and average their rating per group, one of: %v",
+		groupFields))
+	discoverCmd.Flags().Bool("sort-before-trim", false, "fetch extra movies and sort them before keeping max-items")
+	discoverCmd.Flags().Bool("server-sort", false, "sort on TMDB's side, over all the results rather than the fetched ones")
+	discoverCmd.Flags().Int("oversample", defaultOversample, "multiple of max-items fetched with --sort-before-trim")
+	discoverCmd.Flags().Int("min-results", 0,
+		"lower the --average minimum by --relax-step and query again until finding this many movies")
+	discoverCmd.Flags().Float64("relax-step", defaultRelaxStep, "step lowering the --average minimum for --min-results")
+	discoverCmd.Flags().Float64("relax-floor", minVoteAverage, "lowest --average minimum reached by --min-results")
 	return discoverCmd
 }
 
-// completionCommand generates shell autocompletion scripts (hidden helper).
-func completionCommand() *cobra.Command {
-	return &cobra.Command{
-		Use:    "completion",
-		Short:  "Generate the autocompletion script for the specified shell",
-		Hidden: true,
+// filterFlags are the discover filters, shared with list --also-discover.
+var filterFlags = []struct {
+	name  string
+	alias string
+	help  string
+}{
+	{"language", "l", "original language (not the country!)"},
+	{"year", "y", "primary release year or dates"},
+	{"average", "a", "votes average"},
+	{"votes", "v", "vote counts"},
+	{"runtime", "", "runtime in minutes"},
+	{"genres", "g", `with one or many genres, "|" meaning or, e.g. "animation|science-fiction,drama", "any" for all`},
+	{"without-genres", "w", "without one or many genres"},
+	{"genres-match", "", `match "all" (default) or "any" of the genres, overrides genres_default_match`},
+}
+
+// dateFlags are the discover flags each picking the release dates on their own, with
+// what to pick them for.
+var dateFlags = []struct {
+	name string
+	use  string
+}{
+	{"year", "a year or a range of years"},
+	{"primary_release_year", "a year, under its TMDB name"},
+	{"since-last-run", "the movies released since the last run of the same query"},
+}
+
+// checkDateFlags rejects combinations of date flags, explaining which one to pick.
+func checkDateFlags(cmd *cobra.Command) error {
+	var set, uses []string
+	for _, flag := range dateFlags {
+		f := cmd.Flags().Lookup(flag.name)
+		if f == nil || f.Value.String() == f.DefValue {
+			continue
+		}
+		set = append(set, "--"+f.Name)
+		uses = append(uses, fmt.Sprintf("--%s for %s", f.Name, flag.use))
 	}
+	if len(set) < 2 {
+		return nil
+	}
+	return fmt.Errorf("validation error: %s are mutually exclusive, pick one: %s",
+		strings.Join(set, " and "), strings.Join(uses, ", or "))
 }
 
-// getDependencies retrieves API clients from context for command execution.
-func getDependencies(cmd *cobra.Command) (*Dependencies, error) {
-	deps, ok := cmd.Context().Value(dependencies).(*Dependencies)
+// tmdbAliases are hidden discover flags named after TMDB's parameters, easing the
+// migration of curl-based scripts. Each sets a filter flag, its "gte" and "lte" bounds
+// making a range when both are given.
+var tmdbAliases = []struct {
+	name   string
+	filter string
+	bound  string
+}{
+	{"with_original_language", "language", ""},
+	{"primary_release_year", "year", ""},
+	{"vote_average.gte", "average", "gte"},
+	{"vote_average.lte", "average", "lte"},
+	{"vote_count.gte", "votes", "gte"},
+	{"vote_count.lte", "votes", "lte"},
+	{"with_runtime.gte", "runtime", "gte"},
+	{"with_runtime.lte", "runtime", "lte"},
+	{"with_genres", "genres", ""},
+	{"without_genres", "without-genres", ""},
+}
+
+// addFilterFlags registers the discover filters on a command, along with their
+// hidden TMDB aliases.
+func addFilterFlags(cmd *cobra.Command) {
+	for _, flag := range filterFlags {
+		cmd.Flags().StringP(flag.name, flag.alias, "", flag.help)
+	}
+	for _, alias := range tmdbAliases {
+		cmd.Flags().String(alias.name, "", fmt.Sprintf("TMDB name of --%s", alias.filter))
+		_ = cmd.Flags().MarkHidden(alias.name)
+	}
+}
+
+// readTMDBAliases maps the TMDB aliases given on the command line to the value of
+// their filter flag, e.g. "--vote_average.gte=7" to "7,gte", or with
+// "--vote_average.lte=9" too, to "7,9". Genre IDs are mapped to their names.
+func readTMDBAliases(cmd *cobra.Command) map[string]string {
+	bounds := make(map[string]map[string]string)
+	for _, alias := range tmdbAliases {
+		value, _ := cmd.Flags().GetString(alias.name)
+		if value == "" {
+			continue
+		}
+		if strings.Contains(alias.filter, "genres") {
+			value = genreNames(value)
+		}
+		if bounds[alias.filter] == nil {
+			bounds[alias.filter] = make(map[string]string)
+		}
+		bounds[alias.filter][alias.bound] = value
+	}
+	values := make(map[string]string)
+	for filter, bound := range bounds {
+		gte, hasGTE := bound["gte"]
+		lte, hasLTE := bound["lte"]
+		switch {
+		case hasGTE && hasLTE:
+			values[filter] = gte + "," + lte
+		case hasGTE:
+			values[filter] = gte + ",gte"
+		case hasLTE:
+			values[filter] = lte + ",lte"
+		default:
+			values[filter] = bound[""]
+		}
+	}
+	return values
+}
+
+// configDefault returns the value of a string flag, or the configuration value of
+// key when the flag isn't given on the command line and the key is set.
+func configDefault(cmd *cobra.Command, flag, key string) string {
+	value, _ := cmd.Flags().GetString(flag)
+	if configured := viper.GetString(key); !cmd.Flags().Changed(flag) && configured != "" {
+		return configured
+	}
+	return value
+}
+
+// readFilterFlags reads the discover filters, applying the configured defaults and
+// reading the genres given as "@file".
+func readFilterFlags(cmd *cobra.Command) (queryParams, error) {
+	q := queryParams{GenresMatch: viper.GetString("genres_default_match")}
+	flags := map[string]*string{
+		"language":       &q.Language,
+		"year":           &q.Year,
+		"average":        &q.VoteAverage,
+		"votes":          &q.VoteCount,
+		"runtime":        &q.Runtime,
+		"genres":         &q.WithGenres,
+		"without-genres": &q.WithoutGenres,
+		"genres-match":   &q.GenresMatch,
+	}
+	aliases := readTMDBAliases(cmd)
+	for name, value := range flags {
+		if flagValue, _ := cmd.Flags().GetString(name); flagValue != "" {
+			*value = flagValue
+		} else if aliasValue := aliases[name]; aliasValue != "" {
+			*value = aliasValue
+		}
+	}
+	if minVotes := viper.GetInt("default_min_votes"); q.VoteCount == "" && minVotes > 0 {
+		q.VoteCount = fmt.Sprintf("%d,gte", minVotes)
+	}
+	for _, genres := range []*string{&q.WithGenres, &q.WithoutGenres} {
+		var err error
+		if *genres, err = readGenresFile(*genres); err != nil {
+			return q, err
+		}
+	}
+	return q, nil
+}
+
+// readGenresFile reads the genres of a value starting with "@" from the file it
+// names, separated by commas or newlines, e.g. "@genres.txt". Other values are
+// returned as is.
+func readGenresFile(value string) (string, error) {
+	path, ok := strings.CutPrefix(value, "@")
 	if !ok {
-		return nil, fmt.Errorf("retrieve dependencies from context")
+		return value, nil
 	}
-	return deps, nil
+	byt, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read the genres file: %w", err)
+	}
+	isSeparator := func(r rune) bool { return r == ',' || r == '\n' }
+	groups := strings.FieldsFunc(string(byt), isSeparator)
+	for i, group := range groups {
+		groups[i] = strings.TrimSpace(group)
+	}
+	return strings.Join(groups, ","), nil
 }
 
-// formatResults converts movie data into a formatted table for terminal output.
-func formatResults(movies movies) string {
-	if len(movies) == 0 {
-		return "No results available. Please try another query."
+// newCollectionCmd creates the command to display all the movies of a franchise.
+func newCollectionCmd() *cobra.Command {
+	var sort, nulls, format string
+	var reverse bool
+	collectionCmd := &cobra.Command{
+		Use:   "collection <id>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Display all the movies of a collection",
+		Long: `Retrieve and display all the parts of a movie collection (franchise) from
+The Movie Database (TMDB), sorted by release date unless another sort is given.`,
+		Example: `  go-tmdb-cli collection 119
+  go-tmdb-cli collection 119 -s=average,desc --format=json --output-sort-key=`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format = configDefault(cmd, "format", "default_format")
+			if err := validateFormat(format); err != nil {
+				return err
+			}
+			opts, err := newOutputOptions(cmd)
+			if err != nil {
+				return err
+			}
+			deps, err := getDependencies(cmd)
+			if err != nil {
+				return err
+			}
+			url, err := deps.URLBuilder.collection(args[0])
+			if err != nil {
+				return err
+			}
+			collection, err := fetchCollection(cmd.Context(), deps.Client, url)
+			var statusErr *statusError
+			if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+				return fmt.Errorf("collection not found: %s", args[0])
+			}
+			if err != nil {
+				return err
+			}
+			if _, err := collection.Parts.sortByFieldNulls(sort, nulls); err != nil {
+				return err
+			}
+			if reverse {
+				collection.Parts.reverse()
+			}
+			output, err := formatMovies(collection.Parts, format, opts)
+			if err != nil {
+				return err
+			}
+			if format == "table" {
+				cmd.Println(collection.Name)
+			}
+			cmd.Println(output)
+			return nil
+		},
 	}
-	var buf bytes.Buffer
-	table := tablewriter.NewWriter(&buf)
-	table.SetHeader([]string{
-		"#",
-		"Original Title",
-		"Release Date",
-		"Title",
-		"Average",
-		"Votes",
-	})
-	table.SetRowLine(true)
-	table.SetBorder(true)
-	table.SetColumnSeparator("│")
-	table.SetRowSeparator("⎯")
-	table.SetAlignment(tablewriter.ALIGN_LEFT)
-	for i, r := range movies {
-		table.Append([]string{
-			fmt.Sprintf("%d", i+1),
-			r.OriginalTitle,
-			r.ReleaseDate,
-			r.Title,
-			fmt.Sprintf("%.1f", r.VoteAverage),
-			fmt.Sprintf("%d", r.VoteCount),
-		})
+	collectionCmd.Flags().StringVarP(&sort, "sort", "s", "date,asc", "sort by field and order")
+	collectionCmd.Flags().StringVar(&nulls, "sort-nulls", "", `place movies missing the sort field "first" or "last"`)
+	collectionCmd.Flags().BoolVarP(&reverse, "reverse", "R", false, "reverse the sort order")
+	collectionCmd.Flags().StringVar(&format, "format", "table", fmt.Sprintf("output format, one of: %v", outputFormats))
+	return collectionCmd
+}
+
+// newFindCmd looks a movie up by its IMDb ID.
+func newFindCmd() *cobra.Command {
+	var imdbID, format string
+	findCmd := &cobra.Command{
+		Use:   "find --imdb <id>",
+		Args:  cobra.NoArgs,
+		Short: "Find a movie by its IMDb ID",
+		Long:  `Retrieve the movie matching an IMDb ID (tt followed by digits) from The Movie Database (TMDB).`,
+		Example: `  go-tmdb-cli find --imdb tt0133093
+  go-tmdb-cli find --imdb tt0133093 --format=json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format = configDefault(cmd, "format", "default_format")
+			if err := validateFormat(format); err != nil {
+				return err
+			}
+			opts, err := newOutputOptions(cmd)
+			if err != nil {
+				return err
+			}
+			deps, err := getDependencies(cmd)
+			if err != nil {
+				return err
+			}
+			url, err := deps.URLBuilder.find(imdbID, "imdb_id")
+			if err != nil {
+				return err
+			}
+			movies, err := fetchFind(cmd.Context(), deps.Client, url)
+			if err != nil {
+				return err
+			}
+			output, err := formatMovies(movies, format, opts)
+			if err != nil {
+				return err
+			}
+			cmd.Println(output)
+			return nil
+		},
+	}
+	findCmd.Flags().StringVar(&imdbID, "imdb", "", `IMDb ID of the movie, e.g. "tt0133093"`)
+	findCmd.Flags().StringVar(&format, "format", "table", fmt.Sprintf("output format, one of: %v", outputFormats))
+	_ = findCmd.MarkFlagRequired("imdb")
+	return findCmd
+}
+
+// newCompareCmd shows the key metrics of two movies side by side.
+func newCompareCmd() *cobra.Command {
+	compareCmd := &cobra.Command{
+		Use:   "compare <id1> <id2>",
+		Args:  cobra.ExactArgs(2),
+		Short: "Compare two movies side by side",
+		Long: `Retrieve the details of two movies from The Movie Database (TMDB) and show their
+runtime, rating, votes, release date and genres side by side, marking the higher value
+of each row.`,
+		Example: `  go-tmdb-cli compare 603 604`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids := make([]int, len(args))
+			for i, arg := range args {
+				id, err := parseMovieID(arg)
+				if err != nil {
+					return err
+				}
+				ids[i] = id
+			}
+			opts, err := newOutputOptions(cmd)
+			if err != nil {
+				return err
+			}
+			deps, err := getDependencies(cmd)
+			if err != nil {
+				return err
+			}
+			details := make([]movieDetails, len(ids))
+			for i, id := range ids {
+				if details[i], err = fetchDetails(cmd.Context(), deps.Client, deps.URLBuilder.details(id)); err != nil {
+					return err
+				}
+			}
+			cmd.Println(formatComparison(details[0], details[1], opts))
+			return nil
+		},
+	}
+	return compareCmd
+}
+
+// newWatchlistCmd manages the watchlist of the account owning the access token.
+func newWatchlistCmd() *cobra.Command {
+	watchlistCmd := &cobra.Command{
+		Use:   "watchlist",
+		Args:  cobra.NoArgs,
+		Short: "Manage the watchlist of your TMDB account",
+		Long: `Add movies to, remove them from, and list the watchlist of the TMDB account
+owning the access token. It needs an access token approved for the account, set as
+api_key, since the API read access token can't change an account.`,
+		Example: `  go-tmdb-cli watchlist add 603
+  go-tmdb-cli watchlist remove 603
+  go-tmdb-cli watchlist list`,
+		Run: func(cmd *cobra.Command, args []string) {
+			_ = cmd.Help()
+		},
+	}
+	for _, c := range []struct {
+		use, short, done string
+		watchlist        bool
+	}{
+		{use: "add <id>", short: "Add a movie to the watchlist", done: "added %d to the watchlist", watchlist: true},
+		{use: "remove <id>", short: "Remove a movie from the watchlist", done: "removed %d from the watchlist"},
+	} {
+		watchlistCmd.AddCommand(&cobra.Command{
+			Use:   c.use,
+			Args:  cobra.ExactArgs(1),
+			Short: c.short,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				id, err := parseMovieID(args[0])
+				if err != nil {
+					return err
+				}
+				deps, err := getDependencies(cmd)
+				if err != nil {
+					return err
+				}
+				accountID, err := fetchAccountID(cmd.Context(), deps.Client, deps.URLBuilder.account())
+				if err != nil {
+					return accountAuthHint(err)
+				}
+				url := deps.URLBuilder.watchlist(accountID)
+				if err := updateWatchlist(cmd.Context(), deps.Client, url, id, c.watchlist); err != nil {
+					return accountAuthHint(err)
+				}
+				cmd.Printf(c.done+"\n", id)
+				return nil
+			},
+		})
+	}
+	watchlistCmd.AddCommand(newWatchlistListCmd())
+	return watchlistCmd
+}
+
+// newWatchlistListCmd lists the movies of the account watchlist.
+func newWatchlistListCmd() *cobra.Command {
+	var format, maxItems string
+	listCmd := &cobra.Command{
+		Use:         "list",
+		Args:        cobra.NoArgs,
+		Short:       "List the movies of the watchlist",
+		Annotations: map[string]string{apiVersionsAnnotation: "3,4"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format = configDefault(cmd, "format", "default_format")
+			maxItems = configDefault(cmd, "max-items", "default_max_items")
+			if err := validateFormat(format); err != nil {
+				return err
+			}
+			opts, err := newOutputOptions(cmd)
+			if err != nil {
+				return err
+			}
+			wantItems, err := parseMaxItems(maxItems)
+			if err != nil {
+				return err
+			}
+			deps, err := getDependencies(cmd)
+			if err != nil {
+				return err
+			}
+			accountID, err := watchlistAccountID(cmd.Context(), deps)
+			if err != nil {
+				return err
+			}
+			url := deps.URLBuilder.watchlistMovies(accountID)
+			tmdbRes, total, err := asyncFetchMovies(cmd.Context(), deps.Client, url, wantItems, nil)
+			interrupted := errors.Is(err, errInterrupted)
+			if err != nil && !interrupted {
+				return accountAuthHint(err)
+			}
+			output, err := formatMovies(tmdbRes, format, opts)
+			if err != nil {
+				return err
+			}
+			cmd.Println(output)
+			if interrupted {
+				cmd.PrintErrln(errInterrupted)
+				return nil
+			}
+			printMoreResultsHint(cmd, len(tmdbRes), total, wantItems)
+			return nil
+		},
+	}
+	listCmd.Flags().StringVar(&format, "format", "table", fmt.Sprintf("output format, one of: %v", outputFormats))
+	listCmd.Flags().StringVarP(&maxItems, "max-items", "m", "",
+		fmt.Sprintf("maximum number of movies, default 20, max %d", APIMaxItems))
+	return listCmd
+}
+
+// watchlistAccountID identifies the account of the watchlist: its numeric ID fetched
+// from version 3 of the API, or its object ID read from the access token for version 4.
+func watchlistAccountID(ctx context.Context, deps *Dependencies) (string, error) {
+	if deps.URLBuilder.Version == 4 {
+		return accountObjectID(deps.Client.APIKey)
+	}
+	accountID, err := fetchAccountID(ctx, deps.Client, deps.URLBuilder.account())
+	if err != nil {
+		return "", accountAuthHint(err)
+	}
+	return strconv.Itoa(accountID), nil
+}
+
+// accountAuthHint explains the authentication failures of the commands acting on an
+// account, which need more than the API read access token.
+func accountAuthHint(err error) error {
+	var statusErr *statusError
+	var expired *expiredTokenError
+	if errors.As(err, &expired) {
+		return err // Regenerating the token is the fix, whatever its permissions
+	}
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("authentication error: this command needs an access token approved "+
+			"for your TMDB account as api_key: %w", err)
+	}
+	return err
+}
+
+// newRateCmd rates a movie on behalf of the account owning the access token.
+func newRateCmd() *cobra.Command {
+	rateCmd := &cobra.Command{
+		Use:   "rate <id> <value>",
+		Args:  cobra.ExactArgs(2),
+		Short: "Rate a movie",
+		Long: `Rate a movie on The Movie Database (TMDB), from 0.5 to 10 by steps of 0.5, on
+behalf of the account owning the access token, see watchlist for the token needed.`,
+		Example: `  go-tmdb-cli rate 603 8.5`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseMovieID(args[0])
+			if err != nil {
+				return err
+			}
+			value, err := validateRating(args[1])
+			if err != nil {
+				return err
+			}
+			deps, err := getDependencies(cmd)
+			if err != nil {
+				return err
+			}
+			if err := rateMovie(cmd.Context(), deps.Client, deps.URLBuilder.rating(id), value); err != nil {
+				return accountAuthHint(err)
+			}
+			cmd.Printf("rated %d %s\n", id, strconv.FormatFloat(value, 'f', -1, 64))
+			return nil
+		},
+	}
+	return rateCmd
+}
+
+// newDoctorCmd diagnoses the configuration, the API key and the network access.
+func newDoctorCmd(fileName string) *cobra.Command {
+	var offline bool
+	doctorCmd := &cobra.Command{
+		Use:   "doctor",
+		Args:  cobra.NoArgs,
+		Short: "Diagnose the setup of the CLI",
+		Long: `Check the configuration file, the API key, the configuration directory and the
+access to the TMDB API, printing a hint for each failed check.`,
+		Example: `  go-tmdb-cli doctor
+  go-tmdb-cli doctor --offline`,
+		// Runs its own checks instead of failing on the first misconfiguration.
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return setOutputEncoding(cmd) },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfgDir, _ := cmd.Flags().GetString("config-dir")
+			apiKeyFile, _ := cmd.Flags().GetString("api-key-file")
+			baseURL := newURLBuilder().BaseURL
+			if deps, err := getDependencies(cmd); err == nil {
+				baseURL = deps.URLBuilder.BaseURL
+			}
+			var results []checkResult
+			dir, err := configDir(&defaultUserHome{}, cfgDir)
+			if err != nil {
+				results = append(results, checkResult{Name: "config dir", Detail: err.Error(),
+					Hint: "set HOME or pass --config-dir"})
+			} else {
+				config := checkConfigFile(&defaultUserHome{}, dir, fileName)
+				results = append(results, config)
+				if config.OK {
+					keyCheck, key := checkAPIKey(apiKeyFile)
+					results = append(results, keyCheck)
+					if keyCheck.OK && !offline {
+						hc := newHTTPClient(key)
+						hc.NoRetry = true
+						results = append(results, checkAuth(cmd.Context(), hc, baseURL))
+					}
+				}
+				results = append(results, checkConfigDirWritable(dir))
+			}
+			if !offline {
+				results = append(results, checkReachability(cmd.Context(), http.DefaultClient, baseURL))
+			}
+			failed := 0
+			for _, result := range results {
+				cmd.Println(result)
+				if !result.OK {
+					failed++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("doctor found %d problem(s)", failed)
+			}
+			return nil
+		},
+	}
+	doctorCmd.Flags().BoolVar(&offline, "offline", false, "skip the checks calling the TMDB API")
+	return doctorCmd
+}
+
+// newLanguagesCmd lists the language codes accepted by --language.
+func newLanguagesCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "languages",
+		Args:  cobra.NoArgs,
+		Short: "List the language codes accepted by --language",
+		Long: `List the ISO 639-1 codes accepted by the --language filter, with their
+English name, including the codes TMDB uses for Cantonese and no language.`,
+		// Offline command: neither the configuration file nor the API key is needed.
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return setOutputEncoding(cmd) },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := newOutputOptions(cmd)
+			if err != nil {
+				return err
+			}
+			cmd.Println(formatLanguages(opts))
+			return nil
+		},
+	}
+}
+
+// newMergeCmd combines saved JSON outputs into a single deduplicated list.
+func newMergeCmd() *cobra.Command {
+	var sort, nulls, format, dedupeBy string
+	var reverse bool
+	mergeCmd := &cobra.Command{
+		Use:   "merge file.json [file.json...]",
+		Args:  cobra.MinimumNArgs(1),
+		Short: "Merge and deduplicate saved JSON results",
+		Long: `Merge reads movies from JSON files saved with --format=json, or raw TMDB
+responses with a "results" array, and prints them as a single deduplicated list.`,
+		Example: `  go-tmdb-cli merge popular.json top.json
+  go-tmdb-cli merge week1.json week2.json -s=average,desc --format=json --output-sort-key=`,
+		// Offline command: neither the configuration file nor the API key is needed.
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return setOutputEncoding(cmd) },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateFormat(format); err != nil {
+				return err
+			}
+			opts, err := newOutputOptions(cmd)
+			if err != nil {
+				return err
+			}
+			var merged movies
+			for _, path := range args {
+				byt, err := os.ReadFile(path)
+				if err != nil {
+					return fmt.Errorf("read the results file: %w", err)
+				}
+				fileMovies, err := UnmarshalMovies(byt)
+				if err != nil {
+					return fmt.Errorf("%s: %w", path, err)
+				}
+				if opts.SourceColumn {
+					fileMovies = fileMovies.withSource(filepath.Base(path))
+				}
+				merged = append(merged, fileMovies...)
+			}
+			key, err := dedupeKey(dedupeBy)
+			if err != nil {
+				return err
+			}
+			merged = merged.deduplicateBy(key)
+			if sort != "" {
+				if _, err := merged.sortByFieldNulls(sort, nulls); err != nil {
+					return err
+				}
+			}
+			if reverse {
+				merged.reverse()
+			}
+			output, err := formatMovies(merged, format, opts)
+			if err != nil {
+				return err
+			}
+			cmd.Println(output)
+			return nil
+		},
+	}
+	mergeCmd.Flags().StringVarP(&sort, "sort", "s", "", "sort by field and order")
+	mergeCmd.Flags().StringVar(&dedupeBy, "dedupe-by", "id", `drop repeated movies by "id" or normalized "title"`)
+	mergeCmd.Flags().StringVar(&nulls, "sort-nulls", "", `place movies missing the sort field "first" or "last"`)
+	mergeCmd.Flags().BoolVarP(&reverse, "reverse", "R", false, "reverse the sort order, or the merge order without --sort")
+	mergeCmd.Flags().StringVar(&format, "format", "table", fmt.Sprintf("output format, one of: %v", outputFormats))
+	mergeCmd.Flags().Bool("source-column", false, "add a source column naming the files each movie came from")
+	return mergeCmd
+}
+
+// commandExamples lists the command lines of the Example of cmd and its subcommands,
+// split into arguments without the program name, so they can be checked to parse.
+func commandExamples(cmd *cobra.Command) [][]string {
+	var examples [][]string
+	for _, line := range strings.Split(cmd.Example, "\n") {
+		args := strings.Fields(line)
+		if len(args) > 0 && args[0] == cmd.Root().Name() {
+			examples = append(examples, args[1:])
+		}
+	}
+	for _, sub := range cmd.Commands() {
+		examples = append(examples, commandExamples(sub)...)
+	}
+	return examples
+}
+
+// completionCommand generates shell autocompletion scripts (hidden helper).
+func completionCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:    "completion",
+		Short:  "Generate the autocompletion script for the specified shell",
+		Hidden: true,
+	}
+}
+
+// inferPositionals maps positional genre names to --genres and a 4-digit number to
+// --year, unless these flags are given explicitly.
+func inferPositionals(cmd *cobra.Command, args []string) error {
+	var genres []string
+	var year string
+	for _, arg := range args {
+		token := strings.ToLower(cleanString(arg))
+		if token == "" {
+			continue
+		}
+		_, isGenre := genresMap[token]
+		_, err := strconv.Atoi(token)
+		switch {
+		case isGenre:
+			genres = append(genres, token)
+		case len(token) == 4 && err == nil && year == "":
+			year = token
+		case len(token) == 4 && err == nil:
+			return fmt.Errorf("validation error: ambiguous years %q and %q, use --year=%s,%s for a range",
+				year, token, year, token)
+		default:
+			return fmt.Errorf("validation error: cannot infer %q, expected a genre or a 4-digit year", arg)
+		}
+	}
+	inferred := map[string]string{"genres": strings.Join(genres, ","), "year": year}
+	for name, value := range inferred {
+		if value == "" || cmd.Flags().Changed(name) {
+			continue
+		}
+		if err := cmd.Flags().Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gluedFlagHint adds a hint to unknown flag errors caused by a long flag glued to
+// its value, e.g. "--votes100,gte" for "--votes=100,gte".
+func gluedFlagHint(cmd *cobra.Command, err error) error {
+	unknown, ok := strings.CutPrefix(err.Error(), "unknown flag: --")
+	if !ok {
+		return err
+	}
+	var hint error
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if value, found := strings.CutPrefix(unknown, flag.Name); found && value != "" && hint == nil {
+			hint = fmt.Errorf("%w\nhint: separate the flag from its value, e.g. --%s=%s", err, flag.Name, value)
+		}
+	})
+	if hint == nil {
+		return err
+	}
+	return hint
+}
+
+// gluedShorthandHint rejects a long flag name typed with a single dash, e.g. "-votes=100"
+// parsed as "-v" with the value "otes=100", and suggests the long flag instead.
+func gluedShorthandHint(cmd *cobra.Command) error {
+	var hint error
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		rest := strings.TrimPrefix(flag.Name, flag.Shorthand)
+		if flag.Shorthand == "" || rest == flag.Name || hint != nil {
+			return
+		}
+		value, found := strings.CutPrefix(flag.Value.String(), rest)
+		if !found {
+			return
+		}
+		suggestion := "--" + flag.Name
+		if value = strings.TrimPrefix(value, "="); value != "" {
+			suggestion += "=" + value
+		}
+		hint = fmt.Errorf("validation error: -%s received %q, did you mean %s?",
+			flag.Shorthand, flag.Value.String(), suggestion)
+	})
+	return hint
+}
+
+// apiVersionsAnnotation lists, comma-separated, the TMDB API versions a command
+// supports, when not only the default one.
+const apiVersionsAnnotation = "api_versions"
+
+// supportsAPIVersion reports whether the command works with the API version. Command
+// groups, which only print their help, support any version.
+func supportsAPIVersion(cmd *cobra.Command, version int) bool {
+	if version == defaultAPIVersion || cmd.HasSubCommands() {
+		return true
+	}
+	versions := strings.Split(cmd.Annotations[apiVersionsAnnotation], ",")
+	return slices.Contains(versions, strconv.Itoa(version))
+}
+
+// getDependencies retrieves API clients from context for command execution.
+func getDependencies(cmd *cobra.Command) (*Dependencies, error) {
+	deps, ok := cmd.Context().Value(dependencies).(*Dependencies)
+	if !ok {
+		return nil, fmt.Errorf("retrieve dependencies from context")
+	}
+	return deps, nil
+}
+
+// parseMaxItems reads the --max-items flag value, defaulting to a single page of results.
+func parseMaxItems(maxItems string) (int, error) {
+	if maxItems == "" {
+		return resultsPerPage, nil
+	}
+	n, err := strconv.Atoi(cleanString(maxItems))
+	if err != nil {
+		return 0, fmt.Errorf(`validation error: items must be an integer, e.g. "50"`)
+	}
+	if n < 1 {
+		return 0, fmt.Errorf("validation error: items must be ≥ 1")
+	}
+	return n, nil
+}
+
+// validateMinResults checks --min-results can be met by relaxing the minimum of the
+// vote average, given as "7,gte" or "7,8".
+func validateMinResults(minResults, maxItems int, step float64, average string) error {
+	switch {
+	case minResults == 0:
+		return nil
+	case minResults < 0 || minResults > maxItems:
+		return fmt.Errorf("validation error: --min-results must be between 0 and max-items (%d)", maxItems)
+	case step <= 0:
+		return fmt.Errorf("validation error: --relax-step must be > 0")
+	}
+	if _, ok := voteAverageMin(average); !ok {
+		return fmt.Errorf(`validation error: --min-results requires a minimum --average, e.g. "7,gte" or "7,9"`)
+	}
+	return nil
+}
+
+// relaxVoteAverage lowers the minimum of the vote average by step, down to floor,
+// keeping its maximum, e.g. "7,gte" becomes "6.5,gte". It reports false once the
+// minimum is at the floor.
+func relaxVoteAverage(average string, step, floor float64) (string, bool) {
+	lower, ok := voteAverageMin(average)
+	if !ok || lower <= floor {
+		return average, false
+	}
+	relaxed := max(math.Round((lower-step)*100)/100, floor)
+	_, upper, _ := strings.Cut(cleanString(average), ",")
+	return strconv.FormatFloat(relaxed, 'f', -1, 64) + "," + upper, true
+}
+
+// voteAverageMin parses the minimum of a vote average filter, reporting false when
+// it only has a maximum, e.g. "7,lte".
+func voteAverageMin(average string) (float64, bool) {
+	lower, upper, found := strings.Cut(cleanString(average), ",")
+	if !found || upper == "lte" {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(lower, 64)
+	return value, err == nil
+}
+
+// printMoreResultsHint tells on stderr, unless --quiet, that TMDB has more results
+// than shown, when a higher --max-items could show them.
+func printMoreResultsHint(cmd *cobra.Command, shown, total, maxItems int) {
+	quiet, _ := cmd.Flags().GetBool("quiet")
+	if quiet || shown == 0 || total <= shown || maxItems >= APIMaxItems {
+		return
+	}
+	cmd.PrintErrf("more results available (showing %d of %d); increase --max-items to see more.\n", shown, total)
+}
+
+// setMaxPages caps the pages fetched by the client with the --max-pages flag.
+func setMaxPages(cmd *cobra.Command, hc *httpClient) error {
+	maxPages, _ := cmd.Flags().GetInt("max-pages")
+	if maxPages < 0 {
+		return fmt.Errorf("validation error: max pages must be ≥ 0")
+	}
+	hc.MaxPages = maxPages
+	return nil
+}
+
+// printDedupReport tells on stderr how many repeated movies were dropped, and which.
+func printDedupReport(cmd *cobra.Command, ids []int) {
+	if len(ids) == 0 {
+		cmd.PrintErrln("removed 0 duplicate entries")
+		return
+	}
+	strIDs := make([]string, len(ids))
+	for i, id := range ids {
+		strIDs[i] = strconv.Itoa(id)
+	}
+	cmd.PrintErrf("removed %d duplicate entries (IDs: %s)\n", len(ids), strings.Join(strIDs, ", "))
+}
+
+// readExcludedIDs collects the movie IDs to exclude from inline and file sources.
+func readExcludedIDs(inline, path string) (map[int]bool, error) {
+	excluded := make(map[int]bool)
+	tokens := strings.Split(cleanString(inline), ",")
+	if path != "" {
+		byt, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read the excluded IDs file: %w", err)
+		}
+		tokens = append(tokens, strings.Split(string(byt), "\n")...)
+	}
+	for _, token := range tokens {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+		id, err := strconv.Atoi(token)
+		if err != nil {
+			return nil, fmt.Errorf(`validation error: excluded ID must be an integer, e.g. "550", got %q`, token)
+		}
+		excluded[id] = true
+	}
+	return excluded, nil
+}
+
+// dumpIndexFile lists the files written by a dump, in the order of the movies.
+const dumpIndexFile = "index.json"
+
+// dumpEntry is an entry of the dump index.
+type dumpEntry struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+	File  string `json:"file"`
+}
+
+// dumpMovies writes each movie as a JSON file named by its ID into dir, created if
+// needed, and an index of the files. A name already taken in the dump, such as a
+// movie repeated with --dedupe-by=title, gets a numbered suffix, e.g. "603-2.json".
+func dumpMovies(dir string, m movies) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create the dump directory: %w", err)
+	}
+	taken := map[string]bool{dumpIndexFile: true}
+	index := make([]dumpEntry, 0, len(m))
+	for _, movie := range m {
+		name := fmt.Sprintf("%d.json", movie.ID)
+		for n := 2; taken[name]; n++ {
+			name = fmt.Sprintf("%d-%d.json", movie.ID, n)
+		}
+		taken[name] = true
+		if err := writeJSONFile(filepath.Join(dir, name), movie); err != nil {
+			return err
+		}
+		index = append(index, dumpEntry{ID: movie.ID, Title: movie.Title, File: name})
+	}
+	return writeJSONFile(filepath.Join(dir, dumpIndexFile), index)
+}
+
+// writeJSONFile writes v as indented JSON to path.
+func writeJSONFile(path string, v any) error {
+	byt, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", filepath.Base(path), err)
+	}
+	if err := os.WriteFile(path, byt, 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// encodingWriter transcodes UTF-8 output to a legacy code page before writing it.
+type encodingWriter struct {
+	w       io.Writer
+	encoder *encoding.Encoder
+}
+
+func (ew *encodingWriter) Write(p []byte) (int, error) {
+	byt, err := ew.encoder.Bytes(p)
+	if err != nil {
+		return 0, fmt.Errorf("encode output: %w", err)
+	}
+	if _, err := ew.w.Write(byt); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// setOutputEncoding wraps the command output to transcode it to the --output-encoding
+// flag value, leaving UTF-8 output untouched.
+func setOutputEncoding(cmd *cobra.Command) error {
+	name, _ := cmd.Flags().GetString("output-encoding")
+	enc, err := htmlindex.Get(cleanString(name))
+	if err != nil {
+		return fmt.Errorf(`validation error: output encoding must be a known charset like "utf-8" or "cp1252": %w`, err)
+	}
+	if enc == unicode.UTF8 {
+		return nil
+	}
+	encoder := encoding.ReplaceUnsupported(enc.NewEncoder())
+	cmd.SetOut(&encodingWriter{w: cmd.OutOrStdout(), encoder: encoder})
+	return nil
+}
+
+// outputFormats lists the supported values of the --format flag.
+var outputFormats = []string{"table", "json", "yaml", "csv"}
+
+// validateFormat rejects unknown output formats before any request is made.
+func validateFormat(format string) error {
+	if format == "" || slices.Contains(outputFormats, format) {
+		return nil
+	}
+	return fmt.Errorf("validation error: format must be one of: %v", outputFormats)
+}
+
+// outputOptions tunes the rendering of the movies in every output format.
+type outputOptions struct {
+	ShowGenres      bool
+	HighlightGenres map[int]bool
+	Color           bool
+	JSONCompact     bool
+	Meta            *resultsEnvelope
+	NoHeader        bool
+	Fields          []movieField
+	SourceColumn    bool
+	// Enriched adds the runtime and genres filled in by --enrich to the table.
+	Enriched bool
+	// Numbers, when set, formats the numbers of the tables by its locale, e.g. "1,000"
+	// or "1.000", leaving them raw otherwise.
+	Numbers *message.Printer
+	// URLKind prints only the URLs of this kind, one per movie, when set.
+	URLKind string
+	// Theme names the style of the tables among tableThemes, "default" when empty.
+	Theme string
+	// ASCII draws the tables with the asciiGlyphs fallbacks of their Unicode glyphs.
+	ASCII bool
+	// OutputSortKey, when set, orders the JSON, YAML and CSV records by this field,
+	// ascending, whatever the order of the table; --output-sort-key defaults to "id".
+	OutputSortKey string
+}
+
+// tableTheme styles the borders and lines of the tables. RowLine only applies to
+// the movie tables, the smaller tables never separating their rows.
+type tableTheme struct {
+	Border  bool
+	RowLine bool
+	Column  string
+	Row     string
+	Center  string
+}
+
+// tableThemes are the presets of the --theme flag.
+var tableThemes = map[string]tableTheme{
+	"default": {Border: true, RowLine: true, Column: "│", Row: "⎯", Center: "+"},
+	"minimal": {Border: true, Column: "│", Row: "─", Center: "┼"},
+	"compact": {Column: " ", Row: "─", Center: " "},
+	"heavy":   {Border: true, RowLine: true, Column: "║", Row: "═", Center: "╬"},
+}
+
+// themeNames lists the supported values of the --theme flag.
+var themeNames = []string{"default", "minimal", "compact", "heavy"}
+
+// asciiGlyphs are the ASCII fallbacks of the Unicode glyphs of the outputs, for
+// terminals and fonts lacking them.
+var asciiGlyphs = map[string]string{
+	"│": "|",
+	"║": "|",
+	"⎯": "-",
+	"─": "-",
+	"═": "=",
+	"┼": "+",
+	"╬": "+",
+}
+
+// glyph returns g, or its ASCII fallback with --ascii.
+func (o outputOptions) glyph(g string) string {
+	if fallback, ok := asciiGlyphs[g]; ok && o.ASCII {
+		return fallback
+	}
+	return g
+}
+
+// tableTheme returns the theme of the tables, the default one when unset.
+func (o outputOptions) tableTheme() tableTheme {
+	theme, ok := tableThemes[o.Theme]
+	if !ok {
+		theme = tableThemes["default"]
+	}
+	theme.Column, theme.Row, theme.Center = o.glyph(theme.Column), o.glyph(theme.Row), o.glyph(theme.Center)
+	return theme
+}
+
+// localeEnv reads the locale variables of the environment, overridable in tests.
+var localeEnv = os.Getenv
+
+// asciiLocale reports whether the locale of the environment names a charset other
+// than UTF-8, e.g. "en_US.ISO-8859-1". Locales without a charset, e.g. "C", keep the
+// Unicode glyphs.
+func asciiLocale() bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		locale := localeEnv(name)
+		if locale == "" {
+			continue
+		}
+		_, charset, _ := strings.Cut(locale, ".")
+		charset, _, _ = strings.Cut(charset, "@")
+		charset = strings.ToLower(strings.ReplaceAll(charset, "-", ""))
+		return charset != "" && charset != "utf8"
+	}
+	return false
+}
+
+// apply sets the borders and separators of the theme on a table.
+func (t tableTheme) apply(table *tablewriter.Table) {
+	table.SetBorder(t.Border)
+	table.SetColumnSeparator(t.Column)
+	table.SetRowSeparator(t.Row)
+	table.SetCenterSeparator(t.Center)
+}
+
+// resultsEnvelope wraps the JSON results with the query that produced them and the
+// total number of results TMDB has for it, beyond the movies shown.
+type resultsEnvelope struct {
+	Query        queryParams `json:"query"`
+	URL          string      `json:"url"`
+	TotalResults int         `json:"total_results"`
+	Results      movies      `json:"results"`
+}
+
+// fieldsEnvelope is the resultsEnvelope of --fields, its results holding only the
+// selected fields of each movie.
+type fieldsEnvelope struct {
+	resultsEnvelope
+	Results []selectedFields `json:"results"`
+}
+
+// movieField is an output column shared by every format: its table and CSV header,
+// its JSON and YAML key, and its value.
+type movieField struct {
+	Name   string
+	Header string
+	Key    string
+	Value  func(m movie) any
+	Text   func(m movie, opts outputOptions) string
+}
+
+// movieFields is the registry of the fields selectable with --fields.
+var movieFields = []movieField{
+	{
+		Name: "id", Header: "ID", Key: "id",
+		Value: func(m movie) any { return m.ID },
+		Text:  func(m movie, _ outputOptions) string { return strconv.Itoa(m.ID) },
+	},
+	{
+		Name: "otitle", Header: "Original Title", Key: "original_title",
+		Value: func(m movie) any { return m.OriginalTitle },
+		Text:  func(m movie, _ outputOptions) string { return m.OriginalTitle },
+	},
+	{
+		Name: "date", Header: "Release Date", Key: "release_date",
+		Value: func(m movie) any { return m.ReleaseDate },
+		Text:  func(m movie, _ outputOptions) string { return m.ReleaseDate },
+	},
+	{
+		Name: "title", Header: "Title", Key: "title",
+		Value: func(m movie) any { return m.Title },
+		Text:  func(m movie, _ outputOptions) string { return m.Title },
+	},
+	{
+		Name: "average", Header: "Average", Key: "vote_average",
+		Value: func(m movie) any { return m.VoteAverage },
+		Text:  func(m movie, opts outputOptions) string { return opts.formatFloat(m.VoteAverage) },
+	},
+	{
+		Name: "votes", Header: "Votes", Key: "vote_count",
+		Value: func(m movie) any { return m.VoteCount },
+		Text:  func(m movie, opts outputOptions) string { return opts.formatInt(m.VoteCount) },
+	},
+	{
+		Name: "runtime", Header: "Runtime", Key: "runtime",
+		Value: func(m movie) any { return m.Runtime },
+		Text:  func(m movie, opts outputOptions) string { return opts.formatInt(m.Runtime) },
+	},
+	{
+		Name: "language", Header: "Language", Key: "original_language",
+		Value: func(m movie) any { return m.OriginalLanguage },
+		Text:  func(m movie, _ outputOptions) string { return m.OriginalLanguage },
+	},
+	{
+		Name: "genres", Header: "Genres", Key: "genres",
+		Value: func(m movie) any {
+			names := make([]string, 0, len(m.GenreIDs))
+			for _, id := range m.GenreIDs {
+				names = append(names, genreName(id))
+			}
+			return names
+		},
+		Text: func(m movie, opts outputOptions) string { return formatGenres(m.GenreIDs, opts) },
+	},
+	{
+		Name: "overview", Header: "Overview", Key: "overview",
+		Value: func(m movie) any { return m.Overview },
+		Text:  func(m movie, _ outputOptions) string { return m.Overview },
+	},
+	{
+		Name: "poster", Header: "Poster", Key: "poster_path",
+		Value: func(m movie) any { return m.PosterPath },
+		Text:  func(m movie, _ outputOptions) string { return m.PosterPath },
+	},
+	{
+		Name: "source", Header: "Source", Key: "source",
+		Value: func(m movie) any { return m.Source },
+		Text:  func(m movie, _ outputOptions) string { return m.Source },
+	},
+}
+
+// parseFields resolves comma-separated --fields names against the registry, in order.
+func parseFields(value string) ([]movieField, error) {
+	value = strings.ToLower(cleanString(value))
+	if value == "" {
+		return nil, nil
+	}
+	var fields []movieField
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		i := slices.IndexFunc(movieFields, func(f movieField) bool { return f.Name == name })
+		if i < 0 {
+			names := make([]string, 0, len(movieFields))
+			for _, f := range movieFields {
+				names = append(names, f.Name)
+			}
+			return nil, fmt.Errorf("validation error: unknown field %q, fields must be among: %v", name, names)
+		}
+		fields = append(fields, movieFields[i])
+	}
+	return fields, nil
+}
+
+// tableFields returns the fields rendered by default, or the --fields selection.
+func tableFields(opts outputOptions) []movieField {
+	if opts.Fields != nil {
+		return opts.Fields
+	}
+	names := "otitle,date,title,average,votes"
+	if opts.Enriched {
+		names += ",runtime"
+	}
+	if opts.ShowGenres || opts.Enriched {
+		names += ",genres"
+	}
+	if opts.SourceColumn {
+		names += ",source"
+	}
+	fields, _ := parseFields(names)
+	return fields
+}
+
+// selectedFields holds the --fields values of a movie in order, encoded as an object.
+type selectedFields []struct {
+	Key   string
+	Value any
+}
+
+// selectFields projects movies onto the given fields, for the JSON and YAML outputs.
+func selectFields(m movies, fields []movieField) []selectedFields {
+	result := make([]selectedFields, 0, len(m))
+	for _, r := range m {
+		var selected selectedFields
+		for _, f := range fields {
+			selected = append(selected, struct {
+				Key   string
+				Value any
+			}{f.Key, f.Value(r)})
+		}
+		result = append(result, selected)
+	}
+	return result
+}
+
+// MarshalJSON encodes the fields as an object, keeping their order.
+func (s selectedFields) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range s {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(f.Key)
+		value, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// MarshalYAML encodes the fields as a mapping, keeping their order.
+func (s selectedFields) MarshalYAML() (any, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range s {
+		var key, value yaml.Node
+		key.SetString(f.Key)
+		if err := value.Encode(f.Value); err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, &key, &value)
+	}
+	return node, nil
+}
+
+// newOutputOptions reads the output flags a command defines, ignoring the others.
+func newOutputOptions(cmd *cobra.Command) (outputOptions, error) {
+	showGenres, _ := cmd.Flags().GetBool("show-genres")
+	enriched, _ := cmd.Flags().GetBool("enrich")
+	sourceColumn, _ := cmd.Flags().GetBool("source-column")
+	jsonCompact, _ := cmd.Flags().GetBool("json-compact")
+	header, _ := cmd.Flags().GetBool("header")
+	fields, _ := cmd.Flags().GetString("fields")
+	selected, err := parseFields(fields)
+	if err != nil {
+		return outputOptions{}, err
+	}
+	var numbers *message.Printer
+	if thousandsSep, _ := cmd.Flags().GetBool("thousands-sep"); thousandsSep {
+		tag := language.English
+		if locale, _ := cmd.Flags().GetString("locale"); locale != "" {
+			if tag, err = language.Parse(locale); err != nil {
+				return outputOptions{}, fmt.Errorf(`validation error: locale must be a BCP 47 tag like "fr" or "pt-BR": %w`, err)
+			}
+		}
+		numbers = message.NewPrinter(tag)
+	}
+	theme, _ := cmd.Flags().GetString("theme")
+	if theme != "" && !slices.Contains(themeNames, theme) {
+		return outputOptions{}, fmt.Errorf("validation error: theme must be one of: %v", themeNames)
+	}
+	ascii, _ := cmd.Flags().GetBool("ascii")
+	if !cmd.Flags().Changed("ascii") {
+		ascii = asciiLocale()
+	}
+	outputSortKey, _ := cmd.Flags().GetString("output-sort-key")
+	if err := validateOutputSortKey(outputSortKey); err != nil {
+		return outputOptions{}, err
+	}
+	var urlKind string
+	if urlsOnly, _ := cmd.Flags().GetBool("urls-only"); urlsOnly {
+		urlKind, _ = cmd.Flags().GetString("url-kind")
+		if !slices.Contains(urlKinds, urlKind) {
+			return outputOptions{}, fmt.Errorf("validation error: url kind must be one of: %v", urlKinds)
+		}
+	}
+	return outputOptions{
+		ShowGenres:    showGenres,
+		Color:         colorEnabled(cmd.OutOrStdout()),
+		JSONCompact:   jsonCompact,
+		NoHeader:      !header,
+		Fields:        selected,
+		SourceColumn:  sourceColumn,
+		Enriched:      enriched,
+		Numbers:       numbers,
+		URLKind:       urlKind,
+		Theme:         theme,
+		ASCII:         ascii,
+		OutputSortKey: outputSortKey,
+	}, nil
+}
+
+// validateOutputSortKey rejects the fields the serialized outputs can't be ordered by.
+func validateOutputSortKey(key string) error {
+	if key == "" || key == "id" {
+		return nil
+	}
+	if _, err := (movies{}).getCompareFunc(key); err != nil {
+		return fmt.Errorf("validation error: output sort key must be one of: %v",
+			[]string{"id", "date", "otitle", "title", "average", "votes", "runtime"})
+	}
+	return nil
+}
+
+// sortForOutput returns a copy of movies ordered by the --output-sort-key field,
+// ascending with ties by ID, leaving the movies as they are.
+func sortForOutput(m movies, key string) movies {
+	sorted := slices.Clone(m)
+	if key == "id" {
+		slices.SortFunc(sorted, func(a, b movie) int { return cmp.Compare(a.ID, b.ID) })
+		return sorted
+	}
+	sorted, _ = sorted.sortByField(key + ",asc")
+	return sorted
+}
+
+// formatInt renders an integer of a table, see Numbers.
+func (o outputOptions) formatInt(n int) string {
+	if o.Numbers == nil {
+		return strconv.Itoa(n)
+	}
+	return o.Numbers.Sprintf("%d", n)
+}
+
+// formatFloat renders a rating of a table with one decimal, see Numbers.
+func (o outputOptions) formatFloat(f float64) string {
+	if o.Numbers == nil {
+		return fmt.Sprintf("%.1f", f)
+	}
+	return o.Numbers.Sprintf("%.1f", f)
+}
+
+// colorEnabled reports whether w is a terminal and NO_COLOR is unset.
+func colorEnabled(w io.Writer) bool {
+	if _, noColor := os.LookupEnv("NO_COLOR"); noColor {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// formatGenres renders genre names, bolding the highlighted ones when color is enabled.
+func formatGenres(ids []int, opts outputOptions) string {
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		name := genreName(id)
+		if opts.Color && opts.HighlightGenres[id] {
+			name = "\033[1m" + name + "\033[0m"
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// formatMovies renders movies in the requested output format.
+func formatMovies(movies movies, format string, opts outputOptions) (string, error) {
+	if err := validateFormat(format); err != nil {
+		return "", err
+	}
+	if opts.URLKind != "" {
+		return formatURLs(movies, opts.URLKind), nil
+	}
+	if serialized := format != "" && format != "table"; serialized && opts.OutputSortKey != "" {
+		movies = sortForOutput(movies, opts.OutputSortKey)
+	}
+	switch format {
+	case "json":
+		switch {
+		case opts.Meta != nil && opts.Fields != nil:
+			envelope := fieldsEnvelope{resultsEnvelope: *opts.Meta, Results: selectFields(movies, opts.Fields)}
+			return marshalJSON(envelope, opts.JSONCompact)
+		case opts.Meta != nil:
+			envelope := *opts.Meta
+			envelope.Results = append(make([]movie, 0, len(movies)), movies...) // "[]" when empty
+			return marshalJSON(envelope, opts.JSONCompact)
+		case opts.Fields != nil:
+			return marshalJSON(selectFields(movies, opts.Fields), opts.JSONCompact)
+		}
+		return formatJSON(movies, opts.JSONCompact)
+	case "yaml":
+		if opts.Fields != nil {
+			byt, err := yaml.Marshal(selectFields(movies, opts.Fields))
+			if err != nil {
+				return "", fmt.Errorf("encode YAML output: %w", err)
+			}
+			return strings.TrimSuffix(string(byt), "\n"), nil
+		}
+		return formatYAML(movies)
+	case "csv":
+		opts.Numbers = nil // Keeps the CSV numbers parsable
+		return formatCSV(movies, opts)
+	}
+	return formatResults(movies, opts), nil
+}
+
+// formatJSON marshals movies to an indented, or minified when compact, JSON array,
+// "[]" when empty.
+func formatJSON(m movies, compact bool) (string, error) {
+	if m == nil {
+		m = movies{}
+	}
+	return marshalJSON(m, compact)
+}
+
+// marshalJSON encodes v as indented, or minified when compact, JSON.
+func marshalJSON(v any, compact bool) (string, error) {
+	marshal := func(v any) ([]byte, error) { return json.MarshalIndent(v, "", "  ") }
+	if compact {
+		marshal = json.Marshal
+	}
+	byt, err := marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("encode JSON output: %w", err)
+	}
+	return string(byt), nil
+}
+
+// formatYAML marshals movies to YAML using the JSON field names, "[]" when empty.
+func formatYAML(m movies) (string, error) {
+	if m == nil {
+		m = movies{}
+	}
+	byt, err := yaml.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf("encode YAML output: %w", err)
+	}
+	return strings.TrimSuffix(string(byt), "\n"), nil
+}
+
+// formatResults converts movie data into a formatted table for terminal output.
+func formatResults(movies movies, opts outputOptions) string {
+	if len(movies) == 0 {
+		return "No results available. Please try another query."
+	}
+	var buf bytes.Buffer
+	table := tablewriter.NewWriter(&buf)
+	if header := movieHeader(opts); header != nil {
+		table.SetHeader(header)
+	}
+	theme := opts.tableTheme()
+	theme.apply(table)
+	table.SetRowLine(theme.RowLine)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	for i, r := range movies {
+		table.Append(movieRow(i, r, opts))
+	}
+	table.Render()
+	return buf.String()
+}
+
+// movieHeader returns the column names of the table and CSV outputs, or nil
+// when the header row is turned off, so that every format drops it alike. The
+// default columns start with the rank, --fields gives the exact columns.
+func movieHeader(opts outputOptions) []string {
+	if opts.NoHeader {
+		return nil
+	}
+	var header []string
+	if opts.Fields == nil {
+		header = append(header, "#")
+	}
+	for _, f := range tableFields(opts) {
+		header = append(header, f.Header)
+	}
+	return header
+}
+
+// movieRow returns the cells of the i-th movie, matching movieHeader.
+func movieRow(i int, r movie, opts outputOptions) []string {
+	var row []string
+	if opts.Fields == nil {
+		row = append(row, fmt.Sprintf("%d", i+1))
+	}
+	for _, f := range tableFields(opts) {
+		row = append(row, f.Text(r, opts))
+	}
+	return row
+}
+
+// formatURLs lists the URLs of the given kind, one per line, skipping the movies
+// without one.
+func formatURLs(m movies, kind string) string {
+	urls := make([]string, 0, len(m))
+	for _, r := range m {
+		if url := r.url(kind); url != "" {
+			urls = append(urls, url)
+		}
+	}
+	return strings.Join(urls, "\n")
+}
+
+// formatCSV renders movies as CSV with the table columns, for spreadsheets.
+func formatCSV(m movies, opts outputOptions) (string, error) {
+	opts.Color = false
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if header := movieHeader(opts); header != nil {
+		w.Write(header)
+	}
+	for i, r := range m {
+		w.Write(movieRow(i, r, opts))
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", fmt.Errorf("encode CSV output: %w", err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// formatLanguages renders the supported language codes as a table sorted by code.
+func formatLanguages(opts outputOptions) string {
+	codes := make([]string, 0, len(languages))
+	for code := range languages {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	var buf bytes.Buffer
+	table := tablewriter.NewWriter(&buf)
+	table.SetHeader([]string{"Code", "Language"})
+	opts.tableTheme().apply(table)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	for _, code := range codes {
+		table.Append([]string{code, languages[code]})
+	}
+	table.Render()
+	return buf.String()
+}
+
+// formatGroups renders the per-group movie counts and average ratings as a small table.
+func formatGroups(field string, groups []groupSummary, opts outputOptions) string {
+	if len(groups) == 0 {
+		return "No results available. Please try another query."
+	}
+	var buf bytes.Buffer
+	table := tablewriter.NewWriter(&buf)
+	table.SetHeader([]string{field, "Count", "Average"})
+	opts.tableTheme().apply(table)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	for _, g := range groups {
+		table.Append([]string{g.Group, opts.formatInt(g.Count), opts.formatFloat(g.Average)})
+	}
+	table.Render()
+	return buf.String()
+}
+
+// formatComparison renders two movies side by side, marking the higher value of
+// each row with a star, or in bold when color is enabled.
+func formatComparison(a, b movieDetails, opts outputOptions) string {
+	mark := func(cells []string, order int) []string {
+		winner := 1
+		if order < 0 {
+			winner = 2
+		}
+		if order != 0 {
+			if opts.Color {
+				cells[winner] = "\033[1m" + cells[winner] + "\033[0m"
+			} else {
+				cells[winner] += " *"
+			}
+		}
+		return cells
+	}
+	dateA, _ := parseReleaseDate(a.ReleaseDate)
+	dateB, _ := parseReleaseDate(b.ReleaseDate)
+	var buf bytes.Buffer
+	table := tablewriter.NewWriter(&buf)
+	table.SetHeader([]string{"", a.Title, b.Title})
+	table.SetAutoFormatHeaders(false)
+	table.SetAutoWrapText(false) // Keeps the bold escapes around whole cells
+	opts.tableTheme().apply(table)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.Append(mark([]string{"Runtime", opts.formatInt(a.Runtime) + " min", opts.formatInt(b.Runtime) + " min"},
+		cmp.Compare(a.Runtime, b.Runtime)))
+	table.Append(mark([]string{"Average", opts.formatFloat(a.VoteAverage), opts.formatFloat(b.VoteAverage)},
+		cmp.Compare(a.VoteAverage, b.VoteAverage)))
+	table.Append(mark([]string{"Votes", opts.formatInt(a.VoteCount), opts.formatInt(b.VoteCount)},
+		cmp.Compare(a.VoteCount, b.VoteCount)))
+	table.Append(mark([]string{"Release date", a.ReleaseDate, b.ReleaseDate}, dateA.Compare(dateB)))
+	table.Append([]string{"Genres", formatGenres(a.genreIDs(), opts), formatGenres(b.genreIDs(), opts)})
+	table.Render()
+	return buf.String()
+}
+
+// formatGenreCounts renders the results counts of --preview as a small table, the
+// combined genres last.
+func formatGenreCounts(counts []genreCount, opts outputOptions) string {
+	var buf bytes.Buffer
+	table := tablewriter.NewWriter(&buf)
+	table.SetHeader([]string{"Genres", "Results"})
+	opts.tableTheme().apply(table)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	for _, c := range counts {
+		table.Append([]string{c.Genres, opts.formatInt(c.Total)})
+	}
+	table.Render()
+	return buf.String()
+}
+
+// formatYearCounts renders the per-year movie counts as a small table.
+func formatYearCounts(counts []yearCount, opts outputOptions) string {
+	if len(counts) == 0 {
+		return "No results available. Please try another query."
+	}
+	var buf bytes.Buffer
+	table := tablewriter.NewWriter(&buf)
+	table.SetHeader([]string{"Year", "Count"})
+	opts.tableTheme().apply(table)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	for _, c := range counts {
+		table.Append([]string{c.Year, opts.formatInt(c.Count)})
 	}
 	table.Render()
 	return buf.String()
@@ -1,15 +1,33 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"gopkg.in/yaml.v3"
 )
 
 func TestIntegrationRootCmd(t *testing.T) {
@@ -76,6 +94,127 @@ func TestIntegrationRootCmd(t *testing.T) {
 	}
 }
 
+func TestIntegrationRootCmd_ConfigDir(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "custom.yaml"), []byte("api_key: valid_api_key"), 0o600)
+	root := newRootCmd("custom.yaml")
+	// Act
+	_, err := executeCommand(root, "--config-dir", dir)
+	// Assert
+	assertNoError(t, err)
+	deps, ok := root.Context().Value(dependencies).(*Dependencies)
+	if !ok {
+		t.Fatal("retrieve dependencies from context")
+	}
+	if deps.ConfigDir != dir {
+		t.Errorf("expected config dir %q, but got %q", dir, deps.ConfigDir)
+	}
+}
+
+func TestIntegrationRootCmd_APIVersion(t *testing.T) {
+	testCases := []struct {
+		name        string
+		args        []string
+		wantBaseURL string
+		wantErr     string
+	}{
+		{name: "default", wantBaseURL: "https://api.themoviedb.org/3"},
+		{name: "version 3", args: []string{"--api-version=3"}, wantBaseURL: "https://api.themoviedb.org/3"},
+		{name: "version 4", args: []string{"--api-version=4"}, wantBaseURL: "https://api.themoviedb.org/4"},
+		{
+			name:        "version 4 command group",
+			args:        []string{"--api-version=4", "watchlist"},
+			wantBaseURL: "https://api.themoviedb.org/4",
+		},
+		{name: "unknown version", args: []string{"--api-version=5"}, wantErr: "API version must be one of"},
+		{
+			name:    "version 3 only command",
+			args:    []string{"--api-version=4", "list", "-p"},
+			wantErr: `"go-tmdb-cli list" only supports --api-version=3`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			dir := t.TempDir()
+			os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("api_key: valid_api_key"), 0o600)
+			root := newRootCmd("config.yaml")
+			// Act
+			c, _, err := executeCommandC(root, append([]string{"--config-dir", dir}, tc.args...)...)
+			// Assert
+			if tc.wantErr != "" {
+				assertNotNil(t, err)
+				assertContains(t, err.Error(), []string{tc.wantErr})
+				return
+			}
+			assertNoError(t, err)
+			deps, ok := c.Context().Value(dependencies).(*Dependencies)
+			if !ok {
+				t.Fatal("retrieve dependencies from context")
+			}
+			if deps.URLBuilder.BaseURL != tc.wantBaseURL {
+				t.Errorf("expected base URL %q, but got %q", tc.wantBaseURL, deps.URLBuilder.BaseURL)
+			}
+		})
+	}
+}
+
+func TestIntegrationRootCmd_APIKeyFile(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("# api key read from a file"), 0o600)
+	keyFile := filepath.Join(dir, "tmdb")
+	os.WriteFile(keyFile, []byte("secret_api_key\n"), 0o600)
+	root := newRootCmd("config.yaml")
+	// Act
+	_, err := executeCommand(root, "--config-dir", dir, "--api-key-file", keyFile)
+	// Assert
+	assertNoError(t, err)
+	deps, ok := root.Context().Value(dependencies).(*Dependencies)
+	if !ok {
+		t.Fatal("retrieve dependencies from context")
+	}
+	if deps.Client.APIKey != "secret_api_key" {
+		t.Errorf("expected API key %q, but got %q", "secret_api_key", deps.Client.APIKey)
+	}
+}
+
+func TestIntegrationRootCmd_Locale(t *testing.T) {
+	testCases := []struct {
+		name    string
+		locale  string
+		want    string
+		wantErr bool
+	}{
+		{name: "canonical locale", locale: "pt-br", want: "pt-BR"},
+		{name: "invalid locale", locale: "not a locale", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			dir := t.TempDir()
+			os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("api_key: valid_api_key"), 0o600)
+			root := newRootCmd("config.yaml")
+			// Act
+			_, err := executeCommand(root, "--config-dir", dir, "--locale", tc.locale)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				return
+			}
+			assertNoError(t, err)
+			deps, ok := root.Context().Value(dependencies).(*Dependencies)
+			if !ok {
+				t.Fatal("retrieve dependencies from context")
+			}
+			if deps.Client.Locale != tc.want {
+				t.Errorf("expected locale %q, but got %q", tc.want, deps.Client.Locale)
+			}
+		})
+	}
+}
+
 func TestIntegrationListCmd(t *testing.T) {
 	testCases := []struct {
 		name          string
@@ -147,12 +286,191 @@ func TestIntegrationListCmd(t *testing.T) {
 	}
 }
 
+func TestIntegrationListCmd_MaxItems(t *testing.T) {
+	testCases := []struct {
+		name      string
+		flags     string
+		wantCount int
+		wantErr   bool
+	}{
+		{name: "default single page", wantCount: 20},
+		{name: "two pages", flags: "-m=40", wantCount: 40},
+		{name: "page cap stricter than items", flags: "-m=40 --max-pages=1", wantCount: 20},
+		{name: "items stricter than page cap", flags: "-m=30 --max-pages=2", wantCount: 30},
+		{name: "negative page cap", flags: "--max-pages=-1", wantErr: true},
+		{name: "zero items", flags: "-m=0", wantErr: true},
+		{name: "not an integer", flags: "-m=many", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			args := []string{"list", "-p", "--format=json"}
+			if tc.flags != "" {
+				args = append(args, strings.Fields(tc.flags)...)
+			}
+			// Act
+			got, err := executeCommand(root, args...)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				return
+			}
+			assertNoError(t, err)
+			var decoded movies
+			json.Unmarshal([]byte(got), &decoded)
+			if len(decoded) != tc.wantCount {
+				t.Errorf("expected %d movies, but got %d", tc.wantCount, len(decoded))
+			}
+		})
+	}
+}
+
+func TestIntegrationListCmd_Categories(t *testing.T) {
+	testCases := []struct {
+		name         string
+		args         []string
+		wantSections []string
+		wantPaths    []string
+		wantErr      string
+	}{
+		{
+			name:         "two categories",
+			args:         []string{"--top", "--now"},
+			wantSections: []string{"Now Playing", "Top Rated"},
+			wantPaths:    []string{"/movie/now_playing", "/movie/top_rated"},
+		},
+		{
+			name:      "one category without heading",
+			args:      []string{"--top"},
+			wantPaths: []string{"/movie/top_rated"},
+		},
+		{
+			name:    "two categories as json",
+			args:    []string{"--now", "--top", "--format=json"},
+			wantErr: "several lists can only be shown as tables",
+		},
+		{name: "no category", args: []string{"--format=json"}, wantErr: "select a list"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			got, err := executeCommand(root, append([]string{"list"}, tc.args...)...)
+			// Assert
+			if tc.wantErr != "" {
+				assertNotNil(t, err)
+				assertContains(t, fmt.Sprint(err), []string{tc.wantErr})
+				return
+			}
+			assertNoError(t, err)
+			if gotPaths := ts.requestedPaths(); !slices.Equal(gotPaths, tc.wantPaths) {
+				t.Errorf("expected paths %v, but got %v", tc.wantPaths, gotPaths)
+			}
+			if len(tc.wantSections) == 0 {
+				assertNotContains(t, got, []string{"Top Rated\n"})
+				return
+			}
+			// Each section is a heading followed by its own table
+			sections := make([]int, len(tc.wantSections))
+			for i, heading := range tc.wantSections {
+				sections[i] = strings.Index(got, heading+"\n")
+				if sections[i] < 0 {
+					t.Fatalf("expected the %q heading, but got:\n%s", heading, got)
+				}
+			}
+			if !slices.IsSorted(sections) {
+				t.Errorf("expected the sections in order %v, but got:\n%s", tc.wantSections, got)
+			}
+			if n := strings.Count(got, "ORIGINAL TITLE"); n != len(tc.wantSections) {
+				t.Errorf("expected %d tables, but got %d", len(tc.wantSections), n)
+			}
+		})
+	}
+}
+
+func TestIntegrationListCmd_SourceColumn(t *testing.T) {
+	// Arrange
+	ts := newFakeTMDBServer(t)
+	root := newMockRootCmd(t, ts.URL)
+	// Act
+	got, err := executeCommand(root, "list", "--now", "--top", "-m=2", "--source-column", "--format=json")
+	// Assert
+	assertNoError(t, err)
+	var decoded movies
+	if err := json.Unmarshal([]byte(got), &decoded); err != nil {
+		t.Fatalf("unmarshal JSON output: %v", err)
+	}
+	assertMovieIDs(t, []int{1, 2}, decoded)
+	for _, m := range decoded {
+		if m.Source != "now_playing, top_rated" {
+			t.Errorf("expected movie %d from both lists, but got source %q", m.ID, m.Source)
+		}
+	}
+}
+
+func TestIntegrationListCmd_AlsoDiscover(t *testing.T) {
+	testCases := []struct {
+		name       string
+		args       []string
+		wantPath   string
+		wantSortBy string
+		wantGenres string
+		wantErr    bool
+	}{
+		{name: "plain list", args: []string{"-p"}, wantPath: "/movie/popular"},
+		{
+			name:       "popular with filters",
+			args:       []string{"-p", "--also-discover", "-g=horror"},
+			wantPath:   "/discover/movie",
+			wantSortBy: "popularity.desc",
+			wantGenres: "27",
+		},
+		{
+			name:       "top rated without filters",
+			args:       []string{"-t", "--also-discover"},
+			wantPath:   "/discover/movie",
+			wantSortBy: "vote_average.desc",
+		},
+		{name: "unmapped category", args: []string{"-u", "--also-discover"}, wantErr: true},
+		{name: "filters without also discover", args: []string{"-p", "-g=horror"}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			_, err := executeCommand(root, append([]string{"list"}, tc.args...)...)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				return
+			}
+			assertNoError(t, err)
+			if got := ts.lastPath(); got != tc.wantPath {
+				t.Errorf("expected path %q, but got %q", tc.wantPath, got)
+			}
+			if got := ts.lastQuery().Get("sort_by"); got != tc.wantSortBy {
+				t.Errorf("expected sort_by %q, but got %q", tc.wantSortBy, got)
+			}
+			if got := ts.lastQuery().Get("with_genres"); got != tc.wantGenres {
+				t.Errorf("expected with_genres %q, but got %q", tc.wantGenres, got)
+			}
+		})
+	}
+}
+
 func TestIntegrationDiscoverCmd(t *testing.T) {
 	testCases := []struct {
 		name          string
 		flag          string
 		wantHelp      bool
 		wantNoResults bool
+		wantCounts    bool
 		wantFetchErr  bool
 		wantErr       bool
 	}{
@@ -172,6 +490,7 @@ func TestIntegrationDiscoverCmd(t *testing.T) {
 		{name: "valid one genre", flag: "--without-genres=drama"},
 		{name: "valid many genres", flag: "--without-genres=comedy,horror,science-fiction"},
 		{name: "valid sort", flag: "--sort=average,desc"},
+		{name: "count by year", flag: "--count-by-year", wantCounts: true},
 		{name: "help", wantHelp: true},
 		{name: "year error", flag: "--year=1", wantErr: true},                           // Parsing error
 		{name: "average error", flag: "--average=11", wantErr: true},                    // Above max average
@@ -234,6 +553,9 @@ func TestIntegrationDiscoverCmd(t *testing.T) {
 			} else if tc.wantNoResults {
 				assertNoError(t, err)
 				assertPrintNoResults(t, got)
+			} else if tc.wantCounts {
+				assertNoError(t, err)
+				assertContains(t, got, []string{"YEAR", "COUNT", "2023", "2024"})
 			} else {
 				assertNoError(t, err)
 				assertContains(t, got, []string{"ORIGINAL TITLE", "RELEASE DATE", "TITLE", "AVERAGE", "VOTES"})
@@ -242,6 +564,2958 @@ func TestIntegrationDiscoverCmd(t *testing.T) {
 	}
 }
 
+func TestIntegrationDiscoverCmd_ExcludeIDs(t *testing.T) {
+	idsFile := filepath.Join(t.TempDir(), "seen.txt")
+	os.WriteFile(idsFile, []byte("3\n\n4\n"), 0o600)
+	testCases := []struct {
+		name        string
+		args        []string
+		wantAbsent  []string
+		wantPresent []string
+		wantErr     bool
+	}{
+		{
+			name:        "inline IDs",
+			args:        []string{"--exclude-ids=1,2"},
+			wantAbsent:  []string{"Epic Journey Begins", "Rise of the Heroes"},
+			wantPresent: []string{"Clash of Titans"},
+		},
+		{
+			name:        "IDs file",
+			args:        []string{"--exclude-ids-file", idsFile},
+			wantAbsent:  []string{"Clash of Titans", "The Quest for Knowledge"},
+			wantPresent: []string{"Epic Journey Begins"},
+		},
+		{
+			name:        "inline IDs and file",
+			args:        []string{"--exclude-ids=1", "--exclude-ids-file", idsFile},
+			wantAbsent:  []string{"Epic Journey Begins", "Clash of Titans"},
+			wantPresent: []string{"Rise of the Heroes"},
+		},
+		{name: "invalid inline ID", args: []string{"--exclude-ids=abc"}, wantErr: true},
+		{name: "missing IDs file", args: []string{"--exclude-ids-file=missing.txt"}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			got, err := executeCommand(root, append([]string{"discover"}, tc.args...)...)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				return
+			}
+			assertNoError(t, err)
+			assertContains(t, got, tc.wantPresent)
+			assertNotContains(t, got, tc.wantAbsent)
+		})
+	}
+}
+
+func TestIntegrationDiscoverCmd_GenresMatch(t *testing.T) {
+	testCases := []struct {
+		name          string
+		configDefault string
+		args          []string
+		want          string
+	}{
+		{name: "default matches all", args: []string{"-g=drama,comedy"}, want: "18,35"},
+		{name: "config default", configDefault: "any", args: []string{"-g=drama,comedy"}, want: "18|35"},
+		{
+			name:          "flag overrides config default",
+			configDefault: "any",
+			args:          []string{"-g=drama,comedy", "--genres-match=all"},
+			want:          "18,35",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			viper.Set("genres_default_match", tc.configDefault)
+			t.Cleanup(func() { viper.Set("genres_default_match", "") })
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			_, err := executeCommand(root, append([]string{"discover"}, tc.args...)...)
+			// Assert
+			assertNoError(t, err)
+			if got := ts.lastQuery().Get("with_genres"); got != tc.want {
+				t.Errorf("expected with_genres %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestIntegrationDiscoverCmd_AnyGenre(t *testing.T) {
+	for _, wildcard := range []string{"any", "*"} {
+		t.Run(wildcard, func(t *testing.T) {
+			// Arrange
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			_, err := executeCommand(root, "discover", "--genres="+wildcard, "-y=2000")
+			// Assert
+			assertNoError(t, err)
+			if query := ts.lastQuery(); query.Has("with_genres") {
+				t.Errorf("expected no with_genres, but got %q", query.Get("with_genres"))
+			}
+		})
+	}
+}
+
+func TestIntegrationDiscoverCmd_Preview(t *testing.T) {
+	totals := map[string]int{"18": 9000, "35": 7000, "16|878": 800, "18,35": 1200, "18,16|878": 3, "18|35": 14800}
+	testCases := []struct {
+		name        string
+		args        []string
+		want        []string
+		wantQueries []string
+		wantErr     string
+	}{
+		{
+			name:        "each genre and combined",
+			args:        []string{"-g=drama,comedy"},
+			want:        []string{"drama", "9000", "comedy", "7000", "drama,comedy", "1200"},
+			wantQueries: []string{"18", "35", "18,35"},
+		},
+		{
+			name:        "alternatives kept together",
+			args:        []string{"-g=drama,animation|science-fiction"},
+			want:        []string{"animation|science-fiction", "800", "drama,animation|science-fiction", "3"},
+			wantQueries: []string{"18", "16|878", "18,16|878"},
+		},
+		{
+			name:        "matching any",
+			args:        []string{"-g=drama,comedy", "--genres-match=any"},
+			want:        []string{"drama,comedy", "14800"},
+			wantQueries: []string{"18", "35", "18|35"},
+		},
+		{name: "single genre", args: []string{"-g=drama"}, want: []string{"9000"}, wantQueries: []string{"18"}},
+		{name: "without genres", args: []string{"-y=2000"}, wantErr: "--preview requires --genres"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			var mu sync.Mutex
+			var gotQueries []string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requireAPIKey(t, w, r)
+				genres := r.URL.Query().Get("with_genres")
+				mu.Lock()
+				gotQueries = append(gotQueries, genres)
+				mu.Unlock()
+				byt, _ := json.Marshal(tmdbResponse{Page: 1, Results: movies{}, TotalPages: 1, TotalResults: totals[genres]})
+				w.Write(byt)
+			}))
+			t.Cleanup(ts.Close)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			got, err := executeCommand(root, append([]string{"discover", "--preview"}, tc.args...)...)
+			// Assert
+			if tc.wantErr != "" {
+				assertNotNil(t, err)
+				assertContains(t, fmt.Sprint(err), []string{tc.wantErr})
+				return
+			}
+			assertNoError(t, err)
+			assertContains(t, got, tc.want)
+			if !slices.Equal(gotQueries, tc.wantQueries) {
+				t.Errorf("expected the genres queries %q, but got %q", tc.wantQueries, gotQueries)
+			}
+		})
+	}
+}
+
+func TestIntegrationDiscoverCmd_NoSortStable(t *testing.T) {
+	// Arrange
+	const pages, perPage = 3, 20
+	var results movies
+	for id := 1; id <= pages*perPage; id++ {
+		results = append(results, movie{ID: id, Title: fmt.Sprintf("Movie %d", id)})
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 2 {
+			time.Sleep(20 * time.Millisecond) // Answer page 3 first
+		}
+		res := tmdbResponse{
+			Page:         page,
+			Results:      results[(page-1)*perPage : page*perPage],
+			TotalPages:   pages,
+			TotalResults: len(results),
+		}
+		byt, _ := json.Marshal(res)
+		w.Write(byt)
+	}))
+	t.Cleanup(ts.Close)
+	var outputs []string
+	for range 3 {
+		root := newMockRootCmd(t, ts.URL)
+		// Act
+		output, err := executeCommand(root, "discover", "-g=drama", "-m=60", "--format=csv", "--fields=id")
+		// Assert
+		assertNoError(t, err)
+		outputs = append(outputs, output)
+	}
+	want := []string{"ID"}
+	for _, m := range results {
+		want = append(want, strconv.Itoa(m.ID))
+	}
+	wantOutput := strings.Join(want, "\n")
+	for _, output := range outputs {
+		if strings.TrimSpace(output) != wantOutput {
+			t.Fatalf("expected TMDB's order %q, but got %q", wantOutput, output)
+		}
+	}
+}
+
+func TestIntegrationDiscoverCmd_ServerSort(t *testing.T) {
+	testCases := []struct {
+		name        string
+		args        []string
+		wantSortBy  string
+		wantWarning bool
+	}{
+		{name: "mapped field", args: []string{"-s=average,desc", "--server-sort"}, wantSortBy: "vote_average.desc"},
+		{name: "client sort only", args: []string{"-s=average,desc"}},
+		{
+			name:        "unsupported field",
+			args:        []string{"-s=runtime,desc", "--fetch-runtime", "--server-sort"},
+			wantWarning: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			_, _, stderr, err := executeCommandStreams(root, append([]string{"discover", "-g=drama"}, tc.args...)...)
+			// Assert
+			assertNoError(t, err)
+			var sortBys []string
+			for i, path := range ts.requestedPaths() {
+				if path == "/discover/movie" {
+					sortBys = append(sortBys, ts.queries[i].Get("sort_by"))
+				}
+			}
+			if len(sortBys) == 0 || sortBys[0] != tc.wantSortBy {
+				t.Errorf("expected sort_by %q, but got %q", tc.wantSortBy, sortBys)
+			}
+			if tc.wantWarning {
+				assertContains(t, stderr, []string{"warning: TMDB can't sort by runtime"})
+			} else {
+				assertNotContains(t, stderr, []string{"warning"})
+			}
+		})
+	}
+}
+
+func TestIntegrationDiscoverCmd_SortBeforeTrim(t *testing.T) {
+	// The best rated movie of both pages is the 29th one
+	globalTop := "The Legend of the Dragon"
+	testCases := []struct {
+		name       string
+		args       []string
+		wantGlobal bool
+	}{
+		{name: "naive sort of the first items", args: []string{"-m=5", "-s=average,desc"}},
+		{
+			name:       "sort before trim",
+			args:       []string{"-m=5", "-s=average,desc", "--sort-before-trim", "--oversample=8"},
+			wantGlobal: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			got, err := executeCommandStdout(root, append([]string{"discover", "--format=yaml"}, tc.args...)...)
+			// Assert
+			assertNoError(t, err)
+			var decoded movies
+			yaml.Unmarshal([]byte(got), &decoded)
+			if len(decoded) != 5 {
+				t.Fatalf("expected 5 movies, but got %d", len(decoded))
+			}
+			if tc.wantGlobal {
+				assertContains(t, got, []string{globalTop})
+			} else {
+				assertNotContains(t, got, []string{globalTop})
+			}
+		})
+	}
+}
+
+func TestIntegrationDiscoverCmd_PrintStats(t *testing.T) {
+	// Arrange
+	ts := newFakeTMDBServer(t)
+	root := newMockRootCmd(t, ts.URL)
+	// Act
+	_, _, stderr, err := executeCommandStreams(root, "discover", "-m=25", "--print-stats")
+	// Assert
+	assertNoError(t, err)
+	pages := len(ts.requestedPages())
+	if pages == 0 {
+		t.Fatal("expected requests to the server")
+	}
+	assertContains(t, stderr, []string{fmt.Sprintf("stats: %d requests, 0 retries", pages)})
+}
+
+func TestIntegrationDiscoverCmd_MoreResultsHint(t *testing.T) {
+	const hint = "more results available (showing %d of %d); increase --max-items to see more."
+	testCases := []struct {
+		name         string
+		args         []string
+		totalResults int
+		wantOutput   string
+		wantHint     string
+	}{
+		{
+			name:         "more results",
+			args:         []string{"-m=2"},
+			totalResults: 4312,
+			wantOutput:   "Epic Journey Begins",
+			wantHint:     fmt.Sprintf(hint, 2, 4312),
+		},
+		{name: "all results shown", args: []string{"-m=2"}, totalResults: 2, wantOutput: "Epic Journey Begins"},
+		{name: "quiet", args: []string{"-m=2", "--quiet"}, totalResults: 4312, wantOutput: "Epic Journey Begins"},
+		{
+			name:         "max items at the API limit",
+			args:         []string{fmt.Sprintf("-m=%d", APIMaxItems)},
+			totalResults: 20000,
+			wantOutput:   "Epic Journey Begins",
+		},
+		{name: "random", args: []string{"-m=2", "--random=2"}, totalResults: 4312, wantOutput: "Epic Journey Begins"},
+		{name: "group by", args: []string{"-m=2", "--group-by=year"}, totalResults: 4312, wantOutput: "2023"},
+		{name: "count by year", args: []string{"-m=2", "--count-by-year"}, totalResults: 4312, wantOutput: "2023"},
+		{
+			name:         "since last run",
+			args:         []string{"-m=2", "--since-last-run"},
+			totalResults: 4312,
+			wantOutput:   "Epic Journey Begins",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requireAPIKey(t, w, r)
+				res := fakeEmptyRes
+				if r.URL.Query().Get("page") == "1" {
+					res = tmdbResponse{Page: 1, Results: fakeMovieList[:2], TotalPages: 1, TotalResults: tc.totalResults}
+				}
+				byt, _ := json.Marshal(res)
+				w.Write(byt)
+			}))
+			t.Cleanup(ts.Close)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			_, stdout, stderr, err := executeCommandStreams(root, append([]string{"discover"}, tc.args...)...)
+			// Assert
+			assertNoError(t, err)
+			assertContains(t, stdout, []string{tc.wantOutput})
+			if tc.wantHint == "" {
+				assertNotContains(t, stderr, []string{"more results available"})
+			} else {
+				assertContains(t, stderr, []string{tc.wantHint})
+			}
+		})
+	}
+}
+
+func TestIntegrationDiscoverCmd_SinceLastRun(t *testing.T) {
+	// Arrange
+	previous := nowFunc
+	nowFunc = func() time.Time { return time.Date(2025, 3, 14, 12, 0, 0, 0, yearLocation) }
+	t.Cleanup(func() { nowFunc = previous })
+	ts := newFakeTMDBServer(t)
+	root := newMockRootCmd(t, ts.URL)
+	args := []string{"discover", "-g=horror", "--since-last-run"}
+	// Act
+	_, firstErr := executeCommand(root, args...)
+	firstGTE := ts.lastQuery().Get("primary_release_date.gte")
+	_, secondErr := executeCommand(root, args...)
+	secondGTE := ts.lastQuery().Get("primary_release_date.gte")
+	_, yearErr := executeCommand(root, "discover", "-y=2000", "--since-last-run")
+	// Assert
+	assertNoError(t, firstErr)
+	assertNoError(t, secondErr)
+	assertNotNil(t, yearErr)
+	if firstGTE != "" {
+		t.Errorf("expected no release date boundary on the first run, but got %q", firstGTE)
+	}
+	if secondGTE != "2025-03-14" {
+		t.Errorf("expected release date boundary %q, but got %q", "2025-03-14", secondGTE)
+	}
+}
+
+func TestIntegrationDiscoverCmd_GenresFile(t *testing.T) {
+	testCases := []struct {
+		name        string
+		content     string
+		args        []string
+		wantParam   string
+		wantGenres  string
+		wantErr     string
+		missingFile bool
+	}{
+		{
+			name:       "newline separated",
+			content:    "animation|science-fiction\ndrama\n\n",
+			args:       []string{"discover", "-g=@FILE"},
+			wantParam:  "with_genres",
+			wantGenres: "16|878,18",
+		},
+		{
+			name:       "commas and newlines",
+			content:    "comedy, action\r\nhorror\n",
+			args:       []string{"discover", "-g=@FILE"},
+			wantParam:  "with_genres",
+			wantGenres: "35,28,27",
+		},
+		{
+			name:       "without genres",
+			content:    "horror\nwestern\n",
+			args:       []string{"discover", "-w=@FILE"},
+			wantParam:  "without_genres",
+			wantGenres: "27,37",
+		},
+		{
+			name:       "list also discover",
+			content:    "horror\n",
+			args:       []string{"list", "-p", "--also-discover", "-g=@FILE"},
+			wantParam:  "with_genres",
+			wantGenres: "27",
+		},
+		{
+			name:    "unknown genre",
+			content: "drama\ndramedy\n",
+			args:    []string{"discover", "-g=@FILE"},
+			wantErr: "genre must be one of",
+		},
+		{name: "missing file", args: []string{"discover", "-g=@FILE"}, missingFile: true, wantErr: "read the genres file"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			path := filepath.Join(t.TempDir(), "genres.txt")
+			if !tc.missingFile {
+				os.WriteFile(path, []byte(tc.content), 0o600)
+			}
+			args := make([]string, 0, len(tc.args))
+			for _, arg := range tc.args {
+				args = append(args, strings.ReplaceAll(arg, "FILE", path))
+			}
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			_, err := executeCommand(root, args...)
+			// Assert
+			if tc.wantErr != "" {
+				assertNotNil(t, err)
+				assertContains(t, err.Error(), []string{tc.wantErr})
+				return
+			}
+			assertNoError(t, err)
+			if got := ts.lastQuery().Get(tc.wantParam); got != tc.wantGenres {
+				t.Errorf("expected %s %q, but got %q", tc.wantParam, tc.wantGenres, got)
+			}
+		})
+	}
+}
+
+func TestIntegrationDiscoverCmd_TMDBAliases(t *testing.T) {
+	testCases := []struct {
+		name     string
+		friendly []string
+		alias    []string
+	}{
+		{name: "language", friendly: []string{"-l=fr"}, alias: []string{"--with_original_language=fr"}},
+		{name: "year", friendly: []string{"-y=2020"}, alias: []string{"--primary_release_year=2020"}},
+		{name: "average lower bound", friendly: []string{"-a=7,gte"}, alias: []string{"--vote_average.gte=7"}},
+		{
+			name:     "average range",
+			friendly: []string{"-a=6.5,9"},
+			alias:    []string{"--vote_average.gte=6.5", "--vote_average.lte=9"},
+		},
+		{name: "votes upper bound", friendly: []string{"-v=500,lte"}, alias: []string{"--vote_count.lte=500"}},
+		{
+			name:     "runtime range",
+			friendly: []string{"--runtime=90,120"},
+			alias:    []string{"--with_runtime.gte=90", "--with_runtime.lte=120"},
+		},
+		{
+			name:     "genre IDs",
+			friendly: []string{"-g=animation|science-fiction,drama"},
+			alias:    []string{"--with_genres=16|878,18"},
+		},
+		{name: "without genres", friendly: []string{"-w=horror"}, alias: []string{"--without_genres=27"}},
+		{name: "friendly flag first", friendly: []string{"-l=fr"}, alias: []string{"-l=fr", "--with_original_language=de"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			queries := make([]url.Values, 0, 2)
+			for _, args := range [][]string{tc.friendly, tc.alias} {
+				ts := newFakeTMDBServer(t)
+				root := newMockRootCmd(t, ts.URL)
+				// Act
+				_, err := executeCommand(root, append([]string{"discover", "-m=5"}, args...)...)
+				// Assert
+				assertNoError(t, err)
+				queries = append(queries, ts.lastQuery())
+			}
+			if !reflect.DeepEqual(queries[0], queries[1]) {
+				t.Errorf("expected the query %v, but got %v", queries[0], queries[1])
+			}
+		})
+	}
+}
+
+func TestIntegrationDiscoverCmd_TMDBAliasesHidden(t *testing.T) {
+	// Arrange
+	root := newMockRootCmd(t, "")
+	// Act
+	got, err := executeCommand(root, "discover", "--help")
+	// Assert
+	assertNoError(t, err)
+	assertNotContains(t, got, []string{"with_original_language", "vote_average.gte"})
+}
+
+func TestIntegrationDiscoverCmd_DateFlags(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		wantErr []string
+	}{
+		{
+			name:    "year and since last run",
+			args:    []string{"-y=2000", "--since-last-run"},
+			wantErr: []string{"--year and --since-last-run are mutually exclusive", "--year for", "--since-last-run for"},
+		},
+		{
+			name:    "positional year and since last run",
+			args:    []string{"drama", "2000", "--since-last-run"},
+			wantErr: []string{"--year and --since-last-run are mutually exclusive"},
+		},
+		{
+			name:    "year and its TMDB alias",
+			args:    []string{"-y=2000", "--primary_release_year=2001"},
+			wantErr: []string{"--year and --primary_release_year are mutually exclusive"},
+		},
+		{name: "year alone", args: []string{"-y=2000"}},
+		{name: "since last run alone", args: []string{"-g=horror", "--since-last-run"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			_, err := executeCommand(root, append([]string{"discover"}, tc.args...)...)
+			// Assert
+			if tc.wantErr != nil {
+				assertNotNil(t, err)
+				assertContains(t, fmt.Sprint(err), tc.wantErr)
+				if len(ts.requestedPaths()) != 0 {
+					t.Errorf("expected no request, but got %v", ts.requestedPaths())
+				}
+				return
+			}
+			assertNoError(t, err)
+		})
+	}
+}
+
+func TestUnitDumpMovies(t *testing.T) {
+	// Arrange
+	dir := filepath.Join(t.TempDir(), "out")
+	repeated := fakeMovieList[0]
+	repeated.Title = "Epic Journey Begins Again"
+	m := movies{fakeMovieList[0], fakeMovieList[1], repeated}
+	// Act
+	err := dumpMovies(dir, m)
+	// Assert
+	assertNoError(t, err)
+	var index []dumpEntry
+	byt, _ := os.ReadFile(filepath.Join(dir, dumpIndexFile))
+	assertNoError(t, json.Unmarshal(byt, &index))
+	wantFiles := []string{"1.json", "2.json", "1-2.json"}
+	if len(index) != len(wantFiles) {
+		t.Fatalf("expected %d index entries, but got %d", len(wantFiles), len(index))
+	}
+	for i, entry := range index {
+		if entry.File != wantFiles[i] || entry.ID != m[i].ID || entry.Title != m[i].Title {
+			t.Errorf("expected entry %d for %q in %s, but got %+v", m[i].ID, m[i].Title, wantFiles[i], entry)
+		}
+		var dumped movie
+		byt, err := os.ReadFile(filepath.Join(dir, entry.File))
+		assertNoError(t, err)
+		assertNoError(t, json.Unmarshal(byt, &dumped))
+		if dumped.Title != m[i].Title {
+			t.Errorf("expected %s to hold %q, but got %q", entry.File, m[i].Title, dumped.Title)
+		}
+	}
+}
+
+func TestIntegrationDiscoverCmd_Dump(t *testing.T) {
+	// Arrange
+	ts := newFakeTMDBServer(t)
+	root := newMockRootCmd(t, ts.URL)
+	dir := filepath.Join(t.TempDir(), "out")
+	// Act
+	_, _, stderr, err := executeCommandStreams(root, "discover", "-g=drama", "-m=2", "--dump", dir)
+	// Assert
+	assertNoError(t, err)
+	assertContains(t, stderr, []string{"dumped 2 movies to " + dir})
+	entries, _ := os.ReadDir(dir)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	wantNames := []string{"1.json", "2.json", dumpIndexFile}
+	if !slices.Equal(names, wantNames) {
+		t.Errorf("expected files %v, but got %v", wantNames, names)
+	}
+}
+
+func TestIntegrationAbsentResults(t *testing.T) {
+	testCases := []struct {
+		name          string
+		args          []string
+		wantNoResults bool
+	}{
+		{name: "discover table", args: []string{"discover", "-g=drama"}, wantNoResults: true},
+		{name: "discover json", args: []string{"discover", "-g=drama", "--format=json"}},
+		{name: "discover yaml fields", args: []string{"discover", "-g=drama", "--format=yaml", "--fields=id"}},
+		{name: "discover csv", args: []string{"discover", "-g=drama", "--format=csv"}},
+		{name: "discover count by year", args: []string{"discover", "-g=drama", "--count-by-year"}, wantNoResults: true},
+		{name: "list table", args: []string{"list", "-p"}, wantNoResults: true},
+		{name: "list categories", args: []string{"list", "-p", "-t"}, wantNoResults: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			outputs := make(map[string]string)
+			for body, res := range map[string]string{
+				"absent": `{"page": 1, "total_pages": 0, "total_results": 0}`,
+				"null":   `{"page": 1, "results": null, "total_pages": 0, "total_results": 0}`,
+				"empty":  `{"page": 1, "results": [], "total_pages": 0, "total_results": 0}`,
+			} {
+				ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte(res))
+				}))
+				root := newMockRootCmd(t, ts.URL)
+				// Act
+				output, err := executeCommand(root, tc.args...)
+				ts.Close()
+				// Assert
+				assertNoError(t, err)
+				outputs[body] = output
+			}
+			if outputs["absent"] != outputs["empty"] || outputs["null"] != outputs["empty"] {
+				t.Errorf("expected the same output without results, but got %q", outputs)
+			}
+			if tc.wantNoResults {
+				assertContains(t, outputs["absent"], []string{"No results available. Please try another query."})
+			}
+		})
+	}
+}
+
+func TestIntegrationDiscoverCmd_DedupReport(t *testing.T) {
+	testCases := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "shifted pages", args: []string{"-m=40"}, want: "removed 2 duplicate entries (IDs: 19, 20)"},
+		{
+			name: "shifted pages and titles",
+			args: []string{"-m=40", "--dedupe-by=title"},
+			want: "removed 3 duplicate entries (IDs: 19, 20, 22)",
+		},
+		{name: "no duplicates", args: []string{"-m=10"}, want: "removed 0 duplicate entries"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			retitled := fakeMovieList[21]
+			retitled.Title = fakeMovieList[0].Title
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				res := fakeResPage1
+				if r.URL.Query().Get("page") == "2" {
+					page2 := append(movies{}, fakeMovieList[18:20]...)
+					page2 = append(page2, fakeMovieList[20], retitled)
+					res = tmdbResponse{Page: 2, Results: page2, TotalPages: 2, TotalResults: fakeResPage1.TotalResults}
+				}
+				byt, _ := json.Marshal(res)
+				w.Write(byt)
+			}))
+			t.Cleanup(ts.Close)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			_, _, stderr, err := executeCommandStreams(root,
+				append([]string{"discover", "-g=drama", "--dedup-report"}, tc.args...)...)
+			// Assert
+			assertNoError(t, err)
+			assertContains(t, stderr, []string{tc.want})
+		})
+	}
+}
+
+func TestIntegrationDiscoverCmd_OnlyGenres(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		wantIDs []int
+		wantErr string
+	}{
+		{name: "all movies", args: []string{"-g=drama"}, wantIDs: []int{1, 2, 3, 4}},
+		{name: "drama only", args: []string{"-g=drama", "--only-genres=drama"}, wantIDs: []int{1, 4}},
+		{
+			name:    "drama or romance only",
+			args:    []string{"-g=drama", "--only-genres=drama,romance"},
+			wantIDs: []int{1, 2, 4},
+		},
+		{name: "unknown genre", args: []string{"-g=drama", "--only-genres=dramedy"}, wantErr: "genre must be one of"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			withGenres := movies{fakeMovieList[0], fakeMovieList[1], fakeMovieList[2], fakeMovieList[3]}
+			withGenres[0].GenreIDs = []int{18}
+			withGenres[1].GenreIDs = []int{18, 10749}
+			withGenres[2].GenreIDs = []int{18, 27}
+			withGenres[3].GenreIDs = nil
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				byt, _ := json.Marshal(tmdbResponse{Page: 1, Results: withGenres, TotalPages: 1, TotalResults: 4})
+				w.Write(byt)
+			}))
+			t.Cleanup(ts.Close)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			got, err := executeCommandStdout(root, append([]string{"discover", "--format=json"}, tc.args...)...)
+			// Assert
+			if tc.wantErr != "" {
+				assertNotNil(t, err)
+				assertContains(t, err.Error(), []string{tc.wantErr})
+				return
+			}
+			assertNoError(t, err)
+			var decoded movies
+			json.Unmarshal([]byte(got), &decoded)
+			assertMovieIDs(t, tc.wantIDs, decoded)
+		})
+	}
+}
+
+func TestIntegrationDiscoverCmd_HasPoster(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		wantIDs []int
+	}{
+		{name: "all movies", args: []string{"-l=fr"}, wantIDs: []int{1, 2, 3, 4}},
+		{name: "with posters only", args: []string{"-l=fr", "--has-poster"}, wantIDs: []int{1, 3}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			withPosters := movies{fakeMovieList[0], fakeMovieList[1], fakeMovieList[2], fakeMovieList[3]}
+			withPosters[0].PosterPath = "/first.jpg"
+			withPosters[2].PosterPath = "/third.jpg"
+			withPosters[3].PosterPath = " "
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requireAPIKey(t, w, r)
+				byt, _ := json.Marshal(tmdbResponse{Page: 1, Results: withPosters, TotalPages: 1, TotalResults: 4})
+				w.Write(byt)
+			}))
+			t.Cleanup(ts.Close)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			got, err := executeCommandStdout(root, append([]string{"discover", "--format=json"}, tc.args...)...)
+			// Assert
+			assertNoError(t, err)
+			var decoded movies
+			json.Unmarshal([]byte(got), &decoded)
+			assertMovieIDs(t, tc.wantIDs, decoded)
+		})
+	}
+}
+
+func TestIntegrationDiscoverCmd_GroupBy(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr string
+	}{
+		{name: "by decade", args: []string{"--group-by=decade"}, want: []string{"DECADE", "COUNT", "AVERAGE", "2020s"}},
+		{name: "by year", args: []string{"--group-by=Year"}, want: []string{"YEAR", "2023"}},
+		{name: "localized average", args: []string{"--group-by=decade", "--thousands-sep", "--locale=de"}, want: []string{"8,5"}},
+		{name: "invalid field", args: []string{"--group-by=title"}, wantErr: "group by must be one of"},
+		{
+			name:    "with count by year",
+			args:    []string{"--group-by=year", "--count-by-year"},
+			wantErr: "mutually exclusive",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			got, err := executeCommand(root, append([]string{"discover", "-l=fr"}, tc.args...)...)
+			// Assert
+			if tc.wantErr != "" {
+				assertNotNil(t, err)
+				assertContains(t, fmt.Sprint(err), []string{tc.wantErr})
+				return
+			}
+			assertNoError(t, err)
+			assertContains(t, got, tc.want)
+		})
+	}
+}
+
+func TestIntegrationDiscoverCmd_Random(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		wantLen int
+		wantErr string
+	}{
+		{name: "sample size", args: []string{"--random=3"}, wantLen: 3},
+		{name: "seeded sample size", args: []string{"--random=3", "--seed=7"}, wantLen: 3},
+		{name: "more than fetched", args: []string{"--random=21"}, wantErr: "fetch more with --max-items"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			got, err := executeCommand(root, append([]string{"discover", "-l=fr", "--format=json"}, tc.args...)...)
+			// Assert
+			if tc.wantErr != "" {
+				assertNotNil(t, err)
+				assertContains(t, fmt.Sprint(err), []string{tc.wantErr})
+				return
+			}
+			assertNoError(t, err)
+			var decoded movies
+			json.Unmarshal([]byte(got), &decoded)
+			if len(decoded) != tc.wantLen {
+				t.Errorf("expected %d movies, but got %d", tc.wantLen, len(decoded))
+			}
+		})
+	}
+}
+
+func TestIntegrationDiscoverCmd_RandomSeed(t *testing.T) {
+	// Arrange
+	ts := newFakeTMDBServer(t)
+	args := []string{"discover", "-l=fr", "--format=json", "--random=5", "--seed=42"}
+	// Act
+	first, err := executeCommand(newMockRootCmd(t, ts.URL), args...)
+	assertNoError(t, err)
+	second, err := executeCommand(newMockRootCmd(t, ts.URL), args...)
+	assertNoError(t, err)
+	// Assert
+	if first != second {
+		t.Errorf("expected the same seed to pick the same movies, but got:\n%s\n%s", first, second)
+	}
+}
+
+func TestIntegrationDiscoverCmd_WithMeta(t *testing.T) {
+	// Arrange
+	ts := newFakeTMDBServer(t)
+	root := newMockRootCmd(t, ts.URL)
+	// Act
+	got, err := executeCommandStdout(root, "discover", "-l=fr", "-g=drama", "-m=3", "--format=json", "--with-meta")
+	// Assert
+	assertNoError(t, err)
+	var envelope resultsEnvelope
+	if err := json.Unmarshal([]byte(got), &envelope); err != nil {
+		t.Fatalf("expected a JSON envelope, but got %v:\n%s", err, got)
+	}
+	want := queryParams{MaxItems: 3, Language: "fr", WithGenres: "drama"}
+	if envelope.Query != want {
+		t.Errorf("expected query %+v, but got %+v", want, envelope.Query)
+	}
+	assertContains(t, envelope.URL, []string{ts.URL, "with_original_language=fr", "with_genres=18"})
+	if strings.Contains(envelope.URL, "valid_api_key") {
+		t.Errorf("expected the API key redacted from the URL, but got %q", envelope.URL)
+	}
+	if want := fakeResPage1.TotalResults; envelope.TotalResults != want { // TMDB's, not the 3 shown
+		t.Errorf("expected TMDB's %d total results, but got %d", want, envelope.TotalResults)
+	}
+	assertMovieIDs(t, []int{1, 2, 3}, envelope.Results)
+}
+
+func TestIntegrationDiscoverCmd_WithMetaRequiresJSON(t *testing.T) {
+	// Arrange
+	ts := newFakeTMDBServer(t)
+	root := newMockRootCmd(t, ts.URL)
+	// Act
+	_, err := executeCommand(root, "discover", "-l=fr", "--with-meta")
+	// Assert
+	assertNotNil(t, err)
+	assertContains(t, fmt.Sprint(err), []string{"--with-meta requires --format=json"})
+}
+
+func TestIntegrationDiscoverCmd_FlagHints(t *testing.T) {
+	testCases := []struct {
+		name      string
+		args      []string
+		wantVotes string
+		wantErr   string
+	}{
+		{name: "glued shorthand", args: []string{"-v100,gte"}, wantVotes: "100"},
+		{name: "shorthand with equal sign", args: []string{"-v=100,gte"}, wantVotes: "100"},
+		{name: "space separated long flag", args: []string{"--votes", "100,gte"}, wantVotes: "100"},
+		{
+			name:    "glued long flag",
+			args:    []string{"--votes100,gte"},
+			wantErr: "hint: separate the flag from its value, e.g. --votes=100,gte",
+		},
+		{name: "long flag with single dash", args: []string{"-votes=100,gte"}, wantErr: "did you mean --votes=100,gte?"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			_, err := executeCommand(root, append([]string{"discover"}, tc.args...)...)
+			// Assert
+			if tc.wantErr != "" {
+				assertNotNil(t, err)
+				assertContains(t, err.Error(), []string{tc.wantErr})
+				return
+			}
+			assertNoError(t, err)
+			if got := ts.lastQuery().Get("vote_count.gte"); got != tc.wantVotes {
+				t.Errorf("expected vote_count.gte %q, but got %q", tc.wantVotes, got)
+			}
+		})
+	}
+}
+
+func TestIntegrationDiscoverCmd_OnlyReleased(t *testing.T) {
+	// Arrange
+	previous := nowFunc
+	nowFunc = func() time.Time { return time.Date(2024, 6, 15, 12, 0, 0, 0, yearLocation) }
+	t.Cleanup(func() { nowFunc = previous })
+	ts := newFakeTMDBServer(t)
+	root := newMockRootCmd(t, ts.URL)
+	// Act
+	_, err := executeCommand(root, "discover", "-y=2000,gte", "--only-released")
+	// Assert
+	assertNoError(t, err)
+	if got := ts.lastQuery().Get("primary_release_date.lte"); got != "2024-06-15" {
+		t.Errorf("expected primary_release_date.lte %q, but got %q", "2024-06-15", got)
+	}
+}
+
+func TestIntegrationDiscoverCmd_Positionals(t *testing.T) {
+	testCases := []struct {
+		name       string
+		args       []string
+		wantGenres string
+		wantYear   string
+		wantErr    bool
+	}{
+		{name: "genres and year", args: []string{"drama", "Action", "2020"}, wantGenres: "18,28", wantYear: "2020"},
+		{name: "year only", args: []string{"1999"}, wantYear: "1999"},
+		{name: "explicit genres win", args: []string{"drama", "2020", "-g=horror"}, wantGenres: "27", wantYear: "2020"},
+		{name: "explicit year wins", args: []string{"drama", "2020", "-y=1990"}, wantGenres: "18", wantYear: "1990"},
+		{name: "unknown token", args: []string{"drama", "zombies"}, wantErr: true},
+		{name: "ambiguous years", args: []string{"2000", "2010"}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			_, err := executeCommand(root, append([]string{"discover"}, tc.args...)...)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				return
+			}
+			assertNoError(t, err)
+			if got := ts.lastQuery().Get("with_genres"); got != tc.wantGenres {
+				t.Errorf("expected with_genres %q, but got %q", tc.wantGenres, got)
+			}
+			if got := ts.lastQuery().Get("primary_release_year"); got != tc.wantYear {
+				t.Errorf("expected primary_release_year %q, but got %q", tc.wantYear, got)
+			}
+		})
+	}
+}
+
+func TestIntegrationDiscoverCmd_FetchRuntime(t *testing.T) {
+	runtimes := map[string]int{"/movie/1": 120, "/movie/2": 181, "/movie/3": 95}
+	testCases := []struct {
+		name    string
+		args    []string
+		wantIDs []int
+		wantErr bool
+	}{
+		{name: "sort by runtime", args: []string{"-s=runtime,desc", "--fetch-runtime"}, wantIDs: []int{2, 1, 3}},
+		{name: "sort by runtime without details", args: []string{"-s=runtime,desc"}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requireAPIKey(t, w, r)
+				var res any = tmdbResponse{Page: 1, Results: fakeMovieList[:3], TotalPages: 1, TotalResults: 3}
+				if runtime, ok := runtimes[r.URL.Path]; ok {
+					res = movieDetails{ID: 1, Runtime: runtime}
+				}
+				byt, _ := json.Marshal(res)
+				w.Write(byt)
+			}))
+			t.Cleanup(ts.Close)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			got, err := executeCommand(root, append([]string{"discover", "-m=3", "--format=json", "--output-sort-key="}, tc.args...)...)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				return
+			}
+			assertNoError(t, err)
+			var decoded movies
+			json.Unmarshal([]byte(got), &decoded)
+			assertMovieIDs(t, tc.wantIDs, decoded)
+			assertContains(t, got, []string{`"runtime": 181`})
+		})
+	}
+}
+
+func TestIntegrationEnrichFlag(t *testing.T) {
+	details := map[string]movieDetails{
+		"/movie/1": {ID: 1, Runtime: 120, Overview: "A journey begins.", Genres: []genre{{ID: 12, Name: "Adventure"}}},
+		"/movie/2": {ID: 2, Runtime: 181, Overview: "A quest ends.", Genres: []genre{{ID: 14, Name: "Fantasy"}}},
+		"/movie/3": {ID: 3, Runtime: 95, Overview: "A twist comes.", Genres: []genre{{ID: 53, Name: "Thriller"}}},
+	}
+	testCases := []struct {
+		name        string
+		args        []string
+		want        []string
+		wantDetails int32
+	}{
+		{
+			name:        "discover table",
+			args:        []string{"discover", "-g=drama", "--enrich"},
+			want:        []string{"RUNTIME", "GENRES", "181", "fantasy"},
+			wantDetails: 3,
+		},
+		{
+			name:        "discover json",
+			args:        []string{"discover", "-g=drama", "--enrich", "--format=json"},
+			want:        []string{`"runtime": 95`, `"overview": "A twist comes."`, `"genre_ids": [`},
+			wantDetails: 3,
+		},
+		{
+			name:        "list overview field",
+			args:        []string{"list", "-p", "--enrich", "--fields=id,runtime,overview"},
+			want:        []string{"OVERVIEW", "A quest ends.", "120"},
+			wantDetails: 3,
+		},
+		{name: "without enrich", args: []string{"list", "-p"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			var detailsRequests atomic.Int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requireAPIKey(t, w, r)
+				var res any = tmdbResponse{Page: 1, Results: fakeMovieList[:3], TotalPages: 1, TotalResults: 3}
+				if d, ok := details[r.URL.Path]; ok {
+					detailsRequests.Add(1)
+					res = d
+				}
+				byt, _ := json.Marshal(res)
+				w.Write(byt)
+			}))
+			t.Cleanup(ts.Close)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			got, err := executeCommand(root, append(tc.args, "-m=3")...)
+			// Assert
+			assertNoError(t, err)
+			assertContains(t, got, tc.want)
+			if got := detailsRequests.Load(); got != tc.wantDetails {
+				t.Errorf("expected %d details requests, but got %d", tc.wantDetails, got)
+			}
+		})
+	}
+}
+
+func TestIntegrationDiscoverCmd_Reverse(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		wantIDs []int
+	}{
+		{name: "natural order", args: []string{"-m=3"}, wantIDs: []int{1, 2, 3}},
+		{name: "reverse natural order", args: []string{"-m=3", "-R"}, wantIDs: []int{3, 2, 1}},
+		{name: "sort", args: []string{"-m=3", "-s=average,asc"}, wantIDs: []int{2, 1, 3}},
+		{name: "reverse sort", args: []string{"-m=3", "-s=average,asc", "--reverse"}, wantIDs: []int{3, 1, 2}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			got, err := executeCommandStdout(root, append([]string{"discover", "--format=json", "--output-sort-key="}, tc.args...)...)
+			// Assert
+			assertNoError(t, err)
+			var decoded movies
+			json.Unmarshal([]byte(got), &decoded)
+			assertMovieIDs(t, tc.wantIDs, decoded)
+		})
+	}
+}
+
+func TestIntegrationDiscoverCmd_DefaultMinVotes(t *testing.T) {
+	testCases := []struct {
+		name          string
+		configDefault int
+		args          []string
+		wantParam     string
+		want          string
+	}{
+		{name: "no default", args: []string{"-l=fr"}, wantParam: "vote_count.gte", want: ""},
+		{name: "default applied", configDefault: 50, args: []string{"-l=fr"}, wantParam: "vote_count.gte", want: "50"},
+		{
+			name:          "explicit votes override",
+			configDefault: 50,
+			args:          []string{"-v=100,lte"},
+			wantParam:     "vote_count.lte",
+			want:          "100",
+		},
+		{
+			name:          "disabled with zero",
+			configDefault: 50,
+			args:          []string{"-v=0,gte"},
+			wantParam:     "vote_count.gte",
+			want:          "0",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			viper.Set("default_min_votes", tc.configDefault)
+			t.Cleanup(func() { viper.Set("default_min_votes", 0) })
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			_, err := executeCommand(root, append([]string{"discover"}, tc.args...)...)
+			// Assert
+			assertNoError(t, err)
+			if got := ts.lastQuery().Get(tc.wantParam); got != tc.want {
+				t.Errorf("expected %s %q, but got %q", tc.wantParam, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUnitRelaxVoteAverage(t *testing.T) {
+	testCases := []struct {
+		name    string
+		average string
+		step    float64
+		floor   float64
+		want    string
+		wantOK  bool
+	}{
+		{name: "minimum only", average: "7,gte", step: 0.5, want: "6.5,gte", wantOK: true},
+		{name: "range keeps its maximum", average: "7.5,9", step: 0.5, want: "7,9", wantOK: true},
+		{name: "small step", average: "7,gte", step: 0.1, want: "6.9,gte", wantOK: true},
+		{name: "stops at the floor", average: "6.2,gte", step: 0.5, floor: 6, want: "6,gte", wantOK: true},
+		{name: "at the floor", average: "6,gte", step: 0.5, floor: 6, want: "6,gte"},
+		{name: "maximum only", average: "7,lte", step: 0.5, want: "7,lte"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got, ok := relaxVoteAverage(tc.average, tc.step, tc.floor)
+			// Assert
+			if got != tc.want || ok != tc.wantOK {
+				t.Errorf("expected %q, %t, but got %q, %t", tc.want, tc.wantOK, got, ok)
+			}
+		})
+	}
+}
+
+func TestIntegrationDiscoverCmd_MinResults(t *testing.T) {
+	testCases := []struct {
+		name       string
+		args       []string
+		wantMovies int
+		wantGte    []string
+		wantErr    bool
+		wantStderr string
+	}{
+		{
+			name:       "enough movies at first",
+			args:       []string{"-a=6,gte", "--min-results=5"},
+			wantMovies: 10,
+			wantGte:    []string{"6"},
+		},
+		{
+			name:       "relaxed until enough movies",
+			args:       []string{"-a=7.5,gte", "--min-results=5"},
+			wantMovies: 10,
+			wantGte:    []string{"7.5", "7", "6.5"},
+			wantStderr: "relaxed --average from 7.5,gte to 6.5,gte to find 5 movies",
+		},
+		{
+			name:       "floor reached",
+			args:       []string{"-a=7.5,gte", "--min-results=5", "--relax-floor=7"},
+			wantMovies: 3,
+			wantGte:    []string{"7.5", "7"},
+			wantStderr: "warning: found only 3 of 5 movies, even with --average=7,gte",
+		},
+		{name: "without minimum average", args: []string{"-a=7,lte", "--min-results=5"}, wantErr: true},
+		{name: "above max items", args: []string{"-a=7,gte", "--min-results=11"}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			var gte []string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Only 3 movies are rated 7 and more
+				res := tmdbResponse{Page: 1, Results: fakeMovieList[:3], TotalPages: 1, TotalResults: 3}
+				average := r.URL.Query().Get("vote_average.gte")
+				if value, _ := strconv.ParseFloat(average, 64); value < 7 {
+					res = fakeResPage1
+				}
+				gte = append(gte, average)
+				byt, _ := json.Marshal(res)
+				w.Write(byt)
+			}))
+			t.Cleanup(ts.Close)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			_, stdout, stderr, err := executeCommandStreams(root,
+				append([]string{"discover", "-m=10", "--max-pages=1", "--format=json"}, tc.args...)...)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				return
+			}
+			assertNoError(t, err)
+			var decoded movies
+			json.Unmarshal([]byte(stdout), &decoded)
+			if len(decoded) != tc.wantMovies {
+				t.Errorf("expected %d movies, but got %d", tc.wantMovies, len(decoded))
+			}
+			if !reflect.DeepEqual(gte, tc.wantGte) {
+				t.Errorf("expected queries with vote_average.gte %v, but got %v", tc.wantGte, gte)
+			}
+			if tc.wantStderr != "" {
+				assertContains(t, stderr, []string{tc.wantStderr})
+			}
+		})
+	}
+}
+
+func TestIntegrationCollectionCmd(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantIDs []int
+		wantErr string
+	}{
+		{name: "table with name", args: []string{"119"}, want: []string{"The Fake Collection", "ORIGINAL TITLE"}},
+		{name: "sorted by release date", args: []string{"119", "--format=json"}, wantIDs: []int{1, 2, 3}},
+		{name: "explicit sort", args: []string{"119", "-s=average,desc", "--format=json", "--output-sort-key="}, wantIDs: []int{3, 1, 2}},
+		{name: "not found", args: []string{"404"}, wantErr: "collection not found"},
+		{name: "non numeric id", args: []string{"abc"}, wantErr: "validation error"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requireAPIKey(t, w, r)
+				if r.URL.Path != "/collection/119" {
+					http.NotFound(w, r)
+					return
+				}
+				byt, _ := json.Marshal(collectionResponse{
+					ID:    119,
+					Name:  "The Fake Collection",
+					Parts: movies{fakeMovieList[2], fakeMovieList[0], fakeMovieList[1]},
+				})
+				w.Write(byt)
+			}))
+			t.Cleanup(ts.Close)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			got, err := executeCommand(root, append([]string{"collection"}, tc.args...)...)
+			// Assert
+			if tc.wantErr != "" {
+				assertNotNil(t, err)
+				assertContains(t, fmt.Sprint(err), []string{tc.wantErr})
+				return
+			}
+			assertNoError(t, err)
+			assertContains(t, got, tc.want)
+			if tc.wantIDs != nil {
+				var decoded movies
+				json.Unmarshal([]byte(got), &decoded)
+				assertMovieIDs(t, tc.wantIDs, decoded)
+			}
+		})
+	}
+}
+
+func TestIntegrationFindCmd(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantIDs []int
+		wantErr string
+	}{
+		{name: "table", args: []string{"--imdb", "tt0133093"}, want: []string{"ORIGINAL TITLE", "Epic Journey Begins"}},
+		{name: "json", args: []string{"--imdb=tt0133093", "--format=json"}, wantIDs: []int{1}},
+		{name: "no match", args: []string{"--imdb=tt9999999"}, want: []string{"No results available"}},
+		{name: "malformed id", args: []string{"--imdb=0133093"}, wantErr: "IMDb ID must be"},
+		{name: "missing flag", wantErr: `required flag(s) "imdb" not set`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requireAPIKey(t, w, r)
+				if r.URL.Query().Get("external_source") != "imdb_id" {
+					http.NotFound(w, r)
+					return
+				}
+				found := findResponse{MovieResults: movies{}}
+				if r.URL.Path == "/find/tt0133093" {
+					found.MovieResults = movies{fakeMovieList[0]}
+				}
+				byt, _ := json.Marshal(found)
+				w.Write(byt)
+			}))
+			t.Cleanup(ts.Close)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			got, err := executeCommand(root, append([]string{"find"}, tc.args...)...)
+			// Assert
+			if tc.wantErr != "" {
+				assertNotNil(t, err)
+				assertContains(t, fmt.Sprint(err), []string{tc.wantErr})
+				return
+			}
+			assertNoError(t, err)
+			assertContains(t, got, tc.want)
+			if tc.wantIDs != nil {
+				var decoded movies
+				json.Unmarshal([]byte(got), &decoded)
+				assertMovieIDs(t, tc.wantIDs, decoded)
+			}
+		})
+	}
+}
+
+var fakeDetails = map[int]movieDetails{
+	603: {
+		ID: 603, Title: "The Matrix", ReleaseDate: "1999-03-31", VoteAverage: 8.2, VoteCount: 26000,
+		Runtime: 136, Genres: []genre{{ID: 28, Name: "Action"}, {ID: 878, Name: "Science Fiction"}},
+	},
+	604: {
+		ID: 604, Title: "The Matrix Reloaded", ReleaseDate: "2003-05-15", VoteAverage: 7.1, VoteCount: 11000,
+		Runtime: 138, Genres: []genre{{ID: 28, Name: "Action"}},
+	},
+}
+
+func TestUnitFormatComparison(t *testing.T) {
+	testCases := []struct {
+		name   string
+		a, b   movieDetails
+		opts   outputOptions
+		want   []string
+		reject []string
+	}{
+		{
+			name: "higher values marked",
+			a:    fakeDetails[603],
+			b:    fakeDetails[604],
+			want: []string{
+				"The Matrix", "The Matrix Reloaded",
+				"136 min", "138 min *", "8.2 *", "7.1", "26000 *", "11000",
+				"1999-03-31", "2003-05-15 *", "action, science-fiction",
+			},
+		},
+		{
+			name:   "ties not marked",
+			a:      fakeDetails[603],
+			b:      fakeDetails[603],
+			reject: []string{"*"},
+		},
+		{
+			name:   "higher values bold with color",
+			a:      fakeDetails[603],
+			b:      fakeDetails[604],
+			opts:   outputOptions{Color: true},
+			want:   []string{"\033[1m138 min\033[0m", "\033[1m8.2\033[0m"},
+			reject: []string{"*"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got := formatComparison(tc.a, tc.b, tc.opts)
+			// Assert
+			assertContains(t, got, tc.want)
+			for _, r := range tc.reject {
+				if strings.Contains(got, r) {
+					t.Errorf("expected output without %q, but got:\n%s", r, got)
+				}
+			}
+		})
+	}
+}
+
+func TestIntegrationCompareCmd(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr string
+	}{
+		{name: "two movies", args: []string{"603", "604"}, want: []string{"The Matrix Reloaded", "138 min *"}},
+		{name: "non numeric id", args: []string{"603", "matrix"}, wantErr: "movie ID must be a positive integer"},
+		{name: "unknown movie", args: []string{"603", "999"}, wantErr: "404"},
+		{name: "one id", args: []string{"603"}, wantErr: "accepts 2 arg(s)"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requireAPIKey(t, w, r)
+				id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/movie/"))
+				details, ok := fakeDetails[id]
+				if !ok {
+					http.NotFound(w, r)
+					return
+				}
+				byt, _ := json.Marshal(details)
+				w.Write(byt)
+			}))
+			t.Cleanup(ts.Close)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			got, err := executeCommand(root, append([]string{"compare", "--no-retry"}, tc.args...)...)
+			// Assert
+			if tc.wantErr != "" {
+				assertNotNil(t, err)
+				assertContains(t, fmt.Sprint(err), []string{tc.wantErr})
+				return
+			}
+			assertNoError(t, err)
+			assertContains(t, got, tc.want)
+		})
+	}
+}
+
+func TestIntegrationWatchlistCmd_APIVersion4(t *testing.T) {
+	testCases := []struct {
+		name    string
+		apiKey  string
+		wantErr string
+	}{
+		{name: "v4 access token", apiKey: fakeV4Token("4bc889a8")},
+		{name: "v3 api key", apiKey: "valid_api_key", wantErr: "needs a v4 access token"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			var paths []string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				paths = append(paths, r.URL.Path)
+				if r.URL.Path != "/account/4bc889a8/movie/watchlist" {
+					http.NotFound(w, r)
+					return
+				}
+				byt, _ := json.Marshal(fakeResPage1)
+				w.Write(byt)
+			}))
+			t.Cleanup(ts.Close)
+			root := newMockRootCmd(t, ts.URL)
+			deps := root.Context().Value(dependencies).(*Dependencies)
+			deps.Client.APIKey = tc.apiKey
+			assertNoError(t, deps.URLBuilder.setVersion(4))
+			deps.URLBuilder.BaseURL = ts.URL
+			// Act
+			got, err := executeCommand(root, "watchlist", "list", "--no-retry")
+			// Assert
+			if tc.wantErr != "" {
+				assertNotNil(t, err)
+				assertContains(t, err.Error(), []string{tc.wantErr})
+				return
+			}
+			assertNoError(t, err)
+			assertContains(t, got, []string{"Epic Journey Begins"})
+			if slices.Contains(paths, "/account") {
+				t.Errorf("expected the account read from the token, but got requests %v", paths)
+			}
+		})
+	}
+}
+
+func TestIntegrationExpiredToken(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		token   string
+		want    string
+		notWant string
+	}{
+		{
+			name:    "expired token",
+			args:    []string{"discover", "-g=drama"},
+			token:   fakeExpiringToken(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)),
+			want:    "your TMDB access token appears to have expired on 2024-01-02; please regenerate it.",
+			notWant: "client error",
+		},
+		{
+			name:    "expired token on an account command",
+			args:    []string{"watchlist", "list"},
+			token:   fakeExpiringToken(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)),
+			want:    "appears to have expired on 2024-01-02",
+			notWant: "needs an access token approved",
+		},
+		{
+			name:    "valid token",
+			args:    []string{"discover", "-g=drama"},
+			token:   fakeExpiringToken(time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)),
+			want:    "client error",
+			notWant: "expired",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"success": false, "status_code": 7, "status_message": "Invalid API key"}`))
+			}))
+			t.Cleanup(ts.Close)
+			root := newMockRootCmd(t, ts.URL)
+			root.Context().Value(dependencies).(*Dependencies).Client.APIKey = tc.token
+			// Act
+			_, err := executeCommand(root, append(tc.args, "--no-retry")...)
+			// Assert
+			assertNotNil(t, err)
+			assertContains(t, err.Error(), []string{tc.want})
+			assertNotContains(t, err.Error(), []string{tc.notWant})
+		})
+	}
+}
+
+func TestIntegrationWatchlistCmd(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		readOnly bool
+		want     []string
+		wantBody *watchlistRequest
+		wantErr  string
+	}{
+		{
+			name:     "add",
+			args:     []string{"add", "603"},
+			want:     []string{"added 603 to the watchlist"},
+			wantBody: &watchlistRequest{MediaType: "movie", MediaID: 603, Watchlist: true},
+		},
+		{
+			name:     "remove",
+			args:     []string{"remove", "603"},
+			want:     []string{"removed 603 from the watchlist"},
+			wantBody: &watchlistRequest{MediaType: "movie", MediaID: 603, Watchlist: false},
+		},
+		{name: "list", args: []string{"list"}, want: []string{"ORIGINAL TITLE", "Epic Journey Begins"}},
+		{name: "non numeric id", args: []string{"add", "matrix"}, wantErr: "movie ID must be a positive integer"},
+		{name: "read only token", args: []string{"add", "603"}, readOnly: true, wantErr: "authentication error"},
+		{name: "read only token on list", args: []string{"list"}, readOnly: true, wantErr: "authentication error"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			var gotMethod string
+			var gotBody *watchlistRequest
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requireAPIKey(t, w, r)
+				switch r.URL.Path {
+				case "/account":
+					w.Write([]byte(`{"id": 42, "username": "fake"}`))
+					return
+				case "/account/42/watchlist":
+					gotMethod = r.Method
+					json.NewDecoder(r.Body).Decode(&gotBody)
+					if tc.readOnly {
+						w.WriteHeader(http.StatusUnauthorized)
+						w.Write([]byte(`{"success": false, "status_code": 36, "status_message": "read only"}`))
+						return
+					}
+					w.Write([]byte(`{"success": true, "status_code": 1, "status_message": "Success."}`))
+					return
+				case "/account/42/watchlist/movies":
+					if tc.readOnly {
+						w.WriteHeader(http.StatusUnauthorized)
+						return
+					}
+					byt, _ := json.Marshal(fakeResPage1)
+					w.Write(byt)
+					return
+				}
+				http.NotFound(w, r)
+			}))
+			t.Cleanup(ts.Close)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			got, err := executeCommand(root, append([]string{"watchlist", "--no-retry"}, tc.args...)...)
+			// Assert
+			if tc.wantErr != "" {
+				assertNotNil(t, err)
+				assertContains(t, fmt.Sprint(err), []string{tc.wantErr})
+				return
+			}
+			assertNoError(t, err)
+			assertContains(t, got, tc.want)
+			if tc.wantBody != nil {
+				if gotMethod != http.MethodPost {
+					t.Errorf("want method %s, got %s", http.MethodPost, gotMethod)
+				}
+				if gotBody == nil || *gotBody != *tc.wantBody {
+					t.Errorf("want body %+v, got %+v", *tc.wantBody, gotBody)
+				}
+			}
+		})
+	}
+}
+
+func TestIntegrationRateCmd(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		readOnly bool
+		want     []string
+		wantErr  string
+	}{
+		{name: "valid rating", args: []string{"603", "7.5"}, want: []string{"rated 603 7.5"}},
+		{name: "not a multiple of 0.5", args: []string{"603", "7.3"}, wantErr: "rating must be a multiple of 0.5"},
+		{name: "non numeric id", args: []string{"matrix", "7.5"}, wantErr: "movie ID must be a positive integer"},
+		{name: "unknown movie", args: []string{"999", "7.5"}, wantErr: "could not be found"},
+		{name: "read only token", args: []string{"603", "7.5"}, readOnly: true, wantErr: "authentication error"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			var gotBody ratingRequest
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requireAPIKey(t, w, r)
+				if r.Method != http.MethodPost || r.URL.Path != "/movie/603/rating" {
+					w.Write([]byte(`{"success": false, "status_code": 34, "status_message": "The resource you requested could not be found."}`))
+					return
+				}
+				if tc.readOnly {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				json.NewDecoder(r.Body).Decode(&gotBody)
+				w.Write([]byte(`{"success": true, "status_code": 1, "status_message": "Success."}`))
+			}))
+			t.Cleanup(ts.Close)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			got, err := executeCommand(root, append([]string{"rate", "--no-retry"}, tc.args...)...)
+			// Assert
+			if tc.wantErr != "" {
+				assertNotNil(t, err)
+				assertContains(t, fmt.Sprint(err), []string{tc.wantErr})
+				return
+			}
+			assertNoError(t, err)
+			assertContains(t, got, tc.want)
+			if gotBody.Value != 7.5 {
+				t.Errorf("expected the rating 7.5 to be posted, but got %v", gotBody.Value)
+			}
+		})
+	}
+}
+
+func TestIntegrationDoctorCmd(t *testing.T) {
+	testCases := []struct {
+		name    string
+		config  string
+		args    []string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:   "healthy setup",
+			config: "# api key passed with --api-key-file",
+			args:   []string{"--api-key-file"},
+			want:   []string{"[ok]   config file", "[ok]   API key", "[ok]   API key valid", "[ok]   network"},
+		},
+		{
+			name:    "missing config",
+			want:    []string{"[fail] config file", "hint:", "[ok]   config dir writable"},
+			wantErr: "doctor found 1 problem(s)",
+		},
+		{
+			name:   "offline",
+			config: "# api key passed with --api-key-file",
+			args:   []string{"--api-key-file", "--offline"},
+			want:   []string{"[ok]   API key", "[ok]   config dir writable"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requireAPIKey(t, w, r)
+				w.Write([]byte(`{"success": true}`))
+			}))
+			t.Cleanup(ts.Close)
+			dir := t.TempDir()
+			if tc.config != "" {
+				os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(tc.config), 0o600)
+			}
+			keyFile := filepath.Join(dir, "tmdb")
+			os.WriteFile(keyFile, []byte("valid_api_key"), 0o600)
+			args := []string{"doctor", "--config-dir", dir}
+			for _, arg := range tc.args {
+				args = append(args, arg)
+				if arg == "--api-key-file" {
+					args = append(args, keyFile)
+				}
+			}
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			got, err := executeCommand(root, args...)
+			// Assert
+			if tc.wantErr != "" {
+				assertNotNil(t, err)
+				assertContains(t, fmt.Sprint(err), []string{tc.wantErr})
+			} else {
+				assertNoError(t, err)
+			}
+			assertContains(t, got, tc.want)
+			if slices.Contains(tc.args, "--offline") && strings.Contains(got, "network") {
+				t.Errorf("expected no network check offline, but got:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestIntegrationLanguagesCmd(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr string
+	}{
+		{name: "table of codes", args: nil, want: []string{"CODE", "LANGUAGE", "fr", "French", "cn", "Cantonese"}},
+		{name: "rejects arguments", args: []string{"fr"}, wantErr: "unknown command"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			root := newMockRootCmd(t, "http://127.0.0.1:0")
+			// Act
+			got, err := executeCommand(root, append([]string{"languages"}, tc.args...)...)
+			// Assert
+			if tc.wantErr != "" {
+				assertNotNil(t, err)
+				assertContains(t, fmt.Sprint(err), []string{tc.wantErr})
+				return
+			}
+			assertNoError(t, err)
+			assertContains(t, got, tc.want)
+		})
+	}
+}
+
+func TestIntegrationMergeCmd(t *testing.T) {
+	dir := t.TempDir()
+	arrayFile := filepath.Join(dir, "array.json")
+	arrayByt, _ := json.Marshal(fakeMovieList[0:3])
+	os.WriteFile(arrayFile, arrayByt, 0o600)
+	objectFile := filepath.Join(dir, "object.json")
+	objectByt, _ := json.Marshal(tmdbResponse{Page: 1, Results: fakeMovieList[2:5]})
+	os.WriteFile(objectFile, objectByt, 0o600)
+	rereleaseFile := filepath.Join(dir, "rerelease.json")
+	rereleaseByt, _ := json.Marshal(movies{{ID: 100, Title: " epic journey BEGINS "}})
+	os.WriteFile(rereleaseFile, rereleaseByt, 0o600)
+	invalidFile := filepath.Join(dir, "invalid.json")
+	os.WriteFile(invalidFile, []byte(`{"page": 1}`), 0o600)
+	testCases := []struct {
+		name    string
+		args    []string
+		wantIDs []int
+		wantErr bool
+	}{
+		{name: "overlapping files", args: []string{arrayFile, objectFile}, wantIDs: []int{1, 2, 3, 4, 5}},
+		{name: "sorted", args: []string{arrayFile, objectFile, "-s=average,desc", "--output-sort-key="}, wantIDs: []int{3, 1, 4, 5, 2}},
+		{name: "dedupe by id", args: []string{arrayFile, rereleaseFile}, wantIDs: []int{1, 2, 3, 100}},
+		{name: "dedupe by title", args: []string{arrayFile, rereleaseFile, "--dedupe-by=title"}, wantIDs: []int{1, 2, 3}},
+		{name: "unknown dedupe key", args: []string{arrayFile, "--dedupe-by=year"}, wantErr: true},
+		{name: "missing file", args: []string{arrayFile, filepath.Join(dir, "missing.json")}, wantErr: true},
+		{name: "unexpected shape", args: []string{invalidFile}, wantErr: true},
+		{name: "no files", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			root := newRootCmd("missing_config.yaml") // Merging works offline
+			// Act
+			got, err := executeCommand(root, append([]string{"merge", "--format=json"}, tc.args...)...)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				return
+			}
+			assertNoError(t, err)
+			var decoded movies
+			if err := json.Unmarshal([]byte(got), &decoded); err != nil {
+				t.Fatalf("unmarshal JSON output: %v", err)
+			}
+			assertMovieIDs(t, tc.wantIDs, decoded)
+		})
+	}
+}
+
+func TestIntegrationMergeCmd_SourceColumn(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	popularFile := filepath.Join(dir, "popular.json")
+	popularByt, _ := json.Marshal(fakeMovieList[0:2])
+	os.WriteFile(popularFile, popularByt, 0o600)
+	topFile := filepath.Join(dir, "top.json")
+	topByt, _ := json.Marshal(fakeMovieList[1:3])
+	os.WriteFile(topFile, topByt, 0o600)
+	root := newRootCmd("missing_config.yaml")
+	// Act
+	got, err := executeCommand(root, "merge", popularFile, topFile, "--source-column")
+	// Assert
+	assertNoError(t, err)
+	assertContains(t, got, []string{"SOURCE", "popular.json, top.json"})
+	for _, line := range strings.Split(got, "\n") {
+		switch {
+		case strings.Contains(line, fakeMovieList[0].Title):
+			assertContains(t, line, []string{"popular.json"})
+			assertNotContains(t, line, []string{"top.json"})
+		case strings.Contains(line, fakeMovieList[2].Title):
+			assertContains(t, line, []string{"top.json"})
+			assertNotContains(t, line, []string{"popular.json"})
+		}
+	}
+}
+
+func TestUnitSetOutputEncoding(t *testing.T) {
+	title := "L'Héritage des Héros, A Ascensão da Fênix"
+	testCases := []struct {
+		name     string
+		encoding string
+		decoder  *encoding.Decoder
+		wantErr  bool
+	}{
+		{name: "utf-8 unchanged", encoding: "utf-8"},
+		{name: "cp1252", encoding: "cp1252", decoder: charmap.Windows1252.NewDecoder()},
+		{name: "latin1", encoding: "ISO-8859-1", decoder: charmap.Windows1252.NewDecoder()},
+		{name: "unknown encoding", encoding: "klingon", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			buf := new(bytes.Buffer)
+			cmd := &cobra.Command{}
+			cmd.Flags().String("output-encoding", tc.encoding, "")
+			cmd.SetOut(buf)
+			// Act
+			err := setOutputEncoding(cmd)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				return
+			}
+			assertNoError(t, err)
+			cmd.Print(title)
+			if tc.decoder == nil {
+				if buf.String() != title {
+					t.Errorf("expected %q, but got %q", title, buf.String())
+				}
+				return
+			}
+			if utf8.Valid(buf.Bytes()) {
+				t.Errorf("expected transcoded bytes, but got UTF-8 %q", buf.String())
+			}
+			got, err := tc.decoder.String(buf.String())
+			assertNoError(t, err)
+			if got != title {
+				t.Errorf("expected %q after round trip, but got %q", title, got)
+			}
+		})
+	}
+}
+
+func TestUnitFormatGenres(t *testing.T) {
+	testCases := []struct {
+		name string
+		ids  []int
+		opts outputOptions
+		want string
+	}{
+		{name: "no genres", want: ""},
+		{name: "genre names", ids: []int{27, 53}, want: "horror, thriller"},
+		{name: "unknown genre id", ids: []int{28, 1}, want: "action, 1"},
+		{
+			name: "highlight without color",
+			ids:  []int{27, 53},
+			opts: outputOptions{HighlightGenres: map[int]bool{27: true}},
+			want: "horror, thriller",
+		},
+		{
+			name: "highlight with color",
+			ids:  []int{27, 53},
+			opts: outputOptions{HighlightGenres: map[int]bool{27: true}, Color: true},
+			want: "\033[1mhorror\033[0m, thriller",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got := formatGenres(tc.ids, tc.opts)
+			// Assert
+			if got != tc.want {
+				t.Errorf("expected genres %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUnitFormatNumbers(t *testing.T) {
+	testCases := []struct {
+		name      string
+		opts      outputOptions
+		wantInt   string
+		wantFloat string
+	}{
+		{name: "raw by default", wantInt: "1234567", wantFloat: "7.5"},
+		{name: "english grouping", opts: outputOptions{Numbers: message.NewPrinter(language.English)},
+			wantInt: "1,234,567", wantFloat: "7.5"},
+		{name: "german grouping", opts: outputOptions{Numbers: message.NewPrinter(language.German)},
+			wantInt: "1.234.567", wantFloat: "7,5"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			gotInt, gotFloat := tc.opts.formatInt(1234567), tc.opts.formatFloat(7.5)
+			// Assert
+			if gotInt != tc.wantInt || gotFloat != tc.wantFloat {
+				t.Errorf("expected %q and %q, but got %q and %q", tc.wantInt, tc.wantFloat, gotInt, gotFloat)
+			}
+		})
+	}
+}
+
+func TestUnitFormatNumbers_Grouped(t *testing.T) {
+	groups := []groupSummary{{Group: "Drama", Count: 1234, Average: 7.5}}
+	counts := []yearCount{{Year: "2023", Count: 1500}}
+	testCases := []struct {
+		name       string
+		opts       outputOptions
+		wantGroups []string
+		wantYears  []string
+	}{
+		{name: "raw by default", wantGroups: []string{"1234", "7.5"}, wantYears: []string{"2023", "1500"}},
+		{name: "english grouping", opts: outputOptions{Numbers: message.NewPrinter(language.English)},
+			wantGroups: []string{"1,234", "7.5"}, wantYears: []string{"2023", "1,500"}},
+		{name: "german grouping", opts: outputOptions{Numbers: message.NewPrinter(language.German)},
+			wantGroups: []string{"1.234", "7,5"}, wantYears: []string{"2023", "1.500"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			gotGroups, gotYears := formatGroups("Genre", groups, tc.opts), formatYearCounts(counts, tc.opts)
+			// Assert
+			assertContains(t, gotGroups, tc.wantGroups)
+			assertContains(t, gotYears, tc.wantYears)
+		})
+	}
+}
+
+func TestIntegrationOutputSortKeyFlag(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		wantIDs func(sorted movies) []int
+		wantErr bool
+	}{
+		{
+			name:    "ID without the flag",
+			args:    []string{"--format=json"},
+			wantIDs: func(movies) []int { return movieIDs(fakeMovieList[:5]) },
+		},
+		{
+			name:    "display sort when empty",
+			args:    []string{"--format=json", "--output-sort-key="},
+			wantIDs: func(sorted movies) []int { return movieIDs(sorted) },
+		},
+		{
+			name: "votes",
+			args: []string{"--format=json", "--output-sort-key=votes"},
+			wantIDs: func(sorted movies) []int {
+				byVotes, _ := slices.Clone(sorted).sortByField("votes,asc")
+				return movieIDs(byVotes)
+			},
+		},
+		{
+			name: "votes separated by a space",
+			args: []string{"--format=json", "--output-sort-key", "votes"},
+			wantIDs: func(sorted movies) []int {
+				byVotes, _ := slices.Clone(sorted).sortByField("votes,asc")
+				return movieIDs(byVotes)
+			},
+		},
+		{name: "unknown key", args: []string{"--format=json", "--output-sort-key=poster"}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			sorted, _ := slices.Clone(fakeMovieList[:5]).sortByField("title,desc")
+			// Act
+			got, err := executeCommandStdout(root,
+				append([]string{"discover", "-g=drama", "-m=5", "-s=title,desc"}, tc.args...)...)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				assertContains(t, err.Error(), []string{"output sort key must be one of"})
+				return
+			}
+			assertNoError(t, err)
+			var decoded movies
+			if err := json.Unmarshal([]byte(got), &decoded); err != nil {
+				t.Fatalf("decode JSON output: %v", err)
+			}
+			assertMovieIDs(t, tc.wantIDs(sorted), decoded)
+		})
+	}
+}
+
+func TestIntegrationOutputSortKeyFlag_Table(t *testing.T) {
+	// Arrange
+	byVotes, _ := slices.Clone(fakeMovieList[:5]).sortByField("votes,desc")
+	args := []string{"discover", "-g=drama", "-m=5", "--sort=votes,desc"}
+	ts := newFakeTMDBServer(t)
+	// Act
+	table, tableErr := executeCommandStdout(newMockRootCmd(t, ts.URL), args...)
+	serialized, jsonErr := executeCommandStdout(newMockRootCmd(t, ts.URL), append(args, "--format=json")...)
+	// Assert
+	assertNoError(t, tableErr)
+	assertNoError(t, jsonErr)
+	for i := 1; i < len(byVotes); i++ {
+		if strings.Index(table, byVotes[i-1].Title) > strings.Index(table, byVotes[i].Title) {
+			t.Errorf("expected the table to honor --sort, listing %q before %q, but got:\n%s",
+				byVotes[i-1].Title, byVotes[i].Title, table)
+		}
+	}
+	var decoded movies
+	if err := json.Unmarshal([]byte(serialized), &decoded); err != nil {
+		t.Fatalf("decode JSON output: %v", err)
+	}
+	assertMovieIDs(t, movieIDs(fakeMovieList[:5]), decoded)
+}
+
+func TestIntegrationThemeFlag(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{name: "movie table", args: []string{"discover", "-g=drama", "--theme=heavy"}, want: "║"},
+		{name: "count table", args: []string{"discover", "-g=drama", "--count-by-year", "--theme=heavy"}, want: "║"},
+		{name: "unknown theme", args: []string{"discover", "-g=drama", "--theme=fancy"}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			got, err := executeCommand(root, tc.args...)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				assertContains(t, err.Error(), []string{"theme must be one of"})
+				return
+			}
+			assertNoError(t, err)
+			assertContains(t, got, []string{tc.want})
+		})
+	}
+}
+
+func TestIntegrationFormatFlag_ThousandsSep(t *testing.T) {
+	testCases := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{name: "raw by default", args: []string{"603", "604"}, want: []string{"26000"}},
+		{name: "grouped", args: []string{"603", "604", "--thousands-sep"}, want: []string{"26,000", "11,000", "8.2"}},
+		{
+			name: "grouped by locale",
+			args: []string{"603", "604", "--thousands-sep", "--locale=de"},
+			want: []string{"26.000", "11.000", "8,2"},
+		},
+		{name: "invalid locale", args: []string{"603", "604", "--thousands-sep", "--locale=not a tag"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/movie/"))
+				byt, _ := json.Marshal(fakeDetails[id])
+				w.Write(byt)
+			}))
+			t.Cleanup(ts.Close)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			got, err := executeCommand(root, append([]string{"compare"}, tc.args...)...)
+			// Assert
+			if tc.want == nil {
+				assertNotNil(t, err)
+				return
+			}
+			assertNoError(t, err)
+			assertContains(t, got, tc.want)
+		})
+	}
+}
+
+func TestIntegrationDiscoverCmd_ShowGenres(t *testing.T) {
+	// Arrange
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requireAPIKey(t, w, r)
+		res := fakeEmptyRes
+		if r.URL.Query().Get("page") == "1" {
+			m := fakeMovieList[0]
+			m.GenreIDs = []int{27, 878}
+			res = tmdbResponse{Page: 1, Results: movies{m}, TotalPages: 1, TotalResults: 1}
+		}
+		byt, _ := json.Marshal(res)
+		w.Write(byt)
+	}))
+	t.Cleanup(ts.Close)
+	root := newMockRootCmd(t, ts.URL)
+	// Act
+	got, err := executeCommand(root, "discover", "-g=horror", "--show-genres")
+	// Assert
+	assertNoError(t, err)
+	assertContains(t, got, []string{"GENRES", "horror, science-fiction"})
+}
+
+func TestUnitFormatResults_Themes(t *testing.T) {
+	testCases := []struct {
+		theme   string
+		want    []string
+		notWant []string
+	}{
+		{theme: "default", want: []string{"│", "⎯", "+"}, notWant: []string{"─", "║"}},
+		{theme: "minimal", want: []string{"│", "─", "┼"}, notWant: []string{"⎯", "+"}},
+		{theme: "compact", want: []string{"─"}, notWant: []string{"│", "+", "┼"}},
+		{theme: "heavy", want: []string{"║", "═", "╬"}, notWant: []string{"│", "⎯"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.theme, func(t *testing.T) {
+			// Act
+			got := formatResults(fakeMovieList[:3], outputOptions{Theme: tc.theme})
+			// Assert
+			assertContains(t, got, tc.want)
+			assertNotContains(t, got, tc.notWant)
+			rowLines := strings.Count(got, "\n") > 2*3+2
+			if want := tableThemes[tc.theme].RowLine; rowLines != want {
+				t.Errorf("expected row lines %v, but got:\n%s", want, got)
+			}
+		})
+	}
+}
+
+func TestUnitFormatResults_DefaultTheme(t *testing.T) {
+	// Act
+	unset := formatResults(fakeMovieList[:3], outputOptions{})
+	named := formatResults(fakeMovieList[:3], outputOptions{Theme: "default"})
+	// Assert
+	if unset != named {
+		t.Errorf("expected the default theme when unset, but got:\n%s\ninstead of:\n%s", unset, named)
+	}
+}
+
+func TestUnitFormatResults_ASCII(t *testing.T) {
+	testCases := []struct {
+		theme string
+		want  []string
+	}{
+		{theme: "default", want: []string{"|", "-", "+"}},
+		{theme: "minimal", want: []string{"|", "-", "+"}},
+		{theme: "compact", want: []string{"-"}},
+		{theme: "heavy", want: []string{"|", "=", "+"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.theme, func(t *testing.T) {
+			// Act
+			got := formatResults(fakeMovieList[:3], outputOptions{Theme: tc.theme, ASCII: true})
+			// Assert
+			assertContains(t, got, tc.want)
+			for glyph := range asciiGlyphs {
+				assertNotContains(t, got, []string{glyph})
+			}
+		})
+	}
+}
+
+func TestUnitASCIILocale(t *testing.T) {
+	testCases := []struct {
+		name   string
+		lcAll  string
+		lang   string
+		wantOK bool
+	}{
+		{name: "unset"},
+		{name: "utf-8", lang: "en_US.UTF-8"},
+		{name: "utf8 with modifier", lang: "de_DE.utf8@euro"},
+		{name: "without charset", lang: "C"},
+		{name: "latin-1", lang: "en_US.ISO-8859-1", wantOK: true},
+		{name: "lc_all first", lcAll: "fr_FR.UTF-8", lang: "fr_FR.ISO-8859-15"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			t.Setenv("LC_ALL", tc.lcAll)
+			t.Setenv("LC_CTYPE", "")
+			t.Setenv("LANG", tc.lang)
+			// Act
+			got := asciiLocale()
+			// Assert
+			if got != tc.wantOK {
+				t.Errorf("expected %t, but got %t", tc.wantOK, got)
+			}
+		})
+	}
+}
+
+func TestIntegrationASCIIFlag(t *testing.T) {
+	testCases := []struct {
+		name      string
+		args      []string
+		lang      string
+		wantASCII bool
+	}{
+		{name: "unicode by default", args: []string{"discover", "-g=drama"}, lang: "en_US.UTF-8"},
+		{name: "movie table", args: []string{"discover", "-g=drama", "--ascii"}, wantASCII: true},
+		{name: "languages table", args: []string{"languages", "--ascii"}, wantASCII: true},
+		{name: "detected from the locale", args: []string{"discover", "-g=drama"}, lang: "en_US.ISO-8859-1", wantASCII: true},
+		{name: "forced off", args: []string{"discover", "-g=drama", "--ascii=false"}, lang: "en_US.ISO-8859-1"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			localeEnv = func(name string) string {
+				if name == "LANG" {
+					return tc.lang
+				}
+				return ""
+			}
+			// Act
+			got, err := executeCommand(root, tc.args...)
+			// Assert
+			assertNoError(t, err)
+			if tc.wantASCII {
+				assertContains(t, got, []string{"|"})
+				assertNotContains(t, got, []string{"│", "⎯"})
+			} else {
+				assertContains(t, got, []string{"│", "⎯"})
+			}
+		})
+	}
+}
+
+func TestUnitFormatCSV(t *testing.T) {
+	fakeMovies := movies{fakeMovieList[0], fakeMovieList[1]}
+	fakeMovies[0].GenreIDs = []int{18}
+	testCases := []struct {
+		name string
+		opts outputOptions
+		want string
+	}{
+		{
+			name: "with header",
+			want: "#,Original Title,Release Date,Title,Average,Votes\n" +
+				"1,L'Aube de l'Aventure,2023-01-01,Epic Journey Begins,8.5,100\n" +
+				"2,Rise of the Heroes,2023-02-01,Rise of the Heroes,7.0,50",
+		},
+		{
+			name: "without header",
+			opts: outputOptions{NoHeader: true},
+			want: "1,L'Aube de l'Aventure,2023-01-01,Epic Journey Begins,8.5,100\n" +
+				"2,Rise of the Heroes,2023-02-01,Rise of the Heroes,7.0,50",
+		},
+		{
+			name: "genres are never colored",
+			opts: outputOptions{NoHeader: true, ShowGenres: true, Color: true, HighlightGenres: map[int]bool{18: true}},
+			want: "1,L'Aube de l'Aventure,2023-01-01,Epic Journey Begins,8.5,100,drama\n" +
+				"2,Rise of the Heroes,2023-02-01,Rise of the Heroes,7.0,50,",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got, err := formatCSV(fakeMovies, tc.opts)
+			// Assert
+			assertNoError(t, err)
+			if got != tc.want {
+				t.Errorf("expected CSV:\n%s\nbut got:\n%s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestIntegrationFormatFlag_Header(t *testing.T) {
+	testCases := []struct {
+		name       string
+		args       []string
+		wantHeader bool
+	}{
+		{name: "header by default", args: nil, wantHeader: true},
+		{name: "explicit header", args: []string{"--header"}, wantHeader: true},
+		{name: "no header", args: []string{"--no-header"}},
+		{name: "header false", args: []string{"--header=false"}},
+		{name: "no header false", args: []string{"--no-header=false"}, wantHeader: true},
+		{name: "no header last", args: []string{"--header", "--no-header"}},
+		{name: "header last", args: []string{"--no-header", "--header"}, wantHeader: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			got, err := executeCommandStdout(root, append([]string{"list", "-p", "-m=2", "--format=csv"}, tc.args...)...)
+			// Assert
+			assertNoError(t, err)
+			lines := strings.Split(strings.TrimSpace(got), "\n")
+			if hasHeader := strings.HasPrefix(lines[0], "#,"); hasHeader != tc.wantHeader {
+				t.Errorf("expected header %t, but got:\n%s", tc.wantHeader, got)
+			}
+			if wantLines := map[bool]int{true: 3, false: 2}[tc.wantHeader]; len(lines) != wantLines {
+				t.Errorf("expected %d lines, but got %d:\n%s", wantLines, len(lines), got)
+			}
+		})
+	}
+}
+
+func TestIntegrationConfigDefaults(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config map[string]any
+		args   []string
+		check  func(t *testing.T, got string)
+	}{
+		{
+			name:   "default format",
+			config: map[string]any{"default_format": "json"},
+			args:   []string{"list", "-p"},
+			check:  func(t *testing.T, got string) { assertContains(t, got, []string{`"id": 1`}) },
+		},
+		{
+			name:   "flag overrides default format",
+			config: map[string]any{"default_format": "json"},
+			args:   []string{"list", "-p", "--format=table"},
+			check:  func(t *testing.T, got string) { assertContains(t, got, []string{"ORIGINAL TITLE"}) },
+		},
+		{
+			name:   "default max items",
+			config: map[string]any{"default_max_items": 3},
+			args:   []string{"discover", "-l=fr", "--format=json"},
+			check: func(t *testing.T, got string) {
+				var decoded movies
+				json.Unmarshal([]byte(got), &decoded)
+				assertMovieIDs(t, []int{1, 2, 3}, decoded)
+			},
+		},
+		{
+			name:   "flag overrides default max items",
+			config: map[string]any{"default_max_items": 3},
+			args:   []string{"discover", "-l=fr", "--format=json", "-m=2"},
+			check: func(t *testing.T, got string) {
+				var decoded movies
+				json.Unmarshal([]byte(got), &decoded)
+				assertMovieIDs(t, []int{1, 2}, decoded)
+			},
+		},
+		{
+			name:   "default sort",
+			config: map[string]any{"default_sort": "date,desc", "default_max_items": 3},
+			args:   []string{"discover", "-l=fr", "--format=json", "--output-sort-key="},
+			check: func(t *testing.T, got string) {
+				var decoded movies
+				json.Unmarshal([]byte(got), &decoded)
+				assertMovieIDs(t, []int{3, 2, 1}, decoded)
+			},
+		},
+		{
+			name:   "flag overrides default sort",
+			config: map[string]any{"default_sort": "date,desc", "default_max_items": 3},
+			args:   []string{"discover", "-l=fr", "--format=json", "-s=date,asc"},
+			check: func(t *testing.T, got string) {
+				var decoded movies
+				json.Unmarshal([]byte(got), &decoded)
+				assertMovieIDs(t, []int{1, 2, 3}, decoded)
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			for key, value := range tc.config {
+				viper.Set(key, value)
+				t.Cleanup(func() { viper.Set(key, "") })
+			}
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			got, err := executeCommandStdout(root, tc.args...)
+			// Assert
+			assertNoError(t, err)
+			tc.check(t, got)
+		})
+	}
+}
+
+func TestUnitParseFields(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		wantKeys []string
+		wantErr  bool
+	}{
+		{name: "no selection", value: ""},
+		{name: "in order", value: "title,average", wantKeys: []string{"title", "vote_average"}},
+		{name: "spaces and case", value: " Average , ID ", wantKeys: []string{"vote_average", "id"}},
+		{name: "unknown field", value: "title,budget", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got, err := parseFields(tc.value)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				return
+			}
+			assertNoError(t, err)
+			var keys []string
+			for _, f := range got {
+				keys = append(keys, f.Key)
+			}
+			if !reflect.DeepEqual(tc.wantKeys, keys) {
+				t.Errorf("expected keys %v, but got %v", tc.wantKeys, keys)
+			}
+		})
+	}
+}
+
+func TestUnitFormatURLs(t *testing.T) {
+	fakeMovies := movies{
+		{ID: 603, PosterPath: "/matrix.jpg", BackdropPath: "/matrix-backdrop.jpg"},
+		{ID: 604, PosterPath: " "},
+		{ID: 605, BackdropPath: "/revolutions-backdrop.jpg"},
+	}
+	testCases := []struct {
+		kind string
+		want string
+	}{
+		{
+			kind: "tmdb-page",
+			want: "https://www.themoviedb.org/movie/603\n" +
+				"https://www.themoviedb.org/movie/604\n" +
+				"https://www.themoviedb.org/movie/605",
+		},
+		{kind: "poster", want: "https://image.tmdb.org/t/p/original/matrix.jpg"},
+		{
+			kind: "backdrop",
+			want: "https://image.tmdb.org/t/p/original/matrix-backdrop.jpg\n" +
+				"https://image.tmdb.org/t/p/original/revolutions-backdrop.jpg",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.kind, func(t *testing.T) {
+			// Act
+			got := formatURLs(fakeMovies, tc.kind)
+			// Assert
+			if got != tc.want {
+				t.Errorf("expected URLs:\n%s\nbut got:\n%s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestIntegrationFormatFlag_URLsOnly(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{name: "default kind", args: []string{"--urls-only"}},
+		{name: "json format ignored", args: []string{"--urls-only", "--format=json"}},
+		{name: "unknown kind", args: []string{"--urls-only", "--url-kind=trailer"}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			got, err := executeCommandStdout(root, append([]string{"list", "-p", "-m=5"}, tc.args...)...)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				return
+			}
+			assertNoError(t, err)
+			lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+			if len(lines) != 5 {
+				t.Fatalf("expected one URL per movie, but got:\n%s", got)
+			}
+			for i, line := range lines {
+				if want := fmt.Sprintf("https://www.themoviedb.org/movie/%d", fakeMovieList[i].ID); line != want {
+					t.Errorf("expected URL %q, but got %q", want, line)
+				}
+			}
+		})
+	}
+}
+
+func TestIntegrationFormatFlag_Fields(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "json",
+			args: []string{"--format=json", "--json-compact"},
+			want: `[{"title":"Epic Journey Begins","vote_average":8.5},{"title":"Rise of the Heroes","vote_average":7}]`,
+		},
+		{
+			name: "csv",
+			args: []string{"--format=csv"},
+			want: "Title,Average\nEpic Journey Begins,8.5\nRise of the Heroes,7.0",
+		},
+		{
+			name: "yaml",
+			args: []string{"--format=yaml"},
+			want: "- title: Epic Journey Begins\n  vote_average: 8.5\n- title: Rise of the Heroes\n  vote_average: 7",
+		},
+		{name: "unknown field", args: []string{"--format=json", "--fields=title,budget"}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			args := append([]string{"list", "-p", "-m=2", "--fields=title,average"}, tc.args...)
+			// Act
+			got, err := executeCommandStdout(root, args...)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				return
+			}
+			assertNoError(t, err)
+			if strings.TrimSpace(got) != tc.want {
+				t.Errorf("expected output:\n%s\nbut got:\n%s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestIntegrationFormatFlag_FieldsTable(t *testing.T) {
+	// Arrange
+	ts := newFakeTMDBServer(t)
+	root := newMockRootCmd(t, ts.URL)
+	// Act
+	got, err := executeCommand(root, "list", "-p", "-m=2", "--fields=id,title")
+	// Assert
+	assertNoError(t, err)
+	assertContains(t, got, []string{"ID", "TITLE", "Epic Journey Begins"})
+	for _, absent := range []string{"ORIGINAL TITLE", "AVERAGE", "#"} {
+		if strings.Contains(got, absent) {
+			t.Errorf("expected no %q column, but got:\n%s", absent, got)
+		}
+	}
+}
+
+func TestUnitFormatJSON_Compact(t *testing.T) {
+	// Act
+	got, err := formatJSON(fakeMovieList[:3], true)
+	// Assert
+	assertNoError(t, err)
+	if strings.Contains(got, "\n") {
+		t.Errorf("expected compact JSON on a single line, but got %q", got)
+	}
+	var decoded movies
+	assertNoError(t, json.Unmarshal([]byte(got), &decoded))
+	assertMovieIDs(t, []int{1, 2, 3}, decoded)
+}
+
+func TestUnitFormatMovies_MetaFields(t *testing.T) {
+	// Arrange
+	fields, _ := parseFields("title")
+	opts := outputOptions{
+		JSONCompact: true,
+		Fields:      fields,
+		Meta:        &resultsEnvelope{URL: "https://api.themoviedb.org/3/discover/movie?", TotalResults: 40},
+	}
+	// Act
+	got, err := formatMovies(fakeMovieList[:2], "json", opts)
+	// Assert
+	assertNoError(t, err)
+	want := `{"query":{},"url":"https://api.themoviedb.org/3/discover/movie?","total_results":40,` +
+		`"results":[{"title":"Epic Journey Begins"},{"title":"Rise of the Heroes"}]}`
+	if got != want {
+		t.Errorf("expected %s, but got %s", want, got)
+	}
+}
+
+func TestUnitFormatYAML(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input movies
+	}{
+		{name: "movies", input: fakeMovieList[:3]},
+		{name: "empty", input: movies{}},
+		{name: "nil", input: nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got, err := formatMovies(tc.input, "yaml", outputOptions{})
+			// Assert
+			assertNoError(t, err)
+			var decoded movies
+			if err := yaml.Unmarshal([]byte(got), &decoded); err != nil {
+				t.Fatalf("unmarshal YAML output: %v", err)
+			}
+			if len(tc.input) == 0 {
+				if got != "[]" {
+					t.Errorf("expected empty YAML output %q, but got %q", "[]", got)
+				}
+				return
+			}
+			if !reflect.DeepEqual(tc.input, decoded) {
+				t.Errorf("expected movies %+v, but got %+v", tc.input, decoded)
+			}
+			assertContains(t, got, []string{"original_title:", "vote_average:"})
+		})
+	}
+}
+
+func TestIntegrationFormatFlag(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "list yaml",
+			args: []string{"list", "-p", "--format=yaml"},
+			want: []string{"- id: 1", "title: Epic Journey Begins"},
+		},
+		{name: "discover yaml", args: []string{"discover", "-l=fr", "--format=yaml"}, want: []string{"- id: 1"}},
+		{name: "discover table", args: []string{"discover", "-l=fr", "--format=table"}, want: []string{"ORIGINAL TITLE"}},
+		{name: "list json", args: []string{"list", "-p", "--format=json"}, want: []string{"[\n  {\n    \"id\": 1,"}},
+		{
+			name: "list compact json",
+			args: []string{"list", "-p", "--format=json", "--json-compact"},
+			want: []string{`[{"id":1,`, `},{"id":2,`},
+		},
+		{name: "list csv", args: []string{"list", "-p", "--format=csv"}, want: []string{"#,Original Title,", "1,"}},
+		{name: "discover table without header", args: []string{"discover", "-l=fr", "--no-header"}, want: []string{"Epic"}},
+		{name: "unknown format", args: []string{"list", "-p", "--format=xml"}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			got, err := executeCommand(root, tc.args...)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+			} else {
+				assertNoError(t, err)
+				assertContains(t, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUnitCommandExamples(t *testing.T) {
+	examples := commandExamples(newRootCmd("config.yaml"))
+	if len(examples) < 20 {
+		t.Fatalf("expected the examples of every command, but got %d", len(examples))
+	}
+	// Arrange, shared by the examples: a home holding the configuration of doctor and
+	// the files of merge, as the working directory
+	home := t.TempDir()
+	os.Mkdir(filepath.Join(home, appDir), 0o700)
+	os.WriteFile(filepath.Join(home, appDir, "config.yaml"), []byte("api_key: valid_api_key"), 0o600)
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	saved, _ := json.Marshal(fakeMovieList[:3])
+	for _, name := range []string{"popular.json", "top.json", "week1.json", "week2.json"} {
+		os.WriteFile(filepath.Join(home, name), saved, 0o600)
+	}
+	wd, _ := os.Getwd()
+	if err := os.Chdir(home); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	pages := newFakeTMDBServer(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requireAPIKey(t, w, r)
+		var body any
+		switch path := r.URL.Path; {
+		case r.Method == http.MethodPost || path == "/authentication":
+			body = map[string]any{"success": true, "status_code": 1}
+		case path == "/collection/119":
+			body = collectionResponse{ID: 119, Name: "The Matrix Collection", Parts: fakeMovieList[:3]}
+		case path == "/movie/603" || path == "/movie/604":
+			id, _ := strconv.Atoi(strings.TrimPrefix(path, "/movie/"))
+			body = movieDetails{ID: id, Title: "The Matrix", ReleaseDate: "1999-03-31", Runtime: 136}
+		case path == "/find/tt0133093":
+			body = findResponse{MovieResults: fakeMovieList[:1]}
+		case path == "/account":
+			body = accountResponse{ID: 42}
+		default: // The pages of discover, list and the watchlist
+			pages.Config.Handler.ServeHTTP(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(ts.Close)
+	for _, args := range examples {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			// Arrange
+			root := newMockRootCmd(t, ts.URL)
+			// Act
+			cmd, _, err := executeCommandC(root, args...)
+			// Assert
+			assertNoError(t, err)
+			if cmd == root {
+				t.Errorf("expected %q to run a subcommand", args)
+			}
+		})
+	}
+}
+
+func TestIntegrationExecute_JSONErrors(t *testing.T) {
+	testCases := []struct {
+		name        string
+		args        []string
+		wantField   string
+		wantMessage string
+	}{
+		{
+			name:        "bad year",
+			args:        []string{"discover", "-y=1500", "--json-errors"},
+			wantField:   "year",
+			wantMessage: "year must be between 1888 and",
+		},
+		{
+			name:        "bad genre",
+			args:        []string{"discover", "-g=drama,invalid", "--json-errors"},
+			wantField:   "genres",
+			wantMessage: "validation error: genre must be one of these genres",
+		},
+		{
+			name:        "error without field",
+			args:        []string{"discover", "-g=drama", "-m=0", "--json-errors"},
+			wantMessage: "validation error: items must be ≥ 1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := newFakeTMDBServer(t)
+			root := newMockRootCmd(t, ts.URL)
+			stderr := new(bytes.Buffer)
+			root.SetErr(stderr)
+			root.SetArgs(tc.args)
+			// Act
+			err := execute(root)
+			// Assert
+			assertNotNil(t, err)
+			var got ValidationError
+			assertNoError(t, json.Unmarshal(stderr.Bytes(), &got))
+			if got.Field != tc.wantField || !strings.HasPrefix(got.Message, tc.wantMessage) {
+				t.Errorf("expected the field %q and a message starting with %q, but got %+v",
+					tc.wantField, tc.wantMessage, got)
+			}
+		})
+	}
+}
+
+func TestIntegrationExecute_PlainErrors(t *testing.T) {
+	// Arrange
+	ts := newFakeTMDBServer(t)
+	root := newMockRootCmd(t, ts.URL)
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	root.SetOut(stdout)
+	root.SetErr(stderr)
+	root.SetArgs([]string{"discover", "-y=1500"})
+	// Act
+	err := execute(root)
+	// Assert
+	assertNotNil(t, err)
+	assertContains(t, stderr.String(), []string{"Error: year must be between"})
+	assertContains(t, stdout.String(), []string{"Usage:"})
+}
+
+func TestIntegrationExecute_FailOnEmpty(t *testing.T) {
+	testCases := []struct {
+		name       string
+		args       []string
+		results    movies
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:       "empty without the flag",
+			args:       []string{"discover", "-g=drama"},
+			wantStdout: "No results available",
+		},
+		{
+			name:       "empty with the flag",
+			args:       []string{"discover", "-g=drama", "--fail-on-empty"},
+			wantCode:   exitNoResults,
+			wantStdout: "No results available",
+			wantStderr: "Error: no results found",
+		},
+		{
+			name:     "empty with the flag quietly",
+			args:     []string{"discover", "-g=drama", "--fail-on-empty", "--quiet"},
+			wantCode: exitNoResults,
+		},
+		{
+			name:       "empty list with the flag",
+			args:       []string{"list", "-p", "-t", "--fail-on-empty"},
+			wantCode:   exitNoResults,
+			wantStdout: "No results available",
+			wantStderr: "Error: no results found",
+		},
+		{
+			name:     "empty list with the flag quietly",
+			args:     []string{"list", "-p", "-t", "--fail-on-empty", "--quiet"},
+			wantCode: exitNoResults,
+		},
+		{
+			name:       "results with the flag",
+			args:       []string{"discover", "-g=drama", "--fail-on-empty"},
+			results:    fakeMovieList[:2],
+			wantStdout: "Epic Journey Begins",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				byt, _ := json.Marshal(tmdbResponse{
+					Page: 1, Results: tc.results, TotalPages: 1, TotalResults: len(tc.results),
+				})
+				w.Write(byt)
+			}))
+			t.Cleanup(ts.Close)
+			root := newMockRootCmd(t, ts.URL)
+			stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+			root.SetOut(stdout)
+			root.SetErr(stderr)
+			root.SetArgs(tc.args)
+			// Act
+			err := execute(root)
+			// Assert
+			if got := exitCode(err); got != tc.wantCode {
+				t.Errorf("expected exit code %d, but got %d (%v)", tc.wantCode, got, err)
+			}
+			assertContains(t, stdout.String(), []string{tc.wantStdout})
+			assertNotContains(t, stdout.String(), []string{"Usage:"})
+			if tc.wantStdout == "" && stdout.Len() > 0 {
+				t.Errorf("expected nothing on stdout, but got %q", stdout.String())
+			}
+			if tc.wantStderr == "" && stderr.Len() > 0 {
+				t.Errorf("expected nothing on stderr, but got %q", stderr.String())
+			}
+			assertContains(t, stderr.String(), []string{tc.wantStderr})
+		})
+	}
+}
+
+func TestUnitExitCode(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "success", want: 0},
+		{name: "error", err: errors.New("validation error"), want: exitError},
+		{name: "no results", err: errNoResults, want: exitNoResults},
+		{name: "wrapped no results", err: fmt.Errorf("discover: %w", errNoResults), want: exitNoResults},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got := exitCode(tc.err)
+			// Assert
+			if got != tc.want {
+				t.Errorf("expected %d, but got %d", tc.want, got)
+			}
+		})
+	}
+}
+
 func TestIntegrationInfoCmd(t *testing.T) {
 	// Arrange
 	home, _ := os.UserHomeDir()
@@ -2,16 +2,25 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 )
 
-// userHome enables testable home directory resolution across OS environments.
+// userHome enables testable home directory and environment resolution across OS environments.
 type userHome interface {
 	dir() (string, error)
+	lookupEnv(key string) (string, bool)
 }
 
 // defaultUserHome implements userHome using actual OS home directory lookup.
@@ -21,20 +30,162 @@ func (u *defaultUserHome) dir() (string, error) {
 	return os.UserHomeDir()
 }
 
-// initialize loads config file and validates API key for TMDB access.
-func initialize(userHome userHome, fileName string) error {
+func (u *defaultUserHome) lookupEnv(key string) (string, bool) {
+	return os.LookupEnv(key)
+}
+
+const (
+	// appDir is the legacy directory, relative to the user home, for CLI files.
+	appDir = ".go-tmdb-cli"
+	// xdgAppDir is the directory name used under XDG base directories.
+	xdgAppDir = "go-tmdb-cli"
+)
+
+// configDir resolves the base directory for config and other CLI files: the
+// override when set, then $XDG_CONFIG_HOME/go-tmdb-cli, then ~/.go-tmdb-cli.
+// An existing legacy directory still wins over a not yet created XDG one.
+func configDir(userHome userHome, override string) (string, error) {
+	if override != "" {
+		return override, nil
+	}
 	home, err := userHome.dir()
 	if err != nil {
-		return fmt.Errorf("get user home directory: %w", err)
+		return "", fmt.Errorf("get user home directory: %w", err)
+	}
+	legacyDir := filepath.Join(home, appDir)
+	xdgHome, ok := userHome.lookupEnv("XDG_CONFIG_HOME")
+	if !ok || xdgHome == "" {
+		return legacyDir, nil
 	}
-	cfgPath := filepath.Join(home, ".go-tmdb-cli", fileName)
+	xdgDir := filepath.Join(xdgHome, xdgAppDir)
+	if !dirExists(xdgDir) && dirExists(legacyDir) {
+		return legacyDir, nil
+	}
+	return xdgDir, nil
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// initialize loads config file and validates API key for TMDB access.
+func initialize(userHome userHome, baseDir, fileName string) error {
+	dir, err := configDir(userHome, baseDir)
+	if err != nil {
+		return err
+	}
+	cfgPath := filepath.Join(dir, fileName)
 	byt, err := os.ReadFile(cfgPath)
 	if err != nil {
 		return fmt.Errorf("read the configuration file: %w ", err)
 	}
 	viper.SetConfigType("yaml")
-	if err := viper.ReadConfig(bytes.NewBuffer(byt)); err != nil {
-		return fmt.Errorf("parse the configuration file: %w", err)
+	err = checkConfigYAML(byt)
+	if err == nil {
+		err = viper.ReadConfig(bytes.NewBuffer(byt))
+	}
+	if err != nil {
+		return fmt.Errorf("parse the configuration file %s: %w, expected settings like \"api_key: VALUE\"",
+			cfgPath, err)
+	}
+	return nil
+}
+
+// checkConfigYAML verifies the configuration is a YAML mapping of settings, locating
+// the problem by line, and column when known, otherwise.
+func checkConfigYAML(byt []byte) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(byt, &doc); err != nil {
+		return errors.New(strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	if root := doc.Content[0]; root.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d, column %d: not a mapping of settings", root.Line, root.Column)
+	}
+	return nil
+}
+
+// resolveAPIKey picks the TMDB API key, by order of precedence: from the keyFile
+// flag, from the file set by api_key_file, then from the inline api_key.
+func resolveAPIKey(keyFile string) (string, error) {
+	if keyFile == "" {
+		keyFile = viper.GetString("api_key_file")
+	}
+	if keyFile == "" {
+		return viper.GetString("api_key"), nil
+	}
+	byt, err := os.ReadFile(keyFile)
+	if err != nil {
+		return "", fmt.Errorf("read the API key file: %w", err)
+	}
+	key := strings.TrimSpace(string(byt))
+	if key == "" {
+		return "", fmt.Errorf("read the API key file: %s is empty", keyFile)
+	}
+	return key, nil
+}
+
+// lastRunFile stores the time of the last successful run of each discover query.
+const lastRunFile = "last_run.json"
+
+// queryKey identifies a discover query by a hash of its URL query string.
+func queryKey(url string) string {
+	_, query, _ := strings.Cut(url, "?")
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:8])
+}
+
+// readLastRuns loads the last-run times stored under dir, empty when none were saved.
+func readLastRuns(dir string) (map[string]time.Time, error) {
+	runs := make(map[string]time.Time)
+	byt, err := os.ReadFile(filepath.Join(dir, lastRunFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return runs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read the last run file: %w", err)
+	}
+	if err := json.Unmarshal(byt, &runs); err != nil {
+		return nil, fmt.Errorf("parse the last run file: %w", err)
+	}
+	return runs, nil
+}
+
+// readLastRun returns the time of the last successful run of a query, if any.
+func readLastRun(dir, key string) (time.Time, bool, error) {
+	runs, err := readLastRuns(dir)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	last, ok := runs[key]
+	return last, ok, nil
+}
+
+// writeLastRun records the time of a successful run of a query, creating dir if
+// needed, e.g. when the API key comes from the environment.
+func writeLastRun(dir, key string, t time.Time) error {
+	runs, err := readLastRuns(dir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("create the configuration directory: %w", err)
+	}
+	runs[key] = t
+	byt, err := json.MarshalIndent(runs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode the last run file: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, lastRunFile), byt, 0o600); err != nil {
+		return fmt.Errorf("write the last run file: %w", err)
 	}
 	return nil
 }
+
+// sinceBoundary is the primary_release_date.gte day for movies released since t.
+func sinceBoundary(t time.Time) string {
+	return t.In(yearLocation).Format(time.DateOnly)
+}
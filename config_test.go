@@ -5,6 +5,9 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/spf13/viper"
 )
 
 type mockUserHome struct{}
@@ -13,6 +16,25 @@ func (m *mockUserHome) dir() (string, error) {
 	return "", fmt.Errorf("home dir not found")
 }
 
+func (m *mockUserHome) lookupEnv(key string) (string, bool) {
+	return "", false
+}
+
+// fakeUserHome resolves a fixed home directory and environment.
+type fakeUserHome struct {
+	home string
+	env  map[string]string
+}
+
+func (f *fakeUserHome) dir() (string, error) {
+	return f.home, nil
+}
+
+func (f *fakeUserHome) lookupEnv(key string) (string, bool) {
+	v, ok := f.env[key]
+	return v, ok
+}
+
 func TestUnitInitialize(t *testing.T) {
 	testCases := []struct {
 		name             string
@@ -63,9 +85,9 @@ func TestUnitInitialize(t *testing.T) {
 			}
 			// Act
 			if tc.wantMockUserHome {
-				err = initialize(&mockUserHome{}, configFile)
+				err = initialize(&mockUserHome{}, "", configFile)
 			} else {
-				err = initialize(&defaultUserHome{}, configFile)
+				err = initialize(&defaultUserHome{}, "", configFile)
 			}
 			// Assert
 			if tc.wantErr {
@@ -76,3 +98,211 @@ func TestUnitInitialize(t *testing.T) {
 		})
 	}
 }
+
+func TestUnitInitialize_ParseError(t *testing.T) {
+	testCases := []struct {
+		name        string
+		fileContent string
+		wantLine    string
+	}{
+		{name: "not a mapping", fileContent: "invalid:yaml:content", wantLine: "line 1, column 1"},
+		{name: "bad indentation", fileContent: "api_key: api_value\n  - default_format: json", wantLine: "line 2"},
+		{name: "duplicate key", fileContent: "api_key: a\napi_key: b", wantLine: "line 2"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			dir := t.TempDir()
+			cfgPath := filepath.Join(dir, "config.yaml")
+			os.WriteFile(cfgPath, []byte(tc.fileContent), 0o600)
+			// Act
+			err := initialize(&mockUserHome{}, dir, "config.yaml")
+			// Assert
+			assertNotNil(t, err)
+			assertContains(t, err.Error(), []string{cfgPath, tc.wantLine, `"api_key: VALUE"`})
+		})
+	}
+}
+
+func TestUnitInitialize_ConfigDirOverride(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("api_key: override_value"), 0o600)
+	// Act
+	err := initialize(&mockUserHome{}, dir, "config.yaml") // Home lookup must be skipped
+	// Assert
+	assertNoError(t, err)
+	if got := viper.GetString("api_key"); got != "override_value" {
+		t.Errorf("expected api key %q, but got %q", "override_value", got)
+	}
+}
+
+func TestUnitConfigDir(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	testCases := []struct {
+		name     string
+		userHome userHome
+		override string
+		want     string
+		wantErr  bool
+	}{
+		{name: "default", userHome: &defaultUserHome{}, want: filepath.Join(home, ".go-tmdb-cli")},
+		{name: "override", userHome: &mockUserHome{}, override: "/custom/dir", want: "/custom/dir"},
+		{name: "missing home dir", userHome: &mockUserHome{}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got, err := configDir(tc.userHome, tc.override)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+			} else {
+				assertNoError(t, err)
+				if got != tc.want {
+					t.Errorf("expected config dir %q, but got %q", tc.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestUnitConfigDir_XDG(t *testing.T) {
+	testCases := []struct {
+		name         string
+		setXDG       bool
+		createXDG    bool
+		createLegacy bool
+		wantXDG      bool
+	}{
+		{name: "xdg unset uses legacy dir", createLegacy: true},
+		{name: "xdg set uses xdg dir", setXDG: true, wantXDG: true},
+		{name: "xdg set with existing xdg dir", setXDG: true, createXDG: true, createLegacy: true, wantXDG: true},
+		{name: "xdg set keeps existing legacy dir", setXDG: true, createLegacy: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			home := t.TempDir()
+			xdgHome := t.TempDir()
+			legacyDir := filepath.Join(home, ".go-tmdb-cli")
+			xdgDir := filepath.Join(xdgHome, "go-tmdb-cli")
+			if tc.createLegacy {
+				os.MkdirAll(legacyDir, 0o755)
+			}
+			if tc.createXDG {
+				os.MkdirAll(xdgDir, 0o755)
+			}
+			env := map[string]string{}
+			if tc.setXDG {
+				env["XDG_CONFIG_HOME"] = xdgHome
+			}
+			// Act
+			got, err := configDir(&fakeUserHome{home: home, env: env}, "")
+			// Assert
+			assertNoError(t, err)
+			want := legacyDir
+			if tc.wantXDG {
+				want = xdgDir
+			}
+			if got != want {
+				t.Errorf("expected config dir %q, but got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestUnitResolveAPIKey(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "tmdb")
+	os.WriteFile(keyFile, []byte("  file_api_key\n"), 0o600)
+	otherKeyFile := filepath.Join(dir, "other")
+	os.WriteFile(otherKeyFile, []byte("other_api_key"), 0o600)
+	emptyKeyFile := filepath.Join(dir, "empty")
+	os.WriteFile(emptyKeyFile, []byte("\n"), 0o600)
+	testCases := []struct {
+		name       string
+		apiKey     string
+		apiKeyFile string
+		flagFile   string
+		want       string
+		wantErr    bool
+	}{
+		{name: "inline api key", apiKey: "inline_api_key", want: "inline_api_key"},
+		{name: "config key file over inline", apiKey: "inline_api_key", apiKeyFile: keyFile, want: "file_api_key"},
+		{name: "flag over config key file", apiKeyFile: keyFile, flagFile: otherKeyFile, want: "other_api_key"},
+		{name: "missing key file", flagFile: filepath.Join(dir, "missing"), wantErr: true},
+		{name: "empty key file", apiKeyFile: emptyKeyFile, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			viper.Set("api_key", tc.apiKey)
+			viper.Set("api_key_file", tc.apiKeyFile)
+			t.Cleanup(func() {
+				viper.Set("api_key", "")
+				viper.Set("api_key_file", "")
+			})
+			// Act
+			got, err := resolveAPIKey(tc.flagFile)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				return
+			}
+			assertNoError(t, err)
+			if got != tc.want {
+				t.Errorf("expected API key %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUnitLastRun(t *testing.T) {
+	// Arrange
+	dir := filepath.Join(t.TempDir(), "go-tmdb-cli") // Not created yet
+	key := queryKey("https://api.themoviedb.org/3/discover/movie?with_genres=27")
+	runAt := time.Date(2025, 3, 14, 23, 30, 0, 0, time.UTC)
+	// Act
+	_, foundBefore, errBefore := readLastRun(dir, key)
+	errWrite := writeLastRun(dir, key, runAt)
+	got, found, err := readLastRun(dir, key)
+	// Assert
+	assertNoError(t, errBefore)
+	assertNoError(t, errWrite)
+	assertNoError(t, err)
+	if foundBefore {
+		t.Error("expected no last run before the first write")
+	}
+	if !found || !got.Equal(runAt) {
+		t.Errorf("expected last run %v, but got %v (found: %t)", runAt, got, found)
+	}
+	if other := queryKey("http://localhost:1234/discover/movie?with_genres=27"); other != key {
+		t.Errorf("expected the key to depend on the query only, but got %q and %q", key, other)
+	}
+}
+
+func TestUnitSinceBoundary(t *testing.T) {
+	testCases := []struct {
+		name     string
+		location *time.Location
+		want     string
+	}{
+		{name: "UTC", location: time.UTC, want: "2025-03-14"},
+		{name: "ahead of UTC", location: time.FixedZone("UTC+2", 2*60*60), want: "2025-03-15"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			previous := yearLocation
+			yearLocation = tc.location
+			t.Cleanup(func() { yearLocation = previous })
+			// Act
+			got := sinceBoundary(time.Date(2025, 3, 14, 23, 30, 0, 0, time.UTC))
+			// Assert
+			if got != tc.want {
+				t.Errorf("expected boundary %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// doctorTimeout bounds each network check of the doctor command.
+const doctorTimeout = 5 * time.Second
+
+// checkResult is one line of the doctor checklist, with a hint when it fails.
+type checkResult struct {
+	Name   string
+	OK     bool
+	Detail string
+	Hint   string
+}
+
+func (c checkResult) String() string {
+	if c.OK {
+		return fmt.Sprintf("[ok]   %s: %s", c.Name, c.Detail)
+	}
+	return fmt.Sprintf("[fail] %s: %s\n       hint: %s", c.Name, c.Detail, c.Hint)
+}
+
+// checkConfigFile loads the configuration file like any other command does.
+func checkConfigFile(userHome userHome, dir, fileName string) checkResult {
+	result := checkResult{Name: "config file", Detail: filepath.Join(dir, fileName)}
+	if err := initialize(userHome, dir, fileName); err != nil {
+		result.Detail = err.Error()
+		result.Hint = fmt.Sprintf("create %s holding a line like: api_key: your_api_key",
+			filepath.Join(dir, fileName))
+		return result
+	}
+	result.OK = true
+	return result
+}
+
+// checkAPIKey verifies an API key is set, once the configuration is loaded.
+func checkAPIKey(keyFile string) (checkResult, string) {
+	result := checkResult{Name: "API key"}
+	key, err := resolveAPIKey(keyFile)
+	switch {
+	case err != nil:
+		result.Detail = err.Error()
+		result.Hint = "point --api-key-file or api_key_file to a readable file holding the key"
+	case key == "":
+		result.Detail = "missing"
+		result.Hint = "set api_key or api_key_file in the configuration file, " +
+			"get a key at https://www.themoviedb.org/settings/api"
+	default:
+		result.OK = true
+		result.Detail = "set"
+	}
+	return result, key
+}
+
+// checkConfigDirWritable verifies the CLI can store files, such as the last runs,
+// in the configuration directory.
+func checkConfigDirWritable(dir string) checkResult {
+	result := checkResult{Name: "config dir writable", Detail: dir}
+	file, err := os.CreateTemp(dir, ".doctor_*")
+	if err != nil {
+		result.Detail = err.Error()
+		result.Hint = fmt.Sprintf("create %s and make it writable by your user", dir)
+		return result
+	}
+	file.Close()
+	os.Remove(file.Name())
+	result.OK = true
+	return result
+}
+
+// checkReachability verifies the TMDB API answers at all, whatever the status.
+func checkReachability(ctx context.Context, client *http.Client, baseURL string) checkResult {
+	result := checkResult{Name: "network", Detail: baseURL}
+	ctx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, baseURL, nil)
+	if err != nil {
+		result.Detail = err.Error()
+		result.Hint = "check the TMDB API base URL"
+		return result
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		result.Detail = err.Error()
+		result.Hint = "check your connection, DNS and proxy settings (HTTPS_PROXY)"
+		return result
+	}
+	res.Body.Close()
+	result.OK = true
+	return result
+}
+
+// checkAuth validates the API key against TMDB's authentication endpoint.
+func checkAuth(ctx context.Context, hc *httpClient, baseURL string) checkResult {
+	result := checkResult{Name: "API key valid"}
+	ctx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	defer cancel()
+	var auth apiError
+	err := hc.do(ctx, http.MethodGet, strings.TrimSuffix(baseURL, "/")+"/authentication", nil, &auth)
+	var statusErr *statusError
+	var expired *expiredTokenError
+	switch {
+	case errors.As(err, &expired):
+		result.Detail = fmt.Sprintf("expired on %s", expired.Expiry.Format(time.DateOnly))
+		result.Hint = "regenerate the access token at https://www.themoviedb.org/settings/api"
+	case errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusUnauthorized:
+		result.Detail = "rejected by TMDB"
+		result.Hint = "copy the API Read Access Token from https://www.themoviedb.org/settings/api"
+	case err != nil:
+		result.Detail = err.Error()
+		result.Hint = "retry later, TMDB may be unavailable"
+	default:
+		result.OK = true
+		result.Detail = "accepted by TMDB"
+	}
+	return result
+}
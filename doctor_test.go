@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestUnitCheckConfigFile(t *testing.T) {
+	testCases := []struct {
+		name        string
+		fileContent string
+		missing     bool
+		wantOK      bool
+	}{
+		{name: "valid config", fileContent: "api_key: api_value", wantOK: true},
+		{name: "missing config", missing: true},
+		{name: "invalid yaml", fileContent: "invalid:yaml:content"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			dir := t.TempDir()
+			if !tc.missing {
+				os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(tc.fileContent), 0o600)
+			}
+			// Act
+			got := checkConfigFile(&defaultUserHome{}, dir, "config.yaml")
+			// Assert
+			if got.OK != tc.wantOK {
+				t.Errorf("expected ok %t, but got %+v", tc.wantOK, got)
+			}
+			if !got.OK && got.Hint == "" {
+				t.Errorf("expected a hint for a failed check, but got %+v", got)
+			}
+		})
+	}
+}
+
+func TestUnitCheckAPIKey(t *testing.T) {
+	testCases := []struct {
+		name     string
+		apiKey   string
+		flagFile string
+		wantOK   bool
+	}{
+		{name: "inline api key", apiKey: "inline_api_key", wantOK: true},
+		{name: "missing api key"},
+		{name: "unreadable key file", flagFile: filepath.Join(t.TempDir(), "missing")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			viper.Set("api_key", tc.apiKey)
+			t.Cleanup(func() { viper.Set("api_key", "") })
+			// Act
+			got, key := checkAPIKey(tc.flagFile)
+			// Assert
+			if got.OK != tc.wantOK {
+				t.Errorf("expected ok %t, but got %+v", tc.wantOK, got)
+			}
+			if tc.wantOK && key != tc.apiKey {
+				t.Errorf("expected API key %q, but got %q", tc.apiKey, key)
+			}
+		})
+	}
+}
+
+func TestUnitCheckConfigDirWritable(t *testing.T) {
+	testCases := []struct {
+		name   string
+		dir    string
+		wantOK bool
+	}{
+		{name: "writable dir", dir: t.TempDir(), wantOK: true},
+		{name: "missing dir", dir: filepath.Join(t.TempDir(), "missing")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got := checkConfigDirWritable(tc.dir)
+			// Assert
+			if got.OK != tc.wantOK {
+				t.Errorf("expected ok %t, but got %+v", tc.wantOK, got)
+			}
+			entries, _ := os.ReadDir(tc.dir)
+			if len(entries) != 0 {
+				t.Errorf("expected no file left behind, but got %v", entries)
+			}
+		})
+	}
+}
+
+func TestUnitCheckReachability(t *testing.T) {
+	testCases := []struct {
+		name   string
+		down   bool
+		wantOK bool
+	}{
+		{name: "reachable", wantOK: true},
+		{name: "unreachable", down: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound) // Any answer proves the API is reachable
+			}))
+			if tc.down {
+				ts.Close()
+			}
+			t.Cleanup(ts.Close)
+			// Act
+			got := checkReachability(context.Background(), http.DefaultClient, ts.URL)
+			// Assert
+			if got.OK != tc.wantOK {
+				t.Errorf("expected ok %t, but got %+v", tc.wantOK, got)
+			}
+		})
+	}
+}
+
+func TestUnitCheckAuth(t *testing.T) {
+	testCases := []struct {
+		name       string
+		apiKey     string
+		wantOK     bool
+		wantDetail string
+	}{
+		{name: "valid key", apiKey: "valid_api_key", wantOK: true, wantDetail: "accepted by TMDB"},
+		{name: "invalid key", apiKey: "invalid_api_key", wantDetail: "rejected by TMDB"},
+		{
+			name:       "expired token",
+			apiKey:     fakeExpiringToken(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)),
+			wantDetail: "expired on 2024-01-02",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/authentication" || r.Header.Get("Authorization") != "Bearer valid_api_key" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.Write([]byte(`{"success": true}`))
+			}))
+			t.Cleanup(ts.Close)
+			hc := newHTTPClient(tc.apiKey)
+			hc.NoRetry = true
+			// Act
+			got := checkAuth(context.Background(), hc, ts.URL)
+			// Assert
+			if got.OK != tc.wantOK || got.Detail != tc.wantDetail {
+				t.Errorf("expected ok %t with detail %q, but got %+v", tc.wantOK, tc.wantDetail, got)
+			}
+		})
+	}
+}
@@ -2,10 +2,22 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/cenkalti/backoff/v5"
 	"github.com/spf13/cobra"
 )
 
@@ -368,6 +380,158 @@ func executeCommandC(root *cobra.Command, args ...string) (c *cobra.Command, out
 	return c, buffer.String(), err
 }
 
+// executeCommandStdout runs the command like executeCommand, leaving stderr, e.g. the
+// hints, out of the output.
+func executeCommandStdout(root *cobra.Command, args ...string) (output string, err error) {
+	_, output, _, err = executeCommandStreams(root, args...)
+	return output, err
+}
+
+// executeCommandStreams runs the command like executeCommandC, keeping stderr apart.
+func executeCommandStreams(root *cobra.Command, args ...string) (c *cobra.Command, stdout, stderr string, err error) {
+	outBuf, errBuf := new(bytes.Buffer), new(bytes.Buffer)
+	root.SetOut(outBuf)
+	root.SetErr(errBuf)
+	root.SetArgs(args)
+	c, err = root.ExecuteC()
+	return c, outBuf.String(), errBuf.String(), err
+}
+
+// roundTripFunc adapts a function into an http.RoundTripper to stub transports.
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// noDelayBackOff retries right away, ignoring Retry-After headers, to test retries
+// without real sleeps.
+func noDelayBackOff() backoff.BackOff {
+	return &backoff.ZeroBackOff{}
+}
+
+// fakeTMDBServer is a test server recording the query of each received request.
+type fakeTMDBServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	paths   []string
+	queries []url.Values
+}
+
+// lastPath returns the path of the most recent request.
+func (f *fakeTMDBServer) lastPath() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.paths) == 0 {
+		return ""
+	}
+	return f.paths[len(f.paths)-1]
+}
+
+// lastQuery returns the query of the most recent request.
+func (f *fakeTMDBServer) lastQuery() url.Values {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.queries) == 0 {
+		return url.Values{}
+	}
+	return f.queries[len(f.queries)-1]
+}
+
+// requestedPaths returns the path of every request, in order.
+func (f *fakeTMDBServer) requestedPaths() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.paths)
+}
+
+// requestedPages returns the page query parameter of each request, in order.
+func (f *fakeTMDBServer) requestedPages() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pages := make([]string, 0, len(f.queries))
+	for _, q := range f.queries {
+		pages = append(pages, q.Get("page"))
+	}
+	return pages
+}
+
+// newFakeTMDBServer serves the fake pages according to the page query parameter.
+func newFakeTMDBServer(t testing.TB) *fakeTMDBServer {
+	t.Helper()
+	fake := &fakeTMDBServer{}
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		fake.paths = append(fake.paths, r.URL.Path)
+		fake.queries = append(fake.queries, r.URL.Query())
+		fake.mu.Unlock()
+		requireAPIKey(t, w, r)
+		res := fakeEmptyRes
+		switch page := r.URL.Query().Get("page"); page {
+		case "1":
+			res = fakeResPage1
+		case "2":
+			res = fakeResPage2
+		default:
+			// Past the last page, TMDB echoes the page number without results
+			if n, err := strconv.Atoi(page); err == nil && n > fakeResPage2.Page {
+				res = tmdbResponse{Page: n, Results: movies{}, TotalPages: 2, TotalResults: len(fakeMovieList)}
+			}
+		}
+		byt, _ := json.Marshal(res)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(byt)
+	}))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+// fakeV4Token builds an unsigned JWT shaped like a TMDB v4 access token, for the
+// account object ID in its "sub" claim.
+func fakeV4Token(accountObjectID string) string {
+	return fakeJWT(fmt.Sprintf(`{"aud":"fake","sub":%q,"scopes":["api_read","api_write"],"version":1}`,
+		accountObjectID))
+}
+
+// fakeExpiringToken builds an unsigned JWT whose "exp" claim is the given time.
+func fakeExpiringToken(exp time.Time) string {
+	return fakeJWT(fmt.Sprintf(`{"aud":"fake","sub":"4bc889a8","exp":%d}`, exp.Unix()))
+}
+
+// fakeJWT builds an unsigned JWT holding the given JSON payload.
+func fakeJWT(payload string) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	return encode([]byte(`{"alg":"HS256"}`)) + "." + encode([]byte(payload)) + ".signature"
+}
+
+// newMockRootCmd builds a root command whose dependencies target the given server.
+func newMockRootCmd(t testing.TB, serverURL string) *cobra.Command {
+	t.Helper()
+	root := newRootCmd("config.yaml")
+	root.PersistentPreRunE = nil // Disable to prevent overriding mock
+	previous := localeEnv
+	localeEnv = func(string) string { return "" } // Unicode tables, whatever the locale running the tests
+	t.Cleanup(func() { localeEnv = previous })
+	mockCtx := context.WithValue(context.Background(), dependencies, &Dependencies{
+		URLBuilder: &urlBuilder{
+			BaseURL:             serverURL,
+			ListPath:            "/movie/%s?",
+			DiscoverPath:        "/discover/movie?",
+			CollectionPath:      "/collection/%s",
+			DetailsPath:         "/movie/%d",
+			FindPath:            "/find/%s?external_source=%s",
+			AccountPath:         "/account",
+			WatchlistPath:       "/account/%d/watchlist",
+			WatchlistMoviesPath: "/account/%s/watchlist/movies?",
+			RatingPath:          "/movie/%d/rating",
+		},
+		Client:    newHTTPClient("valid_api_key"),
+		ConfigDir: t.TempDir(),
+	})
+	root.SetContext(mockCtx)
+	return root
+}
+
 func requireAPIKey(t testing.TB, w http.ResponseWriter, r *http.Request) {
 	t.Helper()
 	apiKey := r.Header.Get("Authorization")
@@ -423,6 +587,23 @@ func assertMovies(t testing.TB, want, got movies) {
 	}
 }
 
+// movieIDs returns the IDs of the movies, in order.
+func movieIDs(m movies) []int {
+	ids := make([]int, 0, len(m))
+	for _, movie := range m {
+		ids = append(ids, movie.ID)
+	}
+	return ids
+}
+
+func assertMovieIDs(t testing.TB, want []int, got movies) {
+	t.Helper()
+	gotIDs := movieIDs(got)
+	if !reflect.DeepEqual(want, gotIDs) {
+		t.Errorf("expected movie IDs %v, but got %v", want, gotIDs)
+	}
+}
+
 func assertPrintNoResults(t testing.TB, got string) {
 	want := "No results available. Please try another query.\n"
 	if want != got {
@@ -438,3 +619,12 @@ func assertContains(t testing.TB, s string, sl []string) {
 		}
 	}
 }
+
+func assertNotContains(t testing.TB, s string, sl []string) {
+	t.Helper()
+	for _, e := range sl {
+		if strings.Contains(s, e) {
+			t.Errorf("expected output not to contain %q", e)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import "strings"
+
+// languages maps the ISO 639-1 codes accepted by --language to their English name,
+// plus the "cn" and "xx" codes TMDB uses for Cantonese and movies without dialogue.
+var languages = map[string]string{
+	"aa": "Afar",
+	"ab": "Abkhazian",
+	"ae": "Avestan",
+	"af": "Afrikaans",
+	"ak": "Akan",
+	"am": "Amharic",
+	"an": "Aragonese",
+	"ar": "Arabic",
+	"as": "Assamese",
+	"av": "Avaric",
+	"ay": "Aymara",
+	"az": "Azerbaijani",
+	"ba": "Bashkir",
+	"be": "Belarusian",
+	"bg": "Bulgarian",
+	"bi": "Bislama",
+	"bm": "Bambara",
+	"bn": "Bengali",
+	"bo": "Tibetan",
+	"br": "Breton",
+	"bs": "Bosnian",
+	"ca": "Catalan",
+	"ce": "Chechen",
+	"ch": "Chamorro",
+	"cn": "Cantonese (TMDB)",
+	"co": "Corsican",
+	"cr": "Cree",
+	"cs": "Czech",
+	"cu": "Church Slavic",
+	"cv": "Chuvash",
+	"cy": "Welsh",
+	"da": "Danish",
+	"de": "German",
+	"dv": "Divehi",
+	"dz": "Dzongkha",
+	"ee": "Ewe",
+	"el": "Greek",
+	"en": "English",
+	"eo": "Esperanto",
+	"es": "Spanish",
+	"et": "Estonian",
+	"eu": "Basque",
+	"fa": "Persian",
+	"ff": "Fulah",
+	"fi": "Finnish",
+	"fj": "Fijian",
+	"fo": "Faroese",
+	"fr": "French",
+	"fy": "Western Frisian",
+	"ga": "Irish",
+	"gd": "Scottish Gaelic",
+	"gl": "Galician",
+	"gn": "Guarani",
+	"gu": "Gujarati",
+	"gv": "Manx",
+	"ha": "Hausa",
+	"he": "Hebrew",
+	"hi": "Hindi",
+	"ho": "Hiri Motu",
+	"hr": "Croatian",
+	"ht": "Haitian",
+	"hu": "Hungarian",
+	"hy": "Armenian",
+	"hz": "Herero",
+	"ia": "Interlingua",
+	"id": "Indonesian",
+	"ie": "Interlingue",
+	"ig": "Igbo",
+	"ii": "Sichuan Yi",
+	"ik": "Inupiaq",
+	"io": "Ido",
+	"is": "Icelandic",
+	"it": "Italian",
+	"iu": "Inuktitut",
+	"ja": "Japanese",
+	"jv": "Javanese",
+	"ka": "Georgian",
+	"kg": "Kongo",
+	"ki": "Kikuyu",
+	"kj": "Kuanyama",
+	"kk": "Kazakh",
+	"kl": "Kalaallisut",
+	"km": "Khmer",
+	"kn": "Kannada",
+	"ko": "Korean",
+	"kr": "Kanuri",
+	"ks": "Kashmiri",
+	"ku": "Kurdish",
+	"kv": "Komi",
+	"kw": "Cornish",
+	"ky": "Kyrgyz",
+	"la": "Latin",
+	"lb": "Luxembourgish",
+	"lg": "Ganda",
+	"li": "Limburgish",
+	"ln": "Lingala",
+	"lo": "Lao",
+	"lt": "Lithuanian",
+	"lu": "Luba-Katanga",
+	"lv": "Latvian",
+	"mg": "Malagasy",
+	"mh": "Marshallese",
+	"mi": "Maori",
+	"mk": "Macedonian",
+	"ml": "Malayalam",
+	"mn": "Mongolian",
+	"mr": "Marathi",
+	"ms": "Malay",
+	"mt": "Maltese",
+	"my": "Burmese",
+	"na": "Nauru",
+	"nb": "Norwegian Bokmål",
+	"nd": "North Ndebele",
+	"ne": "Nepali",
+	"ng": "Ndonga",
+	"nl": "Dutch",
+	"nn": "Norwegian Nynorsk",
+	"no": "Norwegian",
+	"nr": "South Ndebele",
+	"nv": "Navajo",
+	"ny": "Chichewa",
+	"oc": "Occitan",
+	"oj": "Ojibwa",
+	"om": "Oromo",
+	"or": "Oriya",
+	"os": "Ossetian",
+	"pa": "Punjabi",
+	"pi": "Pali",
+	"pl": "Polish",
+	"ps": "Pashto",
+	"pt": "Portuguese",
+	"qu": "Quechua",
+	"rm": "Romansh",
+	"rn": "Rundi",
+	"ro": "Romanian",
+	"ru": "Russian",
+	"rw": "Kinyarwanda",
+	"sa": "Sanskrit",
+	"sc": "Sardinian",
+	"sd": "Sindhi",
+	"se": "Northern Sami",
+	"sg": "Sango",
+	"si": "Sinhala",
+	"sk": "Slovak",
+	"sl": "Slovenian",
+	"sm": "Samoan",
+	"sn": "Shona",
+	"so": "Somali",
+	"sq": "Albanian",
+	"sr": "Serbian",
+	"ss": "Swati",
+	"st": "Southern Sotho",
+	"su": "Sundanese",
+	"sv": "Swedish",
+	"sw": "Swahili",
+	"ta": "Tamil",
+	"te": "Telugu",
+	"tg": "Tajik",
+	"th": "Thai",
+	"ti": "Tigrinya",
+	"tk": "Turkmen",
+	"tl": "Tagalog",
+	"tn": "Tswana",
+	"to": "Tonga",
+	"tr": "Turkish",
+	"ts": "Tsonga",
+	"tt": "Tatar",
+	"tw": "Twi",
+	"ty": "Tahitian",
+	"ug": "Uyghur",
+	"uk": "Ukrainian",
+	"ur": "Urdu",
+	"uz": "Uzbek",
+	"ve": "Venda",
+	"vi": "Vietnamese",
+	"vo": "Volapük",
+	"wa": "Walloon",
+	"wo": "Wolof",
+	"xh": "Xhosa",
+	"xx": "No Language (TMDB)",
+	"yi": "Yiddish",
+	"yo": "Yoruba",
+	"za": "Zhuang",
+	"zh": "Chinese",
+	"zu": "Zulu",
+}
+
+// keyboardRows locates the letters on a QWERTY keyboard to rank typo suggestions.
+var keyboardRows = []string{"qwertyuiop", "asdfghjkl", "zxcvbnm"}
+
+// keyDistance is the QWERTY distance between two letters, or -1 if either isn't one.
+func keyDistance(a, b rune) int {
+	ra, ca, rb, cb := -1, -1, -1, -1
+	for row, keys := range keyboardRows {
+		if col := strings.IndexRune(keys, a); col >= 0 {
+			ra, ca = row, col
+		}
+		if col := strings.IndexRune(keys, b); col >= 0 {
+			rb, cb = row, col
+		}
+	}
+	if ra < 0 || rb < 0 {
+		return -1
+	}
+	return max(abs(ra-rb), abs(ca-cb))
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// suggestLanguage returns the known code closest to a mistyped one: a swapped pair
+// first, then a single letter replaced by a neighboring key, or "" without a close match.
+func suggestLanguage(code string) string {
+	if len(code) != 2 {
+		return ""
+	}
+	if swapped := code[1:] + code[:1]; languages[swapped] != "" {
+		return swapped
+	}
+	best, bestDist := "", 2 // only adjacent keys count as typos
+	for known := range languages {
+		var dist int
+		switch {
+		case known[0] == code[0] && known[1] != code[1]:
+			dist = keyDistance(rune(known[1]), rune(code[1]))
+		case known[1] == code[1] && known[0] != code[0]:
+			dist = keyDistance(rune(known[0]), rune(code[0]))
+		default:
+			continue
+		}
+		if dist < 0 || dist > bestDist || (dist == bestDist && best != "" && known > best) {
+			continue
+		}
+		best, bestDist = known, dist
+	}
+	if bestDist > 1 {
+		return ""
+	}
+	return best
+}
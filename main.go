@@ -1,14 +1,16 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
 )
 
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	rootCmd := newRootCmd("config.yaml")
-	err := rootCmd.Execute()
-	if err != nil {
-		os.Exit(1)
-	}
-	os.Exit(0)
+	rootCmd.SetContext(ctx)
+	err := execute(rootCmd)
+	stop()
+	os.Exit(exitCode(err))
 }
@@ -1,15 +1,28 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math"
+	mathrand "math/rand/v2"
+	"net"
 	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cenkalti/backoff/v5"
@@ -20,17 +33,37 @@ const (
 	minVoteAverage = 0
 	maxVoteAverage = 10
 	minVoteCount   = 0
+	ratingStep     = 0.5
 	yearFormat     = "2006"
+	unknownYear    = "unknown"
+	unknownGroup   = unknownYear
 	helpISO6391    = "https://en.wikipedia.org/wiki/List_of_ISO_639-1_codes"
 	firstPage      = 1
 	resultsPerPage = 20
 	maxAPICalls    = 20
 	APIMaxItems    = resultsPerPage * maxAPICalls
+	// detailsConcurrency bounds the parallel requests of per-movie details fetches.
+	detailsConcurrency = 5
+	// defaultNetworkRetries bounds retries on transient connection failures.
+	defaultNetworkRetries = 2
+	// defaultRetryBudget bounds the retries shared by all the pages of a fetch.
+	defaultRetryBudget = 10
+	// defaultConnectTimeout bounds establishing a connection to TMDB.
+	defaultConnectTimeout = 5 * time.Second
+	// defaultTotalTimeout bounds each request, from connecting to reading the response.
+	defaultTotalTimeout = 10 * time.Second
 )
 
 var (
-	yearNow   = time.Now().Year()
-	genresMap = map[string]int{
+	// nowFunc is the clock of every calendar-dependent feature (year bounds, --only-released,
+	// --since-last-run), overridable in tests. Request timings keep using time.Now.
+	nowFunc = time.Now
+	// yearLocation is the time zone deciding which year is the current one.
+	yearLocation = time.Local
+	// singlePageFastPath lets single-page fetches skip fetchPageWaves, disabled by the
+	// benchmark comparing both paths.
+	singlePageFastPath = true
+	genresMap          = map[string]int{
 		"action":          28,
 		"adventure":       12,
 		"animation":       16,
@@ -53,35 +86,468 @@ var (
 	}
 )
 
+// errInterrupted reports that a fetch was canceled before all pages arrived.
+var errInterrupted = errors.New("partial results (interrupted)")
+
+// errRetryBudget reports a fetch giving up once its shared retries are spent.
+var errRetryBudget = errors.New("retry budget exhausted")
+
+// errUnexpectedShape reports a decoded response missing the fields TMDB always sends.
+var errUnexpectedShape = errors.New("unexpected response shape")
+
 type (
 	// movies represents a collection of TMDB film entries for processing.
 	movies []movie
 	// movie contains essential metadata for a single TMDB film record.
 	movie struct {
-		ID            int     `json:"id"`
-		OriginalTitle string  `json:"original_title"`
-		ReleaseDate   string  `json:"release_date"`
-		Title         string  `json:"title"`
-		VoteAverage   float64 `json:"vote_average"`
-		VoteCount     int     `json:"vote_count"`
+		ID               int     `json:"id" yaml:"id"`
+		OriginalTitle    string  `json:"original_title" yaml:"original_title"`
+		ReleaseDate      string  `json:"release_date" yaml:"release_date"`
+		Title            string  `json:"title" yaml:"title"`
+		OriginalLanguage string  `json:"original_language,omitempty" yaml:"original_language,omitempty"`
+		VoteAverage      float64 `json:"vote_average" yaml:"vote_average"`
+		VoteCount        int     `json:"vote_count" yaml:"vote_count"`
+		GenreIDs         []int   `json:"genre_ids,omitempty" yaml:"genre_ids,omitempty"`
+		PosterPath       string  `json:"poster_path,omitempty" yaml:"poster_path,omitempty"`
+		BackdropPath     string  `json:"backdrop_path,omitempty" yaml:"backdrop_path,omitempty"`
+		Runtime          int     `json:"runtime,omitempty" yaml:"runtime,omitempty"`
+		Overview         string  `json:"overview,omitempty" yaml:"overview,omitempty"`
+		// Source names the queries a movie came from when several are merged.
+		Source string `json:"source,omitempty" yaml:"source,omitempty"`
 	}
 )
 
 // deduplicate removes repeated movie entries while preserving order.
 func (m movies) deduplicate() movies {
-	seen := make(map[int]bool)
+	return m.deduplicateBy(idKey)
+}
+
+// deduplicateBy keeps the first movie of each key while preserving order, merging
+// the sources of the repeated movies into it.
+func (m movies) deduplicateBy(key func(movie) string) movies {
+	result, _ := m.collapseBy(key)
+	return result
+}
+
+// collapseBy deduplicates like deduplicateBy, also returning the IDs of the movies
+// dropped, in order.
+func (m movies) collapseBy(key func(movie) string) (movies, []int) {
+	seen := make(map[string]int)
 	result := make(movies, 0, len(m))
+	var removed []int
 	for _, movie := range m {
-		if !seen[movie.ID] {
-			seen[movie.ID] = true
-			result = append(result, movie)
+		k := key(movie)
+		if i, ok := seen[k]; ok {
+			result[i].Source = joinSources(result[i].Source, movie.Source)
+			removed = append(removed, movie.ID)
+			continue
+		}
+		seen[k] = len(result)
+		result = append(result, movie)
+	}
+	return result, removed
+}
+
+// joinSources adds the sources of b missing from a, comma-separated.
+func joinSources(a, b string) string {
+	sources := strings.Split(a, ", ")
+	for _, source := range strings.Split(b, ", ") {
+		if source != "" && !slices.Contains(sources, source) {
+			sources = append(sources, source)
+		}
+	}
+	return strings.TrimPrefix(strings.Join(sources, ", "), ", ")
+}
+
+// withSource tags the movies lacking a source with the given one.
+func (m movies) withSource(source string) movies {
+	for i := range m {
+		if m[i].Source == "" {
+			m[i].Source = source
+		}
+	}
+	return m
+}
+
+// dedupeKeys maps the --dedupe-by values to the key identifying a movie.
+var dedupeKeys = map[string]func(movie) string{
+	"id":    idKey,
+	"title": titleKey,
+}
+
+func idKey(m movie) string { return "id:" + strconv.Itoa(m.ID) }
+
+// titleKey normalizes the title, falling back to the ID for untitled movies.
+func titleKey(m movie) string {
+	title := strings.ToLower(strings.TrimSpace(m.Title))
+	if title == "" {
+		return idKey(m)
+	}
+	return "title:" + title
+}
+
+// dedupeKey returns the key function of a --dedupe-by value, ID when empty.
+func dedupeKey(by string) (func(movie) string, error) {
+	by = cleanString(by)
+	if by == "" {
+		by = "id"
+	}
+	key, ok := dedupeKeys[by]
+	if !ok {
+		return nil, fmt.Errorf("validation error: dedupe by must be one of: %v", []string{"id", "title"})
+	}
+	return key, nil
+}
+
+// UnmarshalMovies reads movies from either a JSON array or a TMDB-like object
+// wrapping them under "results", such as a raw TMDB response.
+func UnmarshalMovies(byt []byte) (movies, error) {
+	byt = bytes.TrimSpace(byt)
+	if len(byt) > 0 && byt[0] == '[' {
+		var m movies
+		if err := json.Unmarshal(byt, &m); err != nil {
+			return nil, fmt.Errorf("decode movies: %w", err)
+		}
+		return m, nil
+	}
+	var wrapper struct {
+		Results movies `json:"results"`
+	}
+	if err := json.Unmarshal(byt, &wrapper); err != nil {
+		return nil, fmt.Errorf("decode movies: %w", err)
+	}
+	if wrapper.Results == nil {
+		return nil, fmt.Errorf(`decode movies: expected a JSON array or an object with "results"`)
+	}
+	return wrapper.Results, nil
+}
+
+// decodeResultsStream decodes a TMDB page token by token, passing each movie of its
+// "results" array to emit as soon as it's parsed, so a whole page is never held.
+// The returned response holds the page fields, without the results. A success:false
+// envelope is reported as an *apiError.
+func decodeResultsStream(r io.Reader, emit func(movie)) (tmdbResponse, error) {
+	var page tmdbResponse
+	var envelope apiError
+	dec := json.NewDecoder(r)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return page, fmt.Errorf("decode response: %w: expected an object", errUnexpectedShape)
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return page, fmt.Errorf("decode response: %w", err)
+		}
+		var target any
+		switch key, _ := tok.(string); key {
+		case "results":
+			if err := streamArray(dec, emit); err != nil {
+				return page, err
+			}
+			continue
+		case "page":
+			target = &page.Page
+		case "total_pages":
+			target = &page.TotalPages
+		case "total_results":
+			target = &page.TotalResults
+		case "success":
+			target = &envelope.Success
+		case "status_code":
+			target = &envelope.StatusCode
+		case "status_message":
+			target = &envelope.StatusMessage
+		default:
+			target = &json.RawMessage{}
+		}
+		if err := dec.Decode(target); err != nil {
+			return page, fmt.Errorf("decode response: %w", err)
+		}
+	}
+	if envelope.failed() {
+		return page, fmt.Errorf("fetch TMDB response: %w", &envelope)
+	}
+	return page, nil
+}
+
+// streamArray decodes the array at the decoder position, one movie at a time. A null
+// holds no movies, like an empty array or absent results.
+func streamArray(dec *json.Decoder, emit func(movie)) error {
+	tok, err := dec.Token()
+	if err == nil && tok == nil {
+		return nil
+	}
+	if err != nil || tok != json.Delim('[') {
+		return fmt.Errorf("decode response: %w: results must be an array", errUnexpectedShape)
+	}
+	for dec.More() {
+		var m movie
+		if err := dec.Decode(&m); err != nil {
+			return fmt.Errorf("decode response: %w", err)
+		}
+		emit(m)
+	}
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// reverse flips the order of the movies in place.
+func (m movies) reverse() movies {
+	slices.Reverse(m)
+	return m
+}
+
+// sample picks n movies uniformly at random, without replacement, keeping their order.
+func (m movies) sample(n int, rng *mathrand.Rand) (movies, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("validation error: random must be ≥ 1")
+	}
+	if n > len(m) {
+		return nil, fmt.Errorf("validation error: cannot pick %d random movies out of %d results, "+
+			"fetch more with --max-items or relax the filters", n, len(m))
+	}
+	picked := rng.Perm(len(m))[:n]
+	slices.Sort(picked)
+	result := make(movies, 0, n)
+	for _, i := range picked {
+		result = append(result, m[i])
+	}
+	return result, nil
+}
+
+// isReleased reports whether the movie has a release date on or before today.
+func (m movie) isReleased(today string) bool {
+	date, ok := parseReleaseDate(m.ReleaseDate)
+	return ok && date.Format(time.DateOnly) <= today
+}
+
+// releaseDateLayouts are the release date forms returned across TMDB endpoints.
+var releaseDateLayouts = []string{time.DateOnly, time.RFC3339, yearFormat}
+
+// parseReleaseDate reads a release date in any of releaseDateLayouts, normalized
+// to midnight UTC of its calendar day, a bare year standing for January 1st. It
+// reports false for an empty or malformed date.
+func parseReleaseDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range releaseDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// today is the current date in the year location, as TMDB formats release dates.
+func today() string {
+	return nowFunc().In(yearLocation).Format(time.DateOnly)
+}
+
+// hasOnlyGenres reports whether every genre of the movie is one of allowed, so a
+// movie without genres always has.
+func (m movie) hasOnlyGenres(allowed map[int]bool) bool {
+	for _, id := range m.GenreIDs {
+		if !allowed[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// hasPoster reports whether TMDB has a poster image for the movie.
+func (m movie) hasPoster() bool {
+	return strings.TrimSpace(m.PosterPath) != ""
+}
+
+const (
+	// tmdbPageURL is the page of a movie on the TMDB website.
+	tmdbPageURL = "https://www.themoviedb.org/movie/%d"
+	// imageBaseURL serves TMDB images, such as posters, at their original size.
+	imageBaseURL = "https://image.tmdb.org/t/p/original"
+)
+
+// urlKinds lists the URLs of a movie printed by --urls-only.
+var urlKinds = []string{"tmdb-page", "poster", "backdrop"}
+
+// url returns the URL of the given kind of the movie, empty when TMDB has no such image.
+func (m movie) url(kind string) string {
+	switch kind {
+	case "poster":
+		return imageURL(m.PosterPath)
+	case "backdrop":
+		return imageURL(m.BackdropPath)
+	}
+	return fmt.Sprintf(tmdbPageURL, m.ID)
+}
+
+// imageURL composes the URL of a TMDB image from its path, e.g. "/abc.jpg".
+func imageURL(path string) string {
+	if path = strings.TrimSpace(path); path == "" {
+		return ""
+	}
+	return imageBaseURL + path
+}
+
+// yearCount holds the number of movies released in a given year.
+type yearCount struct {
+	Year  string
+	Count int
+}
+
+// genreCount holds the number of TMDB results of a genres filter.
+type genreCount struct {
+	Genres string
+	Total  int
+}
+
+// previewGenres counts the TMDB results of the query with each genre group of its
+// genres filter alone, then with all of them combined, to spot the group
+// over-constraining the query. It makes a single-page request per count.
+func previewGenres(ctx context.Context, hc *httpClient, ub *urlBuilder, q queryParams) ([]genreCount, error) {
+	var filters []string
+	for _, group := range strings.Split(cleanString(q.WithGenres), ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			filters = append(filters, group)
+		}
+	}
+	filters = dedupe(filters)
+	if len(filters) > 1 {
+		filters = append(filters, strings.Join(filters, ","))
+	}
+	counts := make([]genreCount, 0, len(filters))
+	for _, filter := range filters {
+		q.WithGenres = filter
+		url, err := ub.discover(q)
+		if err != nil {
+			return nil, err
+		}
+		res, err := fetchTMDBResponse(ctx, hc, fmt.Sprintf("%s&page=%d", url, firstPage))
+		if err != nil {
+			return nil, err
 		}
+		counts = append(counts, genreCount{Genres: filter, Total: res.TotalResults})
 	}
+	return counts, nil
+}
+
+// countByYear groups movies per release year, ascending, with undated ones last.
+func (m movies) countByYear() []yearCount {
+	counts := make(map[string]int)
+	for _, movie := range m {
+		counts[movie.releaseYear()]++
+	}
+	result := make([]yearCount, 0, len(counts))
+	for year, count := range counts {
+		result = append(result, yearCount{Year: year, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Year == unknownYear {
+			return false
+		}
+		if result[j].Year == unknownYear {
+			return true
+		}
+		return result[i].Year < result[j].Year
+	})
 	return result
 }
 
+// groupFields lists the fields accepted by --group-by.
+var groupFields = []string{"year", "decade", "language", "genre"}
+
+// groupSummary holds the number of movies and their average rating for a group.
+type groupSummary struct {
+	Group   string
+	Count   int
+	Average float64
+}
+
+// groupBy summarizes movies per year, decade, original language or genre, ascending,
+// with movies missing the field in an "unknown" group last. A movie with several
+// genres counts in each of them.
+func (m movies) groupBy(field string) ([]groupSummary, error) {
+	if err := validateGroupField(field); err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int)
+	sums := make(map[string]float64)
+	for _, movie := range m {
+		for _, group := range movie.groups(field) {
+			counts[group]++
+			sums[group] += movie.VoteAverage
+		}
+	}
+	result := make([]groupSummary, 0, len(counts))
+	for group, count := range counts {
+		result = append(result, groupSummary{Group: group, Count: count, Average: sums[group] / float64(count)})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Group == unknownGroup {
+			return false
+		}
+		if result[j].Group == unknownGroup {
+			return true
+		}
+		return result[i].Group < result[j].Group
+	})
+	return result, nil
+}
+
+// validateGroupField checks that a --group-by field is supported.
+func validateGroupField(field string) error {
+	if !slices.Contains(groupFields, field) {
+		return fmt.Errorf("validation error: group by must be one of %v", groupFields)
+	}
+	return nil
+}
+
+// groups returns the groups a movie belongs to for a --group-by field.
+func (m movie) groups(field string) []string {
+	switch field {
+	case "year":
+		return []string{m.releaseYear()}
+	case "decade":
+		year := m.releaseYear()
+		if year == unknownYear {
+			return []string{unknownGroup}
+		}
+		return []string{year[:len(year)-1] + "0s"}
+	case "language":
+		if m.OriginalLanguage == "" {
+			return []string{unknownGroup}
+		}
+		return []string{m.OriginalLanguage}
+	default:
+		if len(m.GenreIDs) == 0 {
+			return []string{unknownGroup}
+		}
+		names := make([]string, 0, len(m.GenreIDs))
+		for _, id := range m.GenreIDs {
+			names = append(names, genreName(id))
+		}
+		slices.Sort(names)
+		return slices.Compact(names)
+	}
+}
+
+// releaseYear extracts the year from the release date, or "unknown" if missing.
+func (m movie) releaseYear() string {
+	date, ok := parseReleaseDate(m.ReleaseDate)
+	if !ok {
+		return unknownYear
+	}
+	return date.Format(yearFormat)
+}
+
 // sortByField organizes movies by specified criteria and direction.
 func (m movies) sortByField(param string) (movies, error) {
+	return m.sortByFieldNulls(param, "")
+}
+
+// sortByFieldNulls sorts like sortByField, placing the movies missing the sort field
+// "first" or "last" whatever the order, or leaving them to the comparator when empty.
+func (m movies) sortByFieldNulls(param, nulls string) (movies, error) {
 	param = cleanString(param)
 	parts := strings.Split(param, ",")
 	if len(parts) != 2 {
@@ -91,22 +557,67 @@ func (m movies) sortByField(param string) (movies, error) {
 	if err != nil {
 		return m, err
 	}
+	if err := validateNulls(nulls); err != nil {
+		return m, err
+	}
+	if nulls != "" {
+		compareFunc = m.withNulls(parts[0], parts[1], nulls, compareFunc)
+	}
 	if err := m.sortHelper(parts[1], compareFunc); err != nil {
 		return m, err
 	}
 	return m, nil
 }
 
+// withNulls wraps a comparator so movies missing the field land first or last. The
+// placement is inverted for descending orders, as sortHelper swaps the comparator.
+func (m movies) withNulls(field, order, nulls string, compare func(i, j int) bool) func(i, j int) bool {
+	missing := m.missingFunc(field)
+	nullsFirst := (nulls == "first") == (order == "asc")
+	return func(i, j int) bool {
+		iMissing, jMissing := missing(i), missing(j)
+		switch {
+		case iMissing && jMissing:
+			return false
+		case iMissing || jMissing:
+			return iMissing == nullsFirst
+		}
+		return compare(i, j)
+	}
+}
+
+func (m movies) missingFunc(field string) func(i int) bool {
+	return map[string]func(i int) bool{
+		"date": func(i int) bool {
+			_, ok := parseReleaseDate(m[i].ReleaseDate)
+			return !ok
+		},
+		"otitle":  func(i int) bool { return m[i].OriginalTitle == "" },
+		"title":   func(i int) bool { return m[i].Title == "" },
+		"average": func(i int) bool { return m[i].VoteAverage == 0 },
+		"votes":   func(i int) bool { return m[i].VoteCount == 0 },
+		"runtime": func(i int) bool { return m[i].Runtime == 0 },
+	}[field]
+}
+
+// Comparators always read the typed struct fields, never their formatted display
+// values, so numeric fields keep numeric semantics (e.g. 20 < 100, 9.5 < 10).
+// compareReleaseDate orders by calendar day, whatever the date form, with missing
+// dates first; the raw values break ties between forms of the same day.
 func (m movies) compareReleaseDate(i, j int) bool {
-	iDate, _ := time.Parse(time.DateOnly, m[i].ReleaseDate)
-	jDate, _ := time.Parse(time.DateOnly, m[j].ReleaseDate)
-	return iDate.Before(jDate)
+	iDate, _ := parseReleaseDate(m[i].ReleaseDate)
+	jDate, _ := parseReleaseDate(m[j].ReleaseDate)
+	if !iDate.Equal(jDate) {
+		return iDate.Before(jDate)
+	}
+	return m[i].ReleaseDate < m[j].ReleaseDate
 }
 
 func (m movies) compareOriginalTitle(i, j int) bool { return m[i].OriginalTitle < m[j].OriginalTitle }
 func (m movies) compareTitle(i, j int) bool         { return m[i].Title < m[j].Title }
 func (m movies) compareVoteAverage(i, j int) bool   { return m[i].VoteAverage < m[j].VoteAverage }
 func (m movies) compareVoteCount(i, j int) bool     { return m[i].VoteCount < m[j].VoteCount }
+func (m movies) compareRuntime(i, j int) bool       { return m[i].Runtime < m[j].Runtime }
 
 func (m movies) getCompareFunc(field string) (func(i, j int) bool, error) {
 	mapCompareFunc := map[string]func(i, j int) bool{
@@ -115,28 +626,42 @@ func (m movies) getCompareFunc(field string) (func(i, j int) bool, error) {
 		"title":   m.compareTitle,
 		"average": m.compareVoteAverage,
 		"votes":   m.compareVoteCount,
+		"runtime": m.compareRuntime,
 	}
 	compareFunc, ok := mapCompareFunc[field]
 	if !ok {
 		return nil, fmt.Errorf("validation error: movie list parameter must be one of: %v",
-			[]string{"date", "otitle", "title", "average", "votes"})
+			[]string{"date", "otitle", "title", "average", "votes", "runtime"})
 	}
 	return compareFunc, nil
 }
 
+// sortHelper breaks the ties of compare by ascending ID in both directions, so the
+// sorted movies don't depend on the order they were fetched in.
 func (m movies) sortHelper(order string, compare func(i, j int) bool) error {
 	if err := validateOrder(order); err != nil {
 		return err
 	}
 	sort.Slice(m, func(i, j int) bool {
-		if order == "asc" {
-			return compare(i, j)
+		before, after := compare(i, j), compare(j, i)
+		if order == "desc" {
+			before, after = after, before
+		}
+		if before != after {
+			return before
 		}
-		return !compare(i, j)
+		return m[i].ID < m[j].ID
 	})
 	return nil
 }
 
+func validateNulls(nulls string) error {
+	if nulls != "" && nulls != "first" && nulls != "last" {
+		return fmt.Errorf("validation error: sort nulls must be one of: %v", []string{"first", "last"})
+	}
+	return nil
+}
+
 func validateOrder(order string) error {
 	if order != "asc" && order != "desc" {
 		return fmt.Errorf("validation error: order parameter must be one of: %v",
@@ -148,10 +673,125 @@ func validateOrder(order string) error {
 type (
 	// httpClient manages authenticated requests and error handling for GitHub API.
 	httpClient struct {
-		url    string
-		APIKey string
-		Method string
-		Client *http.Client
+		APIKey         string
+		Client         *http.Client
+		NetworkRetries int
+		NoRetry        bool
+		Logger         *log.Logger
+		Stats          *requestStats
+		Locale         string
+		MaxPages       int
+		// BatchSize bounds the pages fetched in parallel per wave, all at once when 0,
+		// and BatchDelay pauses between waves.
+		BatchSize  int
+		BatchDelay time.Duration
+		// RetryBudget bounds the retries shared by all the requests of a fetch, unlimited
+		// when negative, and FetchTimeout bounds its duration, unlimited when 0.
+		RetryBudget  int
+		FetchTimeout time.Duration
+		// OnDuplicates, when set, is called as a multi-page fetch returns, with the IDs
+		// of the movies dropped for repeating an earlier one, e.g. when the results
+		// shift between two pages.
+		OnDuplicates func(ids []int)
+		// OnPage, when set, is called as each page of a multi-page fetch completes, with
+		// the page number and the number of pages the fetch may request. Calls never
+		// overlap, even when pages are fetched in parallel.
+		OnPage func(page, total int)
+		// TotalTimeout bounds each attempt of a request, from connecting to reading the
+		// response, unlimited when 0. The transport bounds connecting alone.
+		TotalTimeout time.Duration
+		// NewBackOff builds the delays between the retries of a request, waiting as
+		// asked by TMDB's Retry-After header and exponentially otherwise when nil.
+		NewBackOff func() backoff.BackOff
+	}
+	// retryAfterBackOff waits as long as the last Retry-After header asked, if any, and
+	// as long as its BackOff otherwise.
+	retryAfterBackOff struct {
+		backoff.BackOff
+		RetryAfter *time.Duration
+	}
+	// requestStats accumulates the API usage of an httpClient, safe for concurrent use.
+	requestStats struct {
+		mu       sync.Mutex
+		Requests int
+		Retries  int
+		Bytes    int64
+		Elapsed  time.Duration
+	}
+	// countingReader counts the bytes read through it.
+	countingReader struct {
+		r io.Reader
+		n *int64
+	}
+	// statusError reports a TMDB API response with an unsuccessful HTTP status.
+	statusError struct {
+		StatusCode int
+		Status     string
+	}
+	// expiredTokenError reports a 401 response explained by the expiry of the access
+	// token.
+	expiredTokenError struct {
+		Expiry time.Time
+		Err    error
+	}
+	// ValidationError reports an invalid discover filter along with the flag holding
+	// it, e.g. "year", for callers mapping errors back to their form fields.
+	ValidationError struct {
+		Field   string `json:"field"`
+		Message string `json:"message"`
+	}
+	// apiError reports a TMDB failure envelope sent with a successful HTTP status.
+	apiError struct {
+		Success       *bool  `json:"success"`
+		StatusCode    int    `json:"status_code"`
+		StatusMessage string `json:"status_message"`
+	}
+	// collectionResponse represents a movie collection and all its parts.
+	collectionResponse struct {
+		ID    int    `json:"id"`
+		Name  string `json:"name"`
+		Parts movies `json:"parts"`
+	}
+	// movieDetails holds the fields of a single movie, including those missing from
+	// list responses.
+	movieDetails struct {
+		ID          int     `json:"id"`
+		Title       string  `json:"title"`
+		ReleaseDate string  `json:"release_date"`
+		VoteAverage float64 `json:"vote_average"`
+		VoteCount   int     `json:"vote_count"`
+		Runtime     int     `json:"runtime"`
+		Overview    string  `json:"overview"`
+		Genres      []genre `json:"genres"`
+	}
+	// genre is a genre as embedded in movie details.
+	genre struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	// accountResponse holds the account owning the access token.
+	accountResponse struct {
+		ID       int    `json:"id"`
+		Username string `json:"username"`
+	}
+	// watchlistRequest adds a movie to, or removes it from, an account watchlist.
+	watchlistRequest struct {
+		MediaType string `json:"media_type"`
+		MediaID   int    `json:"media_id"`
+		Watchlist bool   `json:"watchlist"`
+	}
+	// ratingRequest rates a movie.
+	ratingRequest struct {
+		Value float64 `json:"value"`
+	}
+	// statusResponse is TMDB's acknowledgment of a write request.
+	statusResponse struct {
+		StatusCode    int    `json:"status_code"`
+		StatusMessage string `json:"status_message"`
+	}
+	// findResponse holds the movies matching an external ID, such as an IMDb ID.
+	findResponse struct {
+		MovieResults movies `json:"movie_results"`
 	}
 	// tmdbResponse represents paginated results from TMDB's API endpoints.
 	tmdbResponse struct {
@@ -162,152 +802,779 @@ type (
 	}
 )
 
+// validator is implemented by responses able to check their shape once decoded.
+type validator interface {
+	validate() error
+}
+
+func (r tmdbResponse) validate() error {
+	if r.Page < 1 || (r.TotalPages > 0 && r.Page > r.TotalPages) {
+		return fmt.Errorf("%w: page %d out of range 1-%d", errUnexpectedShape, r.Page, r.TotalPages)
+	}
+	return r.Results.validate()
+}
+
+func (c collectionResponse) validate() error {
+	if c.ID < 1 {
+		return fmt.Errorf("%w: missing collection id", errUnexpectedShape)
+	}
+	return c.Parts.validate()
+}
+
+func (a accountResponse) validate() error {
+	if a.ID < 1 {
+		return fmt.Errorf("%w: missing account id", errUnexpectedShape)
+	}
+	return nil
+}
+
+func (f findResponse) validate() error {
+	return f.MovieResults.validate()
+}
+
+func (m movies) validate() error {
+	for i, movie := range m {
+		if movie.ID < 1 {
+			return fmt.Errorf("%w: missing id for movie at index %d", errUnexpectedShape, i)
+		}
+	}
+	return nil
+}
+
+func (e *statusError) Error() string {
+	if e.StatusCode >= 500 {
+		return fmt.Sprintf("TMDB API server error: %q", e.Status)
+	}
+	return fmt.Sprintf("TMDB API client error: %q", e.Status)
+}
+
+func (e *expiredTokenError) Error() string {
+	return fmt.Sprintf("your TMDB access token appears to have expired on %s; please regenerate it.",
+		e.Expiry.Format(time.DateOnly))
+}
+
+func (e *expiredTokenError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("TMDB API error %d: %q", e.StatusCode, e.StatusMessage)
+}
+
+// failed reports whether the decoded body is TMDB's success:false envelope.
+func (e *apiError) failed() bool {
+	return e.Success != nil && !*e.Success
+}
+
+// add records the attempts, received bytes and time of a single do call.
+func (s *requestStats) add(attempts int, bytes int64, elapsed time.Duration) {
+	if s == nil || attempts == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Requests += attempts
+	s.Retries += attempts - 1
+	s.Bytes += bytes
+	s.Elapsed += elapsed
+}
+
+// String summarizes the API usage on a single line.
+func (s *requestStats) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fmt.Sprintf("stats: %d requests, %d retries, %d bytes received, %s spent in requests",
+		s.Requests, s.Retries, s.Bytes, s.Elapsed.Round(time.Millisecond))
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	*cr.n += int64(n)
+	return n, err
+}
+
 // newHTTPClient configures secure defaults for TMDB API communication.
 func newHTTPClient(apiKey string) *httpClient {
 	return &httpClient{
 		APIKey: apiKey,
-		Method: "GET",
 		Client: &http.Client{
-			Timeout: 10 * time.Second,
+			Transport: newTransport(defaultConnectTimeout),
 		},
+		Stats:          &requestStats{},
+		NetworkRetries: defaultNetworkRetries,
+		RetryBudget:    defaultRetryBudget,
+		TotalTimeout:   defaultTotalTimeout,
 	}
 }
 
-// asyncFetchMovies efficiently retrieves multiple pages of movie results.
-func asyncFetchMovies(hc *httpClient, url string, maxItems int) (movies, error) {
+// newTransport builds the default transport with connectTimeout bounding each dial,
+// unlimited when 0.
+func newTransport(connectTimeout time.Duration) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}
+	transport.DialContext = dialer.DialContext
+	return transport
+}
+
+// asyncFetchMovies efficiently retrieves multiple pages of movie results. Movies
+// rejected by keep (nil keeps all) are dropped as each page is decoded, before the
+// duplicates are collapsed, and don't count toward maxItems: further pages are
+// fetched one by one until enough movies match or pages run out. When ctx is
+// canceled mid-fetch, the pages gathered so far are returned with errInterrupted.
+// A positive hc.MaxPages caps the pages fetched, whatever maxItems, and a positive
+// hc.BatchSize fetches the parallel pages in waves. It also returns the total number
+// of results TMDB has for the query.
+func asyncFetchMovies(ctx context.Context, hc *httpClient, url string, maxItems int,
+	keep func(movie) bool,
+) (movies, int, error) {
 	if maxItems > APIMaxItems {
-		return movies{}, fmt.Errorf("validation error: movies can't be more than %d", APIMaxItems)
+		return movies{}, 0, fmt.Errorf("validation error: movies can't be more than %d", APIMaxItems)
+	}
+	ctx = withRetryBudget(withRequestID(ctx), hc.RetryBudget)
+	if hc.FetchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hc.FetchTimeout)
+		defer cancel()
 	}
-	var (
-		allResults movies
-		mu         sync.Mutex
-		wg         sync.WaitGroup
-	)
 	firstPageURL := fmt.Sprintf("%s&page=%d", url, firstPage)
-	firstRes, err := fetchTMDBResponse(hc, firstPageURL)
+	firstRes, err := fetchKeptMovies(context.WithValue(ctx, pageKey, firstPage), hc, firstPageURL, keep)
 	if err != nil {
-		return movies{}, err
+		return movies{}, 0, err
 	}
-	if maxItems < len(firstRes.Results) {
-		firstRes.Results = firstRes.Results[:maxItems]
-		return firstRes.Results, nil
+	total := firstRes.TotalResults
+	pageCap := maxAPICalls
+	if hc.MaxPages > 0 {
+		pageCap = min(hc.MaxPages, maxAPICalls)
 	}
-	totalPages := (maxItems + resultsPerPage - firstPage) / resultsPerPage
-	errChan := make(chan error, totalPages-firstPage)
-	for page := 2; page <= totalPages; page++ {
-		wg.Add(1)
-		go func(p int) {
-			defer wg.Done()
-			fetchUrl := fmt.Sprintf("%s&page=%d", url, p)
-			pageRes, err := fetchTMDBResponse(hc, fetchUrl)
-			if err != nil {
-				errChan <- err
-				return
+	if firstRes.TotalPages > 0 {
+		pageCap = min(pageCap, firstRes.TotalPages) // Pages past the end come back empty
+	}
+	totalPages := min((maxItems+resultsPerPage-firstPage)/resultsPerPage, pageCap)
+	lastPage := min(firstRes.TotalPages, pageCap)
+	onPage := func(page int) {
+		if hc.OnPage != nil {
+			hc.OnPage(page, max(totalPages, lastPage, firstPage))
+		}
+	}
+	onPage(firstPage)
+	var removed []int
+	deduplicate := func(m movies) movies {
+		result, ids := m.collapseBy(idKey)
+		removed = append(removed, ids...)
+		return result
+	}
+	defer func() {
+		if hc.OnDuplicates != nil {
+			hc.OnDuplicates(removed)
+		}
+	}()
+	allResults := deduplicate(firstRes.Results)
+	if maxItems <= len(allResults) {
+		return allResults[:maxItems], total, nil
+	}
+	// Single-page fetches skip the parallel machinery and go straight to the
+	// sequential top-up below.
+	if totalPages > firstPage || !singlePageFastPath {
+		pages, err := fetchPageWaves(ctx, hc, url, firstPage+1, totalPages, keep, onPage)
+		removed = nil // Counted again along with the first page
+		allResults = deduplicate(append(firstRes.Results, pages...))
+		if err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return trimMovies(allResults, maxItems), total, errInterrupted
 			}
-			mu.Lock()
-			allResults = append(allResults, pageRes.Results...)
-			mu.Unlock()
-		}(page)
+			return movies{}, 0, err
+		}
+	}
+	for page := max(totalPages, firstPage) + 1; len(allResults) < maxItems && page <= lastPage; page++ {
+		fetchUrl := fmt.Sprintf("%s&page=%d", url, page)
+		pageRes, err := fetchKeptMovies(context.WithValue(ctx, pageKey, page), hc, fetchUrl, keep)
+		if err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return trimMovies(allResults, maxItems), total, errInterrupted
+			}
+			return movies{}, 0, err
+		}
+		onPage(page)
+		allResults = deduplicate(append(allResults, pageRes.Results...))
+	}
+	return trimMovies(allResults, maxItems), total, nil
+}
+
+// fetchPageWaves fetches the pages from first to last in parallel, in waves of
+// hc.BatchSize pages separated by hc.BatchDelay, or all at once without a batch size,
+// calling onPage under a lock as each page completes. It returns the movies kept by
+// keep (nil keeps all) of the pages fetched in page order, whatever order they
+// complete in, along with the first error met.
+func fetchPageWaves(ctx context.Context, hc *httpClient, url string, first, last int,
+	keep func(movie) bool, onPage func(page int),
+) (movies, error) {
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	pageResults := make([]movies, last-first+1) // Indexed by page, whatever the arrival order
+	errChan := make(chan error, last-first+1)
+	batchSize := hc.BatchSize
+	if batchSize <= 0 {
+		batchSize = last - first + 1
+	}
+	for batchStart := first; batchStart <= last; batchStart += batchSize {
+		if batchStart > first && !sleepCtx(ctx, hc.BatchDelay) {
+			errChan <- ctx.Err()
+			break
+		}
+		for page := batchStart; page <= min(batchStart+batchSize-1, last); page++ {
+			wg.Add(1)
+			go func(p int) {
+				defer wg.Done()
+				fetchUrl := fmt.Sprintf("%s&page=%d", url, p)
+				pageRes, err := fetchKeptMovies(context.WithValue(ctx, pageKey, p), hc, fetchUrl, keep)
+				if err != nil {
+					errChan <- err
+					return
+				}
+				mu.Lock()
+				pageResults[p-first] = pageRes.Results
+				onPage(p)
+				mu.Unlock()
+			}(page)
+		}
+		wg.Wait()
 	}
-	wg.Wait()
 	close(errChan)
+	results := slices.Concat(pageResults...)
 	for err := range errChan {
 		if err != nil {
-			return movies{}, err
+			return results, err
 		}
 	}
-	allResults = append(firstRes.Results, allResults...)
-	if len(allResults) > maxItems {
-		allResults = allResults[:maxItems]
+	return results, nil
+}
+
+// sleepCtx pauses for d, returning false when ctx is canceled first.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// trimMovies keeps at most maxItems movies.
+func trimMovies(m movies, maxItems int) movies {
+	if len(m) > maxItems {
+		return m[:maxItems]
+	}
+	return m
+}
+
+const (
+	requestIDKey   contextKey = "request_id"
+	pageKey        contextKey = "page"
+	retryBudgetKey contextKey = "retry_budget"
+)
+
+// retryBudget counts down the retries left to the requests sharing it.
+type retryBudget struct {
+	remaining atomic.Int64
+}
+
+// withRetryBudget shares a budget of n retries between the requests made with the
+// context, unless it already has one. A negative n leaves retries unlimited.
+func withRetryBudget(ctx context.Context, n int) context.Context {
+	if n < 0 {
+		return ctx
+	}
+	if _, ok := ctx.Value(retryBudgetKey).(*retryBudget); ok {
+		return ctx
 	}
-	return allResults.deduplicate(), nil
+	budget := &retryBudget{}
+	budget.remaining.Store(int64(n))
+	return context.WithValue(ctx, retryBudgetKey, budget)
 }
 
-func (hc *httpClient) setURL(url string) {
-	hc.url = url
+// takeRetry spends one retry of the context budget, reporting false once it's empty.
+func takeRetry(ctx context.Context) bool {
+	budget, ok := ctx.Value(retryBudgetKey).(*retryBudget)
+	if !ok {
+		return true
+	}
+	return budget.remaining.Add(-1) >= 0
+}
+
+// withRequestID tags the context with an operation ID, unless it already has one.
+func withRequestID(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(requestIDKey).(string); ok {
+		return ctx
+	}
+	byt := make([]byte, 4)
+	_, _ = rand.Read(byt)
+	return context.WithValue(ctx, requestIDKey, hex.EncodeToString(byt))
+}
+
+// logPrefix formats the request ID and page carried by the context for log lines.
+func logPrefix(ctx context.Context) string {
+	prefix := "[req=" + fmt.Sprint(ctx.Value(requestIDKey))
+	if page, ok := ctx.Value(pageKey).(int); ok {
+		prefix += fmt.Sprintf(" page=%d", page)
+	}
+	return prefix + "] "
+}
+
+// logf writes a verbose log line tagged with the request context, if logging is enabled.
+func (hc *httpClient) logf(ctx context.Context, format string, args ...any) {
+	if hc.Logger == nil {
+		return
+	}
+	hc.Logger.Print(logPrefix(ctx) + fmt.Sprintf(format, args...))
 }
 
 // fetchTMDBResponse gets a single page of results from TMDB API.
-func fetchTMDBResponse(hc *httpClient, url string) (tmdbResponse, error) {
-	hc.setURL(url)
-	ctx, cancel := context.WithCancel(context.Background())
+func fetchTMDBResponse(ctx context.Context, hc *httpClient, url string) (tmdbResponse, error) {
+	return fetchKeptMovies(ctx, hc, url, nil)
+}
+
+// fetchKeptMovies gets a single page of results from TMDB API, passing its movies
+// through keep (nil keeps all) as they're decoded, so the rejected ones are never held.
+func fetchKeptMovies(ctx context.Context, hc *httpClient, url string, keep func(movie) bool) (tmdbResponse, error) {
+	ctx, cancel := context.WithCancel(withRequestID(ctx))
 	defer cancel()
-	tmdbRes, err := hc.do(ctx)
+	start := time.Now()
+	hc.logf(ctx, "fetch %s", url)
+	var kept movies
+	tmdbRes, err := hc.stream(ctx, url, func(m movie) {
+		if keep == nil || keep(m) {
+			kept = append(kept, m)
+		}
+	})
+	tmdbRes.Results = kept
+	if err == nil {
+		if err = tmdbRes.validate(); err != nil {
+			err = fmt.Errorf("decode response: %w", err)
+		}
+	}
 	if err != nil {
+		hc.logf(ctx, "failed after %s: %v", time.Since(start), err)
 		return tmdbResponse{}, err
 	}
+	hc.logf(ctx, "fetched %d results in %s", len(tmdbRes.Results), time.Since(start))
 	return tmdbRes, nil
 }
 
-// do retrieves movie data from TMDB with a retry mechanism based on exponential backoff.
-func (hc *httpClient) do(ctx context.Context) (tmdbResponse, error) {
+// fetchCollection gets all the parts of a movie collection from TMDB API.
+func fetchCollection(ctx context.Context, hc *httpClient, url string) (collectionResponse, error) {
+	ctx = withRequestID(ctx)
+	hc.logf(ctx, "fetch %s", url)
+	var collection collectionResponse
+	if err := hc.do(ctx, http.MethodGet, url, nil, &collection); err != nil {
+		return collectionResponse{}, err
+	}
+	return collection, nil
+}
+
+// fetchFind gets the movies matching an external ID from TMDB API.
+func fetchFind(ctx context.Context, hc *httpClient, url string) (movies, error) {
+	ctx = withRequestID(ctx)
+	hc.logf(ctx, "fetch %s", url)
+	var found findResponse
+	if err := hc.do(ctx, http.MethodGet, url, nil, &found); err != nil {
+		return movies{}, err
+	}
+	return found.MovieResults, nil
+}
+
+// fetchDetails gets the details of a single movie from TMDB API.
+func fetchDetails(ctx context.Context, hc *httpClient, url string) (movieDetails, error) {
+	ctx = withRequestID(ctx)
+	hc.logf(ctx, "fetch %s", url)
+	var details movieDetails
+	if err := hc.do(ctx, http.MethodGet, url, nil, &details); err != nil {
+		return movieDetails{}, err
+	}
+	return details, nil
+}
+
+// fetchAccountID resolves the ID of the account owning the access token.
+func fetchAccountID(ctx context.Context, hc *httpClient, url string) (int, error) {
+	ctx = withRequestID(ctx)
+	hc.logf(ctx, "fetch %s", url)
+	var account accountResponse
+	if err := hc.do(ctx, http.MethodGet, url, nil, &account); err != nil {
+		return 0, err
+	}
+	return account.ID, nil
+}
+
+// tokenClaims are the claims read from a version 4 access token, a JWT.
+type tokenClaims struct {
+	Sub string `json:"sub"`
+	Exp int64  `json:"exp"`
+}
+
+// decodeTokenClaims reads the claims of a JWT without verifying its signature,
+// which only TMDB can do, reporting false for other tokens.
+func decodeTokenClaims(token string) (tokenClaims, bool) {
+	var claims tokenClaims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return claims, false
+	}
+	return claims, json.Unmarshal(payload, &claims) == nil
+}
+
+// accountObjectID reads the account object ID of the version 4 API from the "sub"
+// claim of a version 4 access token, sparing a request.
+func accountObjectID(token string) (string, error) {
+	claims, ok := decodeTokenClaims(token)
+	if !ok || claims.Sub == "" {
+		return "", fmt.Errorf("validation error: API version 4 needs a v4 access token as api_key")
+	}
+	return claims.Sub, nil
+}
+
+// explainUnauthorized turns a 401 response into an *expiredTokenError when the
+// access token is a JWT whose "exp" claim is past, returning err otherwise.
+func explainUnauthorized(token string, err error) error {
+	claims, ok := decodeTokenClaims(token)
+	if !ok || claims.Exp == 0 {
+		return err
+	}
+	expiry := time.Unix(claims.Exp, 0).UTC() // JWT expiries are UTC, whatever the local zone
+	if expiry.After(nowFunc()) {
+		return err
+	}
+	return &expiredTokenError{Expiry: expiry, Err: err}
+}
+
+// updateWatchlist adds the movie to the account watchlist, or removes it when
+// watchlist is false.
+func updateWatchlist(ctx context.Context, hc *httpClient, url string, movieID int, watchlist bool) error {
+	ctx = withRequestID(ctx)
+	hc.logf(ctx, "post %s", url)
+	var status statusResponse
+	payload := watchlistRequest{MediaType: "movie", MediaID: movieID, Watchlist: watchlist}
+	return hc.do(ctx, http.MethodPost, url, payload, &status)
+}
+
+// rateMovie submits the rating of a movie, on behalf of the account owning the
+// access token.
+func rateMovie(ctx context.Context, hc *httpClient, url string, value float64) error {
+	ctx = withRequestID(ctx)
+	hc.logf(ctx, "post %s", url)
+	var status statusResponse
+	return hc.do(ctx, http.MethodPost, url, ratingRequest{Value: value}, &status)
+}
+
+// genreIDs lists the IDs of the genres of the movie.
+func (d movieDetails) genreIDs() []int {
+	ids := make([]int, 0, len(d.Genres))
+	for _, g := range d.Genres {
+		ids = append(ids, g.ID)
+	}
+	return ids
+}
+
+// fetchRuntimes fills in the runtime of each movie from its details, as list and
+// discover responses don't include it, with one request per movie.
+func fetchRuntimes(ctx context.Context, hc *httpClient, ub *urlBuilder, m movies) error {
+	return fetchEachDetails(ctx, hc, ub, m, func(m *movie, details movieDetails) {
+		m.Runtime = details.Runtime
+	})
+}
+
+// enrichMovies fills in the runtime, overview and genres of each movie from its
+// details, with one request per movie.
+func enrichMovies(ctx context.Context, hc *httpClient, ub *urlBuilder, m movies) error {
+	return fetchEachDetails(ctx, hc, ub, m, (*movie).enrich)
+}
+
+// enrich fills in the fields of the movie found in its details, keeping the genres
+// of the movie when the details have none.
+func (m *movie) enrich(details movieDetails) {
+	m.Runtime = details.Runtime
+	m.Overview = details.Overview
+	if len(details.Genres) > 0 {
+		m.GenreIDs = details.genreIDs()
+	}
+}
+
+// fetchEachDetails fetches the details of each movie, at most detailsConcurrency at
+// a time, and hands them to fill along with the movie.
+func fetchEachDetails(ctx context.Context, hc *httpClient, ub *urlBuilder, m movies,
+	fill func(*movie, movieDetails),
+) error {
+	ctx = withRequestID(ctx)
+	var wg sync.WaitGroup
+	errs := make([]error, len(m))
+	sem := make(chan struct{}, detailsConcurrency)
+	for i := range m {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			var details movieDetails
+			if errs[i] = hc.do(ctx, http.MethodGet, ub.details(m[i].ID), nil, &details); errs[i] == nil {
+				fill(&m[i], details)
+			}
+		}(i)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// newBackOff builds the backoff of a request, see NewBackOff.
+func (hc *httpClient) newBackOff() backoff.BackOff {
+	if hc.NewBackOff != nil {
+		return hc.NewBackOff()
+	}
+	return &retryAfterBackOff{BackOff: backoff.NewExponentialBackOff()}
+}
+
+// NextBackOff consumes the pending Retry-After delay, if any.
+func (b *retryAfterBackOff) NextBackOff() time.Duration {
+	if delay := b.RetryAfter; delay != nil {
+		b.RetryAfter = nil
+		return *delay
+	}
+	return b.BackOff.NextBackOff()
+}
+
+// do sends a request to TMDB, with payload as its JSON body unless nil, e.g. for a
+// POST or a DELETE, and decodes the response into target, see fetch for the retries.
+func (hc *httpClient) do(ctx context.Context, method, url string, payload, target any) error {
+	var body []byte
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+	}
+	return hc.fetch(ctx, method, url, body, decodeInto(target))
+}
+
+// decodeInto decodes a response body into target, reporting TMDB's failure envelope
+// and validating the target when it's a validator.
+func decodeInto(target any) func(body io.Reader) error {
+	return func(body io.Reader) error {
+		byt, err := io.ReadAll(body)
+		if err != nil {
+			return fmt.Errorf("read response: %w", err)
+		}
+		var envelope apiError
+		if json.Unmarshal(byt, &envelope) == nil && envelope.failed() {
+			return fmt.Errorf("fetch TMDB response: %w", &envelope)
+		}
+		if err = json.Unmarshal(byt, target); err != nil {
+			return fmt.Errorf("decode response: %w", err)
+		}
+		if v, ok := target.(validator); ok {
+			if err := v.validate(); err != nil {
+				return fmt.Errorf("decode response: %w", err)
+			}
+		}
+		return nil
+	}
+}
+
+// stream retrieves a page of results from TMDB, passing each movie to emit as soon
+// as it's decoded, see decodeResultsStream.
+func (hc *httpClient) stream(ctx context.Context, url string, emit func(movie)) (tmdbResponse, error) {
+	var page tmdbResponse
+	err := hc.fetch(ctx, http.MethodGet, url, nil, func(body io.Reader) error {
+		var err error
+		page, err = decodeResultsStream(body, emit)
+		return err
+	})
+	return page, err
+}
+
+// fetch sends a request to TMDB, with body as its JSON body unless nil, and hands the
+// response body to decode, with a retry mechanism based on exponential backoff.
+// Network failures are retried up to NetworkRetries times, apart from status-based
+// retries, and NoRetry makes a single attempt whatever the failure.
+func (hc *httpClient) fetch(ctx context.Context, method, url string, body []byte,
+	decode func(body io.Reader) error,
+) error {
+	networkFailures, attempts := 0, 0
+	var received int64
+	start := time.Now()
+	defer func() { hc.Stats.add(attempts, received, time.Since(start)) }()
+	var lastErr error
+	b := hc.newBackOff()
+	cancelAttempt := context.CancelFunc(func() {})
+	defer func() { cancelAttempt() }()
 	op := func() (*http.Response, error) {
-		req, err := http.NewRequestWithContext(ctx, hc.Method, hc.url, nil)
+		if attempts > 0 && !takeRetry(ctx) {
+			return nil, backoff.Permanent(fmt.Errorf("%w: %w", errRetryBudget, lastErr))
+		}
+		cancelAttempt()
+		attemptCtx := ctx
+		if hc.TotalTimeout > 0 {
+			attemptCtx, cancelAttempt = context.WithTimeout(ctx, hc.TotalTimeout)
+		}
+		var reqBody io.Reader
+		if body != nil {
+			reqBody = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(attemptCtx, method, url, reqBody)
 		if err != nil {
 			return nil, backoff.Permanent(fmt.Errorf("request error: %w", err))
 		}
 		req.Header.Add("Authorization", "Bearer "+hc.APIKey)
 		req.Header.Add("Content-Type", "application/json")
-		cli := newHTTPClient(hc.APIKey)
-		res, err := cli.Client.Do(req)
+		if hc.Locale != "" {
+			req.Header.Add("Accept-Language", hc.Locale)
+		}
+		attempts++
+		res, err := hc.Client.Do(req)
 		if err != nil {
-			return nil, backoff.Permanent(fmt.Errorf("request error: %w", err))
+			hc.logf(ctx, "%s %s: %v", method, url, err)
+			networkFailures++
+			if networkFailures > hc.NetworkRetries || ctx.Err() != nil {
+				return nil, backoff.Permanent(fmt.Errorf("request error: %w", err))
+			}
+			lastErr = fmt.Errorf("request error: %w", err)
+			return nil, lastErr
 		}
+		hc.logf(ctx, "%s %s: %s", method, url, res.Status)
 		switch {
 		case res.StatusCode >= 500:
-			return nil, backoff.Permanent(fmt.Errorf("TMDB API server error: %q", res.Status))
+			return nil, backoff.Permanent(&statusError{StatusCode: res.StatusCode, Status: res.Status})
 		case res.StatusCode == 429:
+			lastErr = &statusError{StatusCode: res.StatusCode, Status: res.Status}
 			sec, err := strconv.ParseInt(res.Header.Get("Retry-After"), 10, 64)
 			if err == nil {
-				return nil, backoff.RetryAfter(int(sec))
+				res.Body.Close()
+				if ra, ok := b.(*retryAfterBackOff); ok {
+					delay := time.Duration(sec) * time.Second
+					ra.RetryAfter = &delay
+				}
+				return nil, lastErr
 			}
+		case res.StatusCode == http.StatusUnauthorized:
+			err := &statusError{StatusCode: res.StatusCode, Status: res.Status}
+			return nil, backoff.Permanent(explainUnauthorized(hc.APIKey, err))
 		case res.StatusCode >= 400:
-			return nil, backoff.Permanent(fmt.Errorf("TMDB API client error: %q", res.Status))
+			return nil, backoff.Permanent(&statusError{StatusCode: res.StatusCode, Status: res.Status})
 		}
 		return res, nil
 	}
-	res, err := backoff.Retry(ctx, op, backoff.WithBackOff(backoff.NewExponentialBackOff()))
+	opts := []backoff.RetryOption{backoff.WithBackOff(b)}
+	if hc.NoRetry {
+		opts = append(opts, backoff.WithMaxTries(1))
+	}
+	res, err := backoff.Retry(ctx, op, opts...)
 	if err != nil {
-		return tmdbResponse{}, fmt.Errorf("fetch TMDB response: %w", err)
+		return fmt.Errorf("fetch TMDB response: %w", err)
 	}
 	defer func() {
 		if err := res.Body.Close(); err != nil {
-			log.Printf("error closing response body: %v", err)
+			log.Printf("%serror closing response body: %v", logPrefix(ctx), err)
 		}
 	}()
-	var results tmdbResponse
-	if err = json.NewDecoder(res.Body).Decode(&results); err != nil {
-		return tmdbResponse{}, fmt.Errorf("decode response: %w", err)
-	}
-	return results, nil
+	return decode(&countingReader{r: res.Body, n: &received})
 }
 
 type (
 	// urlBuilder constructs valid TMDB API URLs with proper parameter encoding.
 	urlBuilder struct {
-		BaseURL      string
-		ListPath     string
-		DiscoverPath string
+		BaseURL        string
+		Version        int
+		ListPath       string
+		DiscoverPath   string
+		CollectionPath string
+		DetailsPath    string
+		FindPath       string
+		AccountPath    string
+		WatchlistPath  string
+		RatingPath     string
+		// WatchlistMoviesPath lists the movies of a watchlist, page by page.
+		WatchlistMoviesPath string
 	}
 	// queryParams encapsulates filter criteria for discover movie searches.
 	queryParams struct {
-		MaxItems      int
-		Language      string
-		Year          string
-		VoteAverage   string
-		VoteCount     string
-		WithGenres    string
-		WithoutGenres string
+		MaxItems       int    `json:"max_items,omitempty"`
+		Language       string `json:"language,omitempty"`
+		Year           string `json:"year,omitempty"`
+		VoteAverage    string `json:"vote_average,omitempty"`
+		VoteCount      string `json:"vote_count,omitempty"`
+		Runtime        string `json:"runtime,omitempty"`
+		WithGenres     string `json:"with_genres,omitempty"`
+		WithoutGenres  string `json:"without_genres,omitempty"`
+		GenresMatch    string `json:"genres_match,omitempty"`
+		SortBy         string `json:"sort_by,omitempty"`
+		ReleasedSince  string `json:"released_since,omitempty"`
+		ReleasedBefore string `json:"released_before,omitempty"`
 	}
 )
 
+// redactURL masks any API key passed in the query string, for URLs shown to users.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := u.Query()
+	if !query.Has("api_key") {
+		return rawURL
+	}
+	query.Set("api_key", "REDACTED")
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+const (
+	// apiBaseURL serves the TMDB API, each version under its own path, e.g. "/3".
+	apiBaseURL        = "https://api.themoviedb.org"
+	defaultAPIVersion = 3
+)
+
 // newURLBuilder initializes URL patterns for TMDB API endpoints.
 func newURLBuilder() *urlBuilder {
 	return &urlBuilder{
-		BaseURL:      "https://api.themoviedb.org/3",
-		ListPath:     "/movie/%s?",
-		DiscoverPath: "/discover/movie?",
+		BaseURL:             fmt.Sprintf("%s/%d", apiBaseURL, defaultAPIVersion),
+		Version:             defaultAPIVersion,
+		ListPath:            "/movie/%s?",
+		DiscoverPath:        "/discover/movie?",
+		CollectionPath:      "/collection/%s",
+		DetailsPath:         "/movie/%d",
+		FindPath:            "/find/%s?external_source=%s",
+		AccountPath:         "/account",
+		WatchlistPath:       "/account/%d/watchlist",
+		WatchlistMoviesPath: "/account/%s/watchlist/movies?",
+		RatingPath:          "/movie/%d/rating",
+	}
+}
+
+// setVersion switches the URLs to version 3 or 4 of the TMDB API. Version 4 only
+// reworks the endpoints it has, such as the account watchlist, under the same
+// response shape; the commands needing the others stay on version 3.
+func (u *urlBuilder) setVersion(version int) error {
+	switch version {
+	case 3:
+	case 4:
+		u.WatchlistMoviesPath = "/account/%s/movie/watchlist?"
+	default:
+		return fmt.Errorf("validation error: API version must be one of: %v", []int{3, 4})
 	}
+	u.BaseURL = fmt.Sprintf("%s/%d", apiBaseURL, version)
+	u.Version = version
+	return nil
 }
 
 // list generates URLs for TMDB's predefined movie list endpoints.
@@ -319,6 +1586,67 @@ func (u *urlBuilder) list(param string) (string, error) {
 	return fmt.Sprintf(u.BaseURL+u.ListPath, param), nil
 }
 
+// collection generates URLs for TMDB's movie collection endpoint.
+func (u *urlBuilder) collection(id string) (string, error) {
+	id = cleanString(id)
+	if n, err := strconv.Atoi(id); err != nil || n <= 0 {
+		return "", fmt.Errorf(`validation error: collection ID must be a positive integer, e.g. "119"`)
+	}
+	return fmt.Sprintf(u.BaseURL+u.CollectionPath, id), nil
+}
+
+// imdbIDPattern matches IMDb title IDs, e.g. "tt0133093".
+var imdbIDPattern = regexp.MustCompile(`^tt[0-9]+$`)
+
+// find generates URLs for TMDB's find endpoint, looking a movie up by an external ID.
+func (u *urlBuilder) find(externalID, source string) (string, error) {
+	externalID = cleanString(externalID)
+	switch source {
+	case "imdb_id":
+		if !imdbIDPattern.MatchString(externalID) {
+			return "", fmt.Errorf(`validation error: IMDb ID must be "tt" followed by digits, e.g. "tt0133093"`)
+		}
+	default:
+		return "", fmt.Errorf("validation error: unsupported external source %q", source)
+	}
+	return fmt.Sprintf(u.BaseURL+u.FindPath, externalID, source), nil
+}
+
+// parseMovieID validates a TMDB movie ID given on the command line.
+func parseMovieID(id string) (int, error) {
+	n, err := strconv.Atoi(cleanString(id))
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf(`validation error: movie ID must be a positive integer, e.g. "603", got %q`, id)
+	}
+	return n, nil
+}
+
+// details generates URLs for TMDB's movie details endpoint.
+func (u *urlBuilder) details(id int) string {
+	return fmt.Sprintf(u.BaseURL+u.DetailsPath, id)
+}
+
+// account generates URLs for TMDB's endpoint of the account owning the access token.
+func (u *urlBuilder) account() string {
+	return u.BaseURL + u.AccountPath
+}
+
+// watchlist generates URLs for TMDB's endpoint updating an account watchlist.
+func (u *urlBuilder) watchlist(accountID int) string {
+	return fmt.Sprintf(u.BaseURL+u.WatchlistPath, accountID)
+}
+
+// watchlistMovies generates URLs for TMDB's endpoint listing an account watchlist.
+// The account ID is the numeric ID of version 3, or the account object ID of version 4.
+func (u *urlBuilder) watchlistMovies(accountID string) string {
+	return fmt.Sprintf(u.BaseURL+u.WatchlistMoviesPath, accountID)
+}
+
+// rating generates URLs for TMDB's endpoint rating a movie.
+func (u *urlBuilder) rating(id int) string {
+	return fmt.Sprintf(u.BaseURL+u.RatingPath, id)
+}
+
 // discover builds complex query URLs for filtered movie searches.
 func (ub *urlBuilder) discover(q queryParams) (string, error) {
 	var query string
@@ -326,17 +1654,26 @@ func (ub *urlBuilder) discover(q queryParams) (string, error) {
 	url := ub.BaseURL + ub.DiscoverPath
 	for _, handler := range []struct {
 		condition bool
+		field     string
 		handle    func() (string, error)
 	}{
-		{q.Language != "", q.handleLanguage},
-		{q.Year != "", q.handleYear},
-		{q.VoteAverage != "", q.handleVoteAverage},
-		{q.VoteCount != "", q.handleVoteCount},
-		{q.WithGenres != "", q.handleWithGenres},
-		{q.WithoutGenres != "", q.handleWithoutGenres},
+		{q.Language != "", "language", q.handleLanguage},
+		{q.Year != "", "year", q.handleYear},
+		{q.VoteAverage != "", "average", q.handleVoteAverage},
+		{q.VoteCount != "", "votes", q.handleVoteCount},
+		{q.Runtime != "", "runtime", q.handleRuntime},
+		{q.WithGenres != "", "genres", q.handleWithGenres},
+		{q.WithoutGenres != "", "without-genres", q.handleWithoutGenres},
+		{q.ReleasedSince != "", "since-last-run", q.handleReleasedSince},
+		{q.ReleasedBefore != "" && !q.yearHasLTE(), "only-released", q.handleReleasedBefore},
+		{q.SortBy != "", "sort", q.handleSortBy},
 	} {
 		if handler.condition {
 			if query, err = handler.handle(); err != nil {
+				var validationErr *ValidationError
+				if !errors.As(err, &validationErr) {
+					err = &ValidationError{Field: handler.field, Message: err.Error()}
+				}
 				return "", err
 			}
 			url += query
@@ -351,6 +1688,14 @@ func (qp *queryParams) handleLanguage() (string, error) {
 	if len(qp.Language) != iso639_1Length {
 		return "", fmt.Errorf("validation error: language must be a 2-letter ISO 639-1 code (see %s)", helpISO6391)
 	}
+	if _, ok := languages[strings.ToLower(qp.Language)]; !ok {
+		hint := ""
+		if suggestion := suggestLanguage(strings.ToLower(qp.Language)); suggestion != "" {
+			hint = fmt.Sprintf(", did you mean %q?", suggestion)
+		}
+		return "", fmt.Errorf("validation error: unknown ISO 639-1 language code %q%s (run: go-tmdb-cli languages)",
+			qp.Language, hint)
+	}
 	return fmt.Sprintf("with_original_language=%s&", qp.Language), nil
 }
 
@@ -367,14 +1712,36 @@ func (qp *queryParams) handleYear() (string, error) {
 	if len(parts) == 1 {
 		return fmt.Sprintf("primary_release_year=%s&", year), nil
 	}
-	if isValidComparison(parts[1]) {
-		return fmt.Sprintf("primary_release_date.%s=%s-01-01&", parts[1], year), nil
+	if parts[1] == "gte" {
+		return fmt.Sprintf("primary_release_date.gte=%s-01-01&", year), nil
+	}
+	if parts[1] == "lte" {
+		return fmt.Sprintf("primary_release_date.lte=%s&", qp.stricterLTE(year+"-01-01")), nil
 	}
 	year2, err := validateYear(parts[1])
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("primary_release_date.gte=%s-01-01&primary_release_date.lte=%s-12-31&", year, year2), nil
+	return fmt.Sprintf("primary_release_date.gte=%s-01-01&primary_release_date.lte=%s&",
+		year, qp.stricterLTE(year2+"-12-31")), nil
+}
+
+// yearHasLTE reports whether the year filter already bounds the release date above.
+func (qp *queryParams) yearHasLTE() bool {
+	parts := strings.Split(cleanString(qp.Year), ",")
+	return len(parts) == 2 && parts[1] != "gte"
+}
+
+// stricterLTE keeps the earliest of an upper release date and ReleasedBefore.
+func (qp *queryParams) stricterLTE(date string) string {
+	if qp.ReleasedBefore != "" && qp.ReleasedBefore < date {
+		return qp.ReleasedBefore
+	}
+	return date
+}
+
+func (qp *queryParams) handleReleasedBefore() (string, error) {
+	return fmt.Sprintf("primary_release_date.lte=%s&", qp.ReleasedBefore), nil
 }
 
 func (qp *queryParams) handleVoteAverage() (string, error) {
@@ -397,6 +1764,26 @@ func (qp *queryParams) handleVoteAverage() (string, error) {
 	return fmt.Sprintf("vote_average.gte=%s&vote_average.lte=%s&", val, val2), nil
 }
 
+func (qp *queryParams) handleRuntime() (string, error) {
+	qp.Runtime = cleanString(qp.Runtime)
+	parts := strings.Split(qp.Runtime, ",")
+	if len(parts) != 2 {
+		return "", fmt.Errorf(`runtime format: use "90,120", "90,gte" or "120,lte"`)
+	}
+	val, err := validateRuntime(parts[0])
+	if err != nil {
+		return "", err
+	}
+	if isValidComparison(parts[1]) {
+		return fmt.Sprintf("with_runtime.%s=%s&", parts[1], val), nil
+	}
+	val2, err := validateRuntime(parts[1])
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("with_runtime.gte=%s&with_runtime.lte=%s&", val, val2), nil
+}
+
 func (qp *queryParams) handleVoteCount() (string, error) {
 	qp.VoteCount = cleanString(qp.VoteCount)
 	parts := strings.Split(qp.VoteCount, ",")
@@ -420,8 +1807,17 @@ func (qp *queryParams) handleVoteCount() (string, error) {
 	return fmt.Sprintf("vote_count.gte=%s&vote_count.lte=%s&", val, val2), nil
 }
 
+// handleWithGenres skips the genres parameter for the "*" and "any" wildcards, so as
+// to override configured genres with no genre constraint.
 func (qp *queryParams) handleWithGenres() (string, error) {
-	query, err := handleGenres(qp.WithGenres, "with")
+	if isAnyGenre(qp.WithGenres) {
+		return "", nil
+	}
+	separator, err := genresSeparator(qp.GenresMatch)
+	if err != nil {
+		return "", &ValidationError{Field: "genres-match", Message: err.Error()}
+	}
+	query, err := handleGenres(qp.WithGenres, "with", separator)
 	if err != nil {
 		return "", err
 	}
@@ -429,30 +1825,126 @@ func (qp *queryParams) handleWithGenres() (string, error) {
 }
 
 func (qp *queryParams) handleWithoutGenres() (string, error) {
-	query, err := handleGenres(qp.WithoutGenres, "without")
+	query, err := handleGenres(qp.WithoutGenres, "without", ",")
 	if err != nil {
 		return "", err
 	}
 	return query, nil
 }
 
-func handleGenres(genres, suffix string) (string, error) {
+func (qp *queryParams) handleReleasedSince() (string, error) {
+	return fmt.Sprintf("primary_release_date.gte=%s&", qp.ReleasedSince), nil
+}
+
+func (qp *queryParams) handleSortBy() (string, error) {
+	return fmt.Sprintf("sort_by=%s&", qp.SortBy), nil
+}
+
+// serverSortFields maps the --sort fields to the discover sort_by fields of TMDB.
+var serverSortFields = map[string]string{
+	"date":    "primary_release_date",
+	"otitle":  "original_title",
+	"title":   "title",
+	"average": "vote_average",
+	"votes":   "vote_count",
+}
+
+// serverSortBy maps a --sort value, e.g. "average,desc", to the equivalent discover
+// sort_by, e.g. "vote_average.desc". It reports false for fields TMDB can't sort by.
+func serverSortBy(sort string) (string, bool, error) {
+	field, order, ok := strings.Cut(cleanString(sort), ",")
+	if !ok {
+		return "", false, fmt.Errorf(`sort format: expected "field, order", e.g. "average,desc" or "date,asc"`)
+	}
+	if _, err := (movies{}).getCompareFunc(field); err != nil {
+		return "", false, err
+	}
+	if err := validateOrder(order); err != nil {
+		return "", false, err
+	}
+	sortBy, ok := serverSortFields[field]
+	if !ok {
+		return "", false, nil
+	}
+	return sortBy + "." + order, true, nil
+}
+
+// listSortBy maps a list category to the equivalent discover sort_by.
+func listSortBy(category string) (string, error) {
+	sortBy := map[string]string{
+		"popular":   "popularity.desc",
+		"top_rated": "vote_average.desc",
+	}
+	value, ok := sortBy[category]
+	if !ok {
+		return "", fmt.Errorf("validation error: only the %v lists can be refined with discover filters",
+			[]string{"popular", "top_rated"})
+	}
+	return value, nil
+}
+
+// genresSeparator maps a genres match mode to TMDB's AND (",") or OR ("|") separator.
+func genresSeparator(match string) (string, error) {
+	switch cleanString(match) {
+	case "", "all":
+		return ",", nil
+	case "any":
+		return "|", nil
+	default:
+		return "", fmt.Errorf("validation error: genres match must be one of: %v", []string{"all", "any"})
+	}
+}
+
+// handleGenres maps a genre expression to TMDB IDs, keeping its operators: "|" joins
+// alternatives and "," joins groups with the separator, e.g. "animation|science-fiction,drama"
+// becomes "16|878,18" when all the groups must match. Empty groups are skipped, and
+// repeated IDs and groups are dropped, keeping the first seen.
+func handleGenres(genres, suffix, separator string) (string, error) {
 	if suffix != "with" && suffix != "without" {
 		return "", fmt.Errorf(`validation error: suffix must be "with" or "without"`)
 	}
-	var strIDs strings.Builder
-	genres = cleanString(genres)
-	genresList := strings.Split(genres, ",")
-	for _, g := range genresList {
-		strId, err := validateGenre(g)
-		if err != nil {
-			return "", err
+	var groups []string
+	for _, group := range strings.Split(cleanString(genres), ",") {
+		if strings.TrimSpace(group) == "" {
+			continue
+		}
+		alternatives := strings.Split(group, "|")
+		for j, g := range alternatives {
+			strId, err := validateGenre(g)
+			if err != nil {
+				return "", err
+			}
+			alternatives[j] = strId
 		}
-		strIDs.WriteString(fmt.Sprintf("%s,", strId))
+		groups = append(groups, strings.Join(dedupe(alternatives), "|"))
+	}
+	if len(groups) == 0 {
+		return "", fmt.Errorf("validation error: at least one genre is required")
 	}
-	genreParam := strIDs.String()
-	genreParam = strings.TrimSuffix(genreParam, ",")
-	return fmt.Sprintf("%s_genres=%s&", suffix, genreParam), nil
+	groups = dedupe(groups)
+	if separator == "|" {
+		groups = dedupe(strings.Split(strings.Join(groups, "|"), "|"))
+	}
+	return fmt.Sprintf("%s_genres=%s&", suffix, strings.Join(groups, separator)), nil
+}
+
+// dedupe drops the repeated values, keeping the first-seen order.
+func dedupe(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := values[:0]
+	for _, v := range values {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		unique = append(unique, v)
+	}
+	return unique
+}
+
+// currentYear computes the year at call time, in the configured time zone.
+func currentYear() int {
+	return nowFunc().In(yearLocation).Year()
 }
 
 func validateYear(v string) (string, error) {
@@ -461,6 +1953,7 @@ func validateYear(v string) (string, error) {
 		return "", fmt.Errorf(`year format: use "2000", "2000,2010", "2000,gte", or "2000,lte"`)
 	}
 	part1, _ := strconv.Atoi(v)
+	yearNow := currentYear()
 	if part1 < earliestMovie || part1 > yearNow {
 		return "", fmt.Errorf("year must be between %d and %d", earliestMovie, yearNow)
 	}
@@ -478,6 +1971,23 @@ func validateVote(v string) (string, error) {
 	return v, nil
 }
 
+// validateRating parses a movie rating, a multiple of 0.5 between 0.5 and 10.
+func validateRating(v string) (float64, error) {
+	value, err := strconv.ParseFloat(cleanString(v), 64)
+	if err != nil || value < ratingStep || value > maxVoteAverage || math.Mod(value, ratingStep) != 0 {
+		return 0, fmt.Errorf(`validation error: rating must be a multiple of 0.5 between 0.5 and 10, e.g. "7.5", got %q`, v)
+	}
+	return value, nil
+}
+
+func validateRuntime(v string) (string, error) {
+	runtime, err := strconv.Atoi(v)
+	if err != nil || runtime < 0 {
+		return "", fmt.Errorf(`validation error: runtime must be a number of minutes, e.g. "90"`)
+	}
+	return v, nil
+}
+
 func validateVoteCount(v string) (string, error) {
 	voteCount, err := strconv.ParseInt(v, 10, 64)
 	if err != nil {
@@ -506,6 +2016,71 @@ func validateGenre(v string) (string, error) {
 	return strconv.Itoa(id), nil
 }
 
+// genreName maps a TMDB genre ID back to its CLI name, or the ID itself when unknown.
+func genreName(id int) string {
+	for name, genreID := range genresMap {
+		if genreID == id {
+			return name
+		}
+	}
+	return strconv.Itoa(id)
+}
+
+var genreIDPattern = regexp.MustCompile(`[0-9]+`)
+
+// genreNames maps the TMDB genre IDs of a genre expression to their CLI names,
+// keeping its operators and any other token, e.g. "16|878,18" becomes
+// "animation|science-fiction,drama".
+func genreNames(genres string) string {
+	return genreIDPattern.ReplaceAllStringFunc(genres, func(id string) string {
+		genreID, _ := strconv.Atoi(id)
+		return genreName(genreID)
+	})
+}
+
+// genreIDs maps the genre names of a genre expression to their TMDB IDs, skipping
+// unknown ones.
+func genreIDs(genres string) map[int]bool {
+	ids := make(map[int]bool)
+	isOperator := func(r rune) bool { return r == ',' || r == '|' }
+	for _, name := range strings.FieldsFunc(cleanString(genres), isOperator) {
+		if id, ok := genresMap[name]; ok {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+// parseOnlyGenres maps a comma-separated list of genre names to their TMDB IDs,
+// rejecting unknown names, for the --only-genres post-filter.
+func parseOnlyGenres(genres string) (map[int]bool, error) {
+	ids := make(map[int]bool)
+	for _, name := range strings.Split(cleanString(genres), ",") {
+		if name == "" {
+			continue
+		}
+		id, err := validateGenre(name)
+		if err != nil {
+			return nil, &ValidationError{Field: "only-genres", Message: err.Error()}
+		}
+		genreID, _ := strconv.Atoi(id)
+		ids[genreID] = true
+	}
+	if len(ids) == 0 {
+		return nil, &ValidationError{Field: "only-genres", Message: "validation error: only genres must name a genre"}
+	}
+	return ids, nil
+}
+
+// isAnyGenre reports whether genres is empty or the "*" or "any" wildcard.
+func isAnyGenre(genres string) bool {
+	switch strings.ToLower(cleanString(genres)) {
+	case "", "*", "any":
+		return true
+	}
+	return false
+}
+
 func isValidComparison(v string) bool {
 	return v == "gte" || v == "lte"
 }
@@ -1,13 +1,27 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	mathrand "math/rand/v2"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/cenkalti/backoff/v5"
 )
 
 func TestUnitDeduplicate(t *testing.T) {
@@ -23,6 +37,336 @@ func TestUnitDeduplicate(t *testing.T) {
 	}
 }
 
+func TestUnitDeduplicate_Sources(t *testing.T) {
+	tagged := func(m movie, source string) movie {
+		m.Source = source
+		return m
+	}
+	testCases := []struct {
+		name   string
+		movies movies
+		want   []string
+	}{
+		{
+			name: "sources merged in order",
+			movies: movies{
+				tagged(fakeMovieList[0], "now_playing"), tagged(fakeMovieList[1], "now_playing"),
+				tagged(fakeMovieList[1], "top_rated"), tagged(fakeMovieList[0], "top_rated"),
+			},
+			want: []string{"now_playing, top_rated", "now_playing, top_rated"},
+		},
+		{
+			name:   "repeated source kept once",
+			movies: movies{tagged(fakeMovieList[0], "a.json"), tagged(fakeMovieList[0], "a.json")},
+			want:   []string{"a.json"},
+		},
+		{
+			name:   "already merged sources",
+			movies: movies{tagged(fakeMovieList[0], "a.json, b.json"), tagged(fakeMovieList[0], "b.json, c.json")},
+			want:   []string{"a.json, b.json, c.json"},
+		},
+		{
+			name:   "untagged movies",
+			movies: movies{fakeMovieList[0], tagged(fakeMovieList[0], "top_rated"), fakeMovieList[0]},
+			want:   []string{"top_rated"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got := tc.movies.deduplicate()
+			// Assert
+			var sources []string
+			for _, m := range got {
+				sources = append(sources, m.Source)
+			}
+			if !slices.Equal(sources, tc.want) {
+				t.Errorf("expected sources %q, but got %q", tc.want, sources)
+			}
+		})
+	}
+}
+
+func TestUnitDeduplicateBy(t *testing.T) {
+	fakeMovies := movies{
+		{ID: 1, Title: "Clash of Titans"},
+		{ID: 2, Title: "  clash of TITANS"},
+		{ID: 3, Title: "Rise of the Heroes"},
+		{ID: 4},
+		{ID: 5},
+	}
+	testCases := []struct {
+		name    string
+		by      string
+		wantIDs []int
+		wantErr bool
+	}{
+		{name: "default by id", wantIDs: []int{1, 2, 3, 4, 5}},
+		{name: "by id", by: "id", wantIDs: []int{1, 2, 3, 4, 5}},
+		{name: "by title keeps untitled movies", by: "title", wantIDs: []int{1, 3, 4, 5}},
+		{name: "unknown key", by: "year", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			key, err := dedupeKey(tc.by)
+			if tc.wantErr {
+				assertNotNil(t, err)
+				return
+			}
+			assertNoError(t, err)
+			// Act
+			got := fakeMovies.deduplicateBy(key)
+			// Assert
+			assertMovieIDs(t, tc.wantIDs, got)
+		})
+	}
+}
+
+func TestUnitCollapseBy(t *testing.T) {
+	// Arrange
+	fakeMovies := movies{
+		{ID: 1, Title: "Clash of Titans"},
+		{ID: 2, Title: "  clash of TITANS"},
+		{ID: 1, Title: "Clash of Titans"},
+		{ID: 3, Title: "Rise of the Heroes"},
+	}
+	// Act
+	byID, removedByID := fakeMovies.collapseBy(idKey)
+	byTitle, removedByTitle := fakeMovies.collapseBy(titleKey)
+	// Assert
+	assertMovieIDs(t, []int{1, 2, 3}, byID)
+	assertMovieIDs(t, []int{1, 3}, byTitle)
+	if !slices.Equal(removedByID, []int{1}) {
+		t.Errorf("expected the removed IDs %v, but got %v", []int{1}, removedByID)
+	}
+	if !slices.Equal(removedByTitle, []int{2, 1}) {
+		t.Errorf("expected the removed IDs %v, but got %v", []int{2, 1}, removedByTitle)
+	}
+}
+
+func TestUnitReverse(t *testing.T) {
+	// Arrange
+	fakeMovies := movies{fakeMovieList[0], fakeMovieList[1], fakeMovieList[2]}
+	// Act
+	got := fakeMovies.reverse()
+	// Assert
+	assertMovieIDs(t, []int{3, 2, 1}, got)
+}
+
+func TestUnitSample(t *testing.T) {
+	testCases := []struct {
+		name    string
+		n       int
+		wantErr bool
+	}{
+		{name: "some movies", n: 3},
+		{name: "all movies", n: len(fakeMovieList)},
+		{name: "more than available", n: len(fakeMovieList) + 1, wantErr: true},
+		{name: "zero", n: 0, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got, err := fakeMovieList.sample(tc.n, mathrand.New(mathrand.NewPCG(1, 1)))
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				return
+			}
+			assertNoError(t, err)
+			if len(got) != tc.n {
+				t.Fatalf("expected %d movies, but got %d", tc.n, len(got))
+			}
+			if len(got.deduplicate()) != tc.n {
+				t.Errorf("expected a sample without replacement, but got %v", got)
+			}
+		})
+	}
+}
+
+func TestUnitSample_Seed(t *testing.T) {
+	// Act
+	first, _ := fakeMovieList.sample(5, mathrand.New(mathrand.NewPCG(42, 42)))
+	second, _ := fakeMovieList.sample(5, mathrand.New(mathrand.NewPCG(42, 42)))
+	// Assert
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("expected the same seed to pick the same movies, but got %v and %v", first, second)
+	}
+}
+
+func TestUnitIsReleased(t *testing.T) {
+	testCases := []struct {
+		name        string
+		releaseDate string
+		want        bool
+	}{
+		{name: "released before today", releaseDate: "2024-06-14", want: true},
+		{name: "released today", releaseDate: "2024-06-15", want: true},
+		{name: "released after today", releaseDate: "2024-06-16"},
+		{name: "missing release date"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got := movie{ReleaseDate: tc.releaseDate}.isReleased("2024-06-15")
+			// Assert
+			if got != tc.want {
+				t.Errorf("expected released %t, but got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUnitCountByYear(t *testing.T) {
+	// Arrange
+	fakeMovies := append(movies{{ID: 41, Title: "Undated"}}, fakeMovieList...)
+	want := []yearCount{
+		{Year: "2023", Count: 12},
+		{Year: "2024", Count: 12},
+		{Year: "2025", Count: 12},
+		{Year: "2026", Count: 4},
+		{Year: unknownYear, Count: 1},
+	}
+	// Act
+	got := fakeMovies.countByYear()
+	// Assert
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("expected year counts %+v, got %+v", want, got)
+	}
+}
+
+func TestUnitGroupBy(t *testing.T) {
+	// Arrange
+	fakeMovies := append(movies{
+		{ID: 41, Title: "Undated", VoteAverage: 5.0},
+		{ID: 42, ReleaseDate: "1999-12-31", VoteAverage: 6.0},
+		{ID: 43, ReleaseDate: "1990-01-01", VoteAverage: 8.0},
+	}, fakeMovieList[:4]...)
+	testCases := []struct {
+		name    string
+		field   string
+		want    []groupSummary
+		wantErr bool
+	}{
+		{
+			name:  "by decade",
+			field: "decade",
+			want: []groupSummary{
+				{Group: "1990s", Count: 2, Average: 7.0},
+				{Group: "2020s", Count: 4, Average: 8.125},
+				{Group: unknownGroup, Count: 1, Average: 5.0},
+			},
+		},
+		{
+			name:  "by language without languages",
+			field: "language",
+			want:  []groupSummary{{Group: unknownGroup, Count: 7, Average: 51.5 / 7}},
+		},
+		{name: "invalid field", field: "title", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got, err := fakeMovies.groupBy(tc.field)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				return
+			}
+			assertNoError(t, err)
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("expected groups %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUnitGroupBy_Genre(t *testing.T) {
+	// Arrange
+	fakeMovies := movies{
+		{ID: 1, GenreIDs: []int{28, 18}, VoteAverage: 8.0},
+		{ID: 2, GenreIDs: []int{18, 18}, VoteAverage: 6.0},
+		{ID: 3, VoteAverage: 7.0},
+	}
+	want := []groupSummary{
+		{Group: "action", Count: 1, Average: 8.0},
+		{Group: "drama", Count: 2, Average: 7.0},
+		{Group: unknownGroup, Count: 1, Average: 7.0},
+	}
+	// Act
+	got, err := fakeMovies.groupBy("genre")
+	// Assert
+	assertNoError(t, err)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("expected groups %+v, got %+v", want, got)
+	}
+}
+
+func TestUnitParseReleaseDate(t *testing.T) {
+	testCases := []struct {
+		value  string
+		want   string
+		wantOK bool
+	}{
+		{value: "2023-02-01", want: "2023-02-01", wantOK: true},
+		{value: "2023-02-01T22:30:00.000Z", want: "2023-02-01", wantOK: true},
+		{value: "2023-02-01T01:00:00+09:00", want: "2023-02-01", wantOK: true}, // Calendar day as given
+		{value: "2023", want: "2023-01-01", wantOK: true},
+		{value: " 2023-02-01 ", want: "2023-02-01", wantOK: true},
+		{value: ""},
+		{value: "02/01/2023"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			// Act
+			got, ok := parseReleaseDate(tc.value)
+			// Assert
+			if ok != tc.wantOK {
+				t.Fatalf("expected ok %t, but got %t", tc.wantOK, ok)
+			}
+			if ok && got.Format(time.DateOnly) != tc.want {
+				t.Errorf("expected date %s, but got %s", tc.want, got.Format(time.DateOnly))
+			}
+		})
+	}
+}
+
+func TestUnitSortByField_MixedDates(t *testing.T) {
+	fakeMovies := movies{
+		{ID: 1, ReleaseDate: "2023-06-01"},
+		{ID: 2, ReleaseDate: ""},
+		{ID: 3, ReleaseDate: "2022"},
+		{ID: 4, ReleaseDate: "2023-01-15T00:00:00.000Z"},
+		{ID: 5, ReleaseDate: "2023"},
+		{ID: 6, ReleaseDate: "2023-01-01"},
+	}
+	testCases := []struct {
+		name    string
+		param   string
+		nulls   string
+		wantIDs []int
+	}{
+		{name: "ascending", param: "date,asc", wantIDs: []int{2, 3, 5, 6, 4, 1}},
+		{name: "descending", param: "date,desc", wantIDs: []int{1, 4, 6, 5, 3, 2}},
+		{name: "descending nulls first", param: "date,desc", nulls: "first", wantIDs: []int{2, 1, 4, 6, 5, 3}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for range 10 { // Deterministic whatever the input order
+				// Arrange
+				shuffled := slices.Clone(fakeMovies)
+				mathrand.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+				// Act
+				got, err := shuffled.sortByFieldNulls(tc.param, tc.nulls)
+				// Assert
+				assertNoError(t, err)
+				assertMovieIDs(t, tc.wantIDs, got)
+			}
+		})
+	}
+}
+
 func TestUnitSortByField(t *testing.T) {
 	fakeMovies := movies{fakeMovieList[0], fakeMovieList[1], fakeMovieList[2]}
 
@@ -120,152 +464,652 @@ func TestUnitSortByField(t *testing.T) {
 	}
 }
 
-func TestUnitList(t *testing.T) {
+func TestUnitSortByField_Numeric(t *testing.T) {
+	// Values whose lexicographic order differs from their numeric order
+	fakeMovies := movies{
+		{ID: 1, VoteAverage: 9.5, VoteCount: 20},
+		{ID: 2, VoteAverage: 10.0, VoteCount: 100},
+		{ID: 3, VoteAverage: 2.0, VoteCount: 3},
+	}
 	testCases := []struct {
 		name    string
 		param   string
-		want    string
-		wantErr bool
+		wantIDs []int
 	}{
-		{
-			name:  "now playing",
-			param: "now_playing",
-			want:  "https://api.themoviedb.org/3/movie/now_playing?",
-		},
-		{
-			name:  "popular",
-			param: "popular",
-			want:  "https://api.themoviedb.org/3/movie/popular?",
-		},
-		{
-			name:  "top rated",
-			param: "top_rated",
-			want:  "https://api.themoviedb.org/3/movie/top_rated?",
-		},
-		{
-			name:  "upcoming",
-			param: "upcoming",
-			want:  "https://api.themoviedb.org/3/movie/upcoming?",
-		},
-		{
-			name:    "invalid param",
-			param:   "invalid",
-			wantErr: true,
-		},
+		{name: "votes ascending", param: "votes,asc", wantIDs: []int{3, 1, 2}},
+		{name: "votes descending", param: "votes,desc", wantIDs: []int{2, 1, 3}},
+		{name: "average ascending", param: "average,asc", wantIDs: []int{3, 1, 2}},
+		{name: "average descending", param: "average,desc", wantIDs: []int{2, 1, 3}},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// Arrange
-			builder := newURLBuilder()
+			m := make(movies, len(fakeMovies))
+			copy(m, fakeMovies)
 			// Act
-			got, err := builder.list(tc.param)
+			got, err := m.sortByField(tc.param)
 			// Assert
-			if tc.wantErr {
-				assertNotNil(t, err)
-			} else {
-				assertNoError(t, err)
-				assertURL(t, tc.want, got)
+			assertNoError(t, err)
+			assertMovieIDs(t, tc.wantIDs, got)
+		})
+	}
+}
+
+func TestUnitHasOnlyGenres(t *testing.T) {
+	drama, horror, romance := genresMap["drama"], genresMap["horror"], genresMap["romance"]
+	testCases := []struct {
+		name     string
+		genreIDs []int
+		want     bool
+	}{
+		{name: "same genres", genreIDs: []int{drama, romance}, want: true},
+		{name: "subset", genreIDs: []int{romance}, want: true},
+		{name: "no genres", want: true},
+		{name: "one genre outside", genreIDs: []int{drama, horror}, want: false},
+		{name: "only genres outside", genreIDs: []int{horror}, want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			m := movie{ID: 1, GenreIDs: tc.genreIDs}
+			allowed, err := parseOnlyGenres("drama,romance")
+			assertNoError(t, err)
+			// Act
+			got := m.hasOnlyGenres(allowed)
+			// Assert
+			if got != tc.want {
+				t.Errorf("expected %v, but got %v", tc.want, got)
 			}
 		})
 	}
 }
 
-func TestUnitDiscover(t *testing.T) {
+func TestUnitGenreNames(t *testing.T) {
+	testCases := []struct {
+		name   string
+		genres string
+		want   string
+	}{
+		{name: "IDs with operators", genres: "16|878,18", want: "animation|science-fiction,drama"},
+		{name: "names left as is", genres: "drama,27", want: "drama,horror"},
+		{name: "unknown ID", genres: "1", want: "1"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got := genreNames(tc.genres)
+			// Assert
+			if got != tc.want {
+				t.Errorf("expected %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUnitParseOnlyGenres(t *testing.T) {
 	testCases := []struct {
 		name    string
-		query   queryParams
-		want    string
+		genres  string
+		wantErr bool
+	}{
+		{name: "known genres", genres: "drama,romance"},
+		{name: "empty groups", genres: "drama,,romance,"},
+		{name: "unknown genre", genres: "drama,dramedy", wantErr: true},
+		{name: "no genre", genres: ",", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got, err := parseOnlyGenres(tc.genres)
+			// Assert
+			if tc.wantErr {
+				var validationErr *ValidationError
+				if !errors.As(err, &validationErr) || validationErr.Field != "only-genres" {
+					t.Fatalf("expected an only-genres validation error, but got %v", err)
+				}
+				return
+			}
+			assertNoError(t, err)
+			want := map[int]bool{genresMap["drama"]: true, genresMap["romance"]: true}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("expected %v, but got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestUnitSortByField_Ties(t *testing.T) {
+	testCases := []struct {
+		name    string
+		param   string
+		wantIDs []int
+	}{
+		{name: "ascending", param: "average,asc", wantIDs: []int{3, 1, 4, 2, 5}},
+		{name: "descending", param: "average,desc", wantIDs: []int{2, 5, 1, 4, 3}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			fakeMovies := movies{
+				{ID: 1, VoteAverage: 7}, {ID: 2, VoteAverage: 8}, {ID: 3, VoteAverage: 6},
+				{ID: 4, VoteAverage: 7}, {ID: 5, VoteAverage: 8},
+			}
+			// Act
+			got, err := fakeMovies.sortByField(tc.param)
+			// Assert
+			assertNoError(t, err)
+			assertMovieIDs(t, tc.wantIDs, got)
+		})
+	}
+}
+
+func TestUnitSortByField_TieBreakByID(t *testing.T) {
+	testCases := []struct {
+		name    string
+		param   string
+		wantIDs []int
+	}{
+		{name: "ascending", param: "average,asc", wantIDs: []int{3, 6, 1, 4, 7, 2, 5}},
+		{name: "descending", param: "average,desc", wantIDs: []int{2, 5, 1, 4, 7, 3, 6}},
+		{name: "all equal", param: "votes,desc", wantIDs: []int{1, 2, 3, 4, 5, 6, 7}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			fetched := movies{
+				{ID: 1, VoteAverage: 7}, {ID: 2, VoteAverage: 8}, {ID: 3, VoteAverage: 6}, {ID: 4, VoteAverage: 7},
+				{ID: 5, VoteAverage: 8}, {ID: 6, VoteAverage: 6}, {ID: 7, VoteAverage: 7},
+			}
+			rng := mathrand.New(mathrand.NewPCG(1, 2))
+			for range 20 {
+				shuffled := slices.Clone(fetched)
+				rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+				// Act
+				got, err := shuffled.sortByField(tc.param)
+				// Assert
+				assertNoError(t, err)
+				assertMovieIDs(t, tc.wantIDs, got)
+			}
+		})
+	}
+}
+
+func TestUnitSortByFieldNulls(t *testing.T) {
+	missingDate := movie{ID: 99, Title: "Undated"}
+	testCases := []struct {
+		name    string
+		param   string
+		nulls   string
+		extra   movies
+		wantIDs []int
+		wantErr bool
+	}{
+		{name: "date asc nulls first", param: "date,asc", nulls: "first", wantIDs: []int{99, 1, 2, 3}},
+		{name: "date asc nulls last", param: "date,asc", nulls: "last", wantIDs: []int{1, 2, 3, 99}},
+		{name: "date desc nulls first", param: "date,desc", nulls: "first", wantIDs: []int{99, 3, 2, 1}},
+		{name: "date desc nulls last", param: "date,desc", nulls: "last", wantIDs: []int{3, 2, 1, 99}},
+		{
+			name:    "missing dates by ID",
+			param:   "date,desc",
+			nulls:   "last",
+			extra:   movies{{ID: 98, Title: "Also undated"}},
+			wantIDs: []int{3, 2, 1, 98, 99},
+		},
+		{name: "invalid nulls", param: "date,asc", nulls: "middle", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			fakeMovies := append(movies{fakeMovieList[1], missingDate, fakeMovieList[2], fakeMovieList[0]}, tc.extra...)
+			// Act
+			got, err := fakeMovies.sortByFieldNulls(tc.param, tc.nulls)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				return
+			}
+			assertNoError(t, err)
+			assertMovieIDs(t, tc.wantIDs, got)
+		})
+	}
+}
+
+func TestUnitSortByField_Runtime(t *testing.T) {
+	testCases := []struct {
+		name    string
+		param   string
+		nulls   string
+		wantIDs []int
+	}{
+		{name: "ascending", param: "runtime,asc", wantIDs: []int{0, 3, 1, 2}},
+		{name: "descending", param: "runtime,desc", wantIDs: []int{2, 1, 3, 0}},
+		{name: "unknown runtime last", param: "runtime,asc", nulls: "last", wantIDs: []int{3, 1, 2, 0}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			fakeMovies := movies{{ID: 1, Runtime: 120}, {ID: 2, Runtime: 181}, {ID: 0}, {ID: 3, Runtime: 95}}
+			// Act
+			got, err := fakeMovies.sortByFieldNulls(tc.param, tc.nulls)
+			// Assert
+			assertNoError(t, err)
+			assertMovieIDs(t, tc.wantIDs, got)
+		})
+	}
+}
+
+func TestUnitList(t *testing.T) {
+	testCases := []struct {
+		name    string
+		param   string
+		want    string
 		wantErr bool
 	}{
-		// Language
-		{
-			name: "valid iso language code",
-			query: queryParams{
-				Language: "fr", // it could be any ISO 639-1 code
-			},
-			want: "https://api.themoviedb.org/3/discover/movie?with_original_language=fr",
-		},
-		{
-			name: "invalid iso language code too long",
-			query: queryParams{
-				Language: "aaa", // Not a two-letter ISO 639-1 code
-			},
-			wantErr: true,
-		},
-		{
-			name: "invalid iso language code too short",
-			query: queryParams{
-				Language: "a", // Not a two-letter ISO 639-1 code
-			},
-			wantErr: true,
-		},
-		// Year(s)
-		{
-			name: "valid primary release year",
-			query: queryParams{
-				Year: "2000",
-			},
-			want: "https://api.themoviedb.org/3/discover/movie?primary_release_year=2000",
-		},
-		{
-			name: "valid primary release dates",
-			query: queryParams{
-				Year: "2000,2010",
-			},
-			want: "https://api.themoviedb.org/3/discover/movie?primary_release_date.gte=2000-01-01&primary_release_date.lte=2010-12-31",
-		},
-		{
-			name: "valid primary release date gte",
-			query: queryParams{
-				Year: "2000,gte",
-			},
-			want: "https://api.themoviedb.org/3/discover/movie?primary_release_date.gte=2000-01-01",
-		},
 		{
-			name: "valid primary release date lte",
-			query: queryParams{
-				Year: "2000,lte",
-			},
-			want: "https://api.themoviedb.org/3/discover/movie?primary_release_date.lte=2000-01-01",
+			name:  "now playing",
+			param: "now_playing",
+			want:  "https://api.themoviedb.org/3/movie/now_playing?",
 		},
 		{
-			name: "invalid non numeric primary release year",
-			query: queryParams{
-				Year: "abcd",
-			},
-			wantErr: true,
+			name:  "popular",
+			param: "popular",
+			want:  "https://api.themoviedb.org/3/movie/popular?",
 		},
 		{
-			name: "invalid primary release year format",
-			query: queryParams{
-				Year: "1", // Can't be parsed as YYYY
-			},
-			wantErr: true,
+			name:  "top rated",
+			param: "top_rated",
+			want:  "https://api.themoviedb.org/3/movie/top_rated?",
 		},
 		{
-			name: "invalid primary release year only comma",
-			query: queryParams{
-				Year: ",",
-			},
-			wantErr: true,
+			name:  "upcoming",
+			param: "upcoming",
+			want:  "https://api.themoviedb.org/3/movie/upcoming?",
 		},
 		{
-			name: "invalid primary release year below min",
-			query: queryParams{
-				Year: "1887", // No existing movies for this year
-			},
+			name:    "invalid param",
+			param:   "invalid",
 			wantErr: true,
 		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			builder := newURLBuilder()
+			// Act
+			got, err := builder.list(tc.param)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+			} else {
+				assertNoError(t, err)
+				assertURL(t, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUnitListSortBy(t *testing.T) {
+	testCases := []struct {
+		category string
+		want     string
+		wantErr  bool
+	}{
+		{category: "popular", want: "popularity.desc"},
+		{category: "top_rated", want: "vote_average.desc"},
+		{category: "now_playing", wantErr: true},
+		{category: "upcoming", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.category, func(t *testing.T) {
+			// Act
+			got, err := listSortBy(tc.category)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				return
+			}
+			assertNoError(t, err)
+			if got != tc.want {
+				t.Errorf("expected sort_by %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUnitServerSortBy(t *testing.T) {
+	testCases := []struct {
+		sort    string
+		want    string
+		wantOK  bool
+		wantErr bool
+	}{
+		{sort: "average,desc", want: "vote_average.desc", wantOK: true},
+		{sort: "votes,asc", want: "vote_count.asc", wantOK: true},
+		{sort: "date,desc", want: "primary_release_date.desc", wantOK: true},
+		{sort: "otitle,asc", want: "original_title.asc", wantOK: true},
+		{sort: "title,desc", want: "title.desc", wantOK: true},
+		{sort: "runtime,desc"},
+		{sort: "rating,desc", wantErr: true},
+		{sort: "average,up", wantErr: true},
+		{sort: "average", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.sort, func(t *testing.T) {
+			// Act
+			got, ok, err := serverSortBy(tc.sort)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				return
+			}
+			assertNoError(t, err)
+			if ok != tc.wantOK || got != tc.want {
+				t.Errorf("expected sort_by %q (%t), but got %q (%t)", tc.want, tc.wantOK, got, ok)
+			}
+		})
+	}
+}
+
+func TestUnitCollection(t *testing.T) {
+	testCases := []struct {
+		name    string
+		id      string
+		want    string
+		wantErr bool
+	}{
+		{name: "valid id", id: "119", want: "https://api.themoviedb.org/3/collection/119"},
+		{name: "non numeric id", id: "lotr", wantErr: true},
+		{name: "negative id", id: "-1", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			builder := newURLBuilder()
+			// Act
+			got, err := builder.collection(tc.id)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+			} else {
+				assertNoError(t, err)
+				assertURL(t, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUnitParseMovieID(t *testing.T) {
+	testCases := []struct {
+		name    string
+		id      string
+		want    int
+		wantErr bool
+	}{
+		{name: "valid id", id: "603", want: 603},
+		{name: "non numeric id", id: "matrix", wantErr: true},
+		{name: "zero id", id: "0", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got, err := parseMovieID(tc.id)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+			} else {
+				assertNoError(t, err)
+				if got != tc.want {
+					t.Errorf("expected ID %d, but got %d", tc.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestUnitValidateRating(t *testing.T) {
+	testCases := []struct {
+		name    string
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{name: "half point", value: "7.5", want: 7.5},
+		{name: "whole point", value: "8", want: 8},
+		{name: "lowest", value: "0.5", want: 0.5},
+		{name: "highest", value: "10", want: 10},
+		{name: "not a multiple of 0.5", value: "7.3", wantErr: true},
+		{name: "zero", value: "0", wantErr: true},
+		{name: "above 10", value: "10.5", wantErr: true},
+		{name: "non numeric", value: "great", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got, err := validateRating(tc.value)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+			} else {
+				assertNoError(t, err)
+				if got != tc.want {
+					t.Errorf("expected rating %v, but got %v", tc.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestUnitFind(t *testing.T) {
+	testCases := []struct {
+		name    string
+		id      string
+		source  string
+		want    string
+		wantErr bool
+	}{
 		{
-			name: "invalid primary release year above current year",
-			query: queryParams{
-				Year: fmt.Sprintf("%d", time.Now().Year()+1), // No existing movies for this year
-			},
-			wantErr: true,
+			name:   "valid imdb id",
+			id:     "tt0133093",
+			source: "imdb_id",
+			want:   "https://api.themoviedb.org/3/find/tt0133093?external_source=imdb_id",
 		},
-		{
-			name: "invalid primary release dates first year not in valid yyyy format",
-			query: queryParams{
+		{name: "missing tt prefix", id: "0133093", source: "imdb_id", wantErr: true},
+		{name: "non digits", id: "tt01330ab", source: "imdb_id", wantErr: true},
+		{name: "prefix only", id: "tt", source: "imdb_id", wantErr: true},
+		{name: "unsupported source", id: "tt0133093", source: "facebook_id", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			builder := newURLBuilder()
+			// Act
+			got, err := builder.find(tc.id, tc.source)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+			} else {
+				assertNoError(t, err)
+				assertURL(t, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUnitSuggestLanguage(t *testing.T) {
+	testCases := []struct {
+		code string
+		want string
+	}{
+		{code: "em", want: "en"}, // n is next to m
+		{code: "rf", want: "fr"}, // swapped letters
+		{code: "99", want: ""},   // no close match
+		{code: "eng", want: ""},  // not two letters
+	}
+	for _, tc := range testCases {
+		t.Run(tc.code, func(t *testing.T) {
+			// Act
+			got := suggestLanguage(tc.code)
+			// Assert
+			if got != tc.want {
+				t.Errorf("expected suggestion %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUnitHandleLanguage_Suggestion(t *testing.T) {
+	testCases := []struct {
+		code string
+		want string
+	}{
+		{code: "em", want: `did you mean "en"?`},
+		{code: "rf", want: `did you mean "fr"?`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.code, func(t *testing.T) {
+			// Arrange
+			qp := queryParams{Language: tc.code}
+			// Act
+			_, err := qp.handleLanguage()
+			// Assert
+			assertNotNil(t, err)
+			assertContains(t, err.Error(), []string{tc.want, "(run: go-tmdb-cli languages)"})
+		})
+	}
+}
+
+func TestUnitRedactURL(t *testing.T) {
+	testCases := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{
+			name: "without key",
+			url:  "https://api.themoviedb.org/3/discover/movie?with_original_language=fr",
+			want: "https://api.themoviedb.org/3/discover/movie?with_original_language=fr",
+		},
+		{
+			name: "with key",
+			url:  "https://api.themoviedb.org/3/movie/popular?api_key=secret&page=1",
+			want: "https://api.themoviedb.org/3/movie/popular?api_key=REDACTED&page=1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got := redactURL(tc.url)
+			// Assert
+			if got != tc.want {
+				t.Errorf("expected URL %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUnitDiscover(t *testing.T) {
+	testCases := []struct {
+		name    string
+		query   queryParams
+		want    string
+		wantErr bool
+	}{
+		// Language
+		{
+			name: "valid iso language code",
+			query: queryParams{
+				Language: "fr", // it could be any ISO 639-1 code
+			},
+			want: "https://api.themoviedb.org/3/discover/movie?with_original_language=fr",
+		},
+		{
+			name: "invalid iso language code too long",
+			query: queryParams{
+				Language: "aaa", // Not a two-letter ISO 639-1 code
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid iso language code too short",
+			query: queryParams{
+				Language: "a", // Not a two-letter ISO 639-1 code
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid iso language code unknown",
+			query: queryParams{
+				Language: "zz", // Two letters, but not an ISO 639-1 code
+			},
+			wantErr: true,
+		},
+		// Year(s)
+		{
+			name: "valid primary release year",
+			query: queryParams{
+				Year: "2000",
+			},
+			want: "https://api.themoviedb.org/3/discover/movie?primary_release_year=2000",
+		},
+		{
+			name: "valid primary release dates",
+			query: queryParams{
+				Year: "2000,2010",
+			},
+			want: "https://api.themoviedb.org/3/discover/movie?primary_release_date.gte=2000-01-01&primary_release_date.lte=2010-12-31",
+		},
+		{
+			name: "valid primary release date gte",
+			query: queryParams{
+				Year: "2000,gte",
+			},
+			want: "https://api.themoviedb.org/3/discover/movie?primary_release_date.gte=2000-01-01",
+		},
+		{
+			name: "valid primary release date lte",
+			query: queryParams{
+				Year: "2000,lte",
+			},
+			want: "https://api.themoviedb.org/3/discover/movie?primary_release_date.lte=2000-01-01",
+		},
+		{
+			name: "invalid non numeric primary release year",
+			query: queryParams{
+				Year: "abcd",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid primary release year format",
+			query: queryParams{
+				Year: "1", // Can't be parsed as YYYY
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid primary release year only comma",
+			query: queryParams{
+				Year: ",",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid primary release year below min",
+			query: queryParams{
+				Year: "1887", // No existing movies for this year
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid primary release year above current year",
+			query: queryParams{
+				Year: fmt.Sprintf("%d", time.Now().Year()+1), // No existing movies for this year
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid primary release dates first year not in valid yyyy format",
+			query: queryParams{
 				Year: "1,2000",
 			},
 			wantErr: true,
@@ -333,6 +1177,41 @@ func TestUnitDiscover(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		// Released before
+		{
+			name:  "released before without year",
+			query: queryParams{ReleasedBefore: "2024-06-15"},
+			want:  "https://api.themoviedb.org/3/discover/movie?primary_release_date.lte=2024-06-15",
+		},
+		{
+			name:  "released before with a single year",
+			query: queryParams{Year: "2000", ReleasedBefore: "2024-06-15"},
+			want: "https://api.themoviedb.org/3/discover/movie?primary_release_year=2000" +
+				"&primary_release_date.lte=2024-06-15",
+		},
+		{
+			name:  "released before with a lower bound",
+			query: queryParams{Year: "2000,gte", ReleasedBefore: "2024-06-15"},
+			want: "https://api.themoviedb.org/3/discover/movie?primary_release_date.gte=2000-01-01" +
+				"&primary_release_date.lte=2024-06-15",
+		},
+		{
+			name:  "released before stricter than the year range",
+			query: queryParams{Year: "2000,2024", ReleasedBefore: "2024-06-15"},
+			want: "https://api.themoviedb.org/3/discover/movie?primary_release_date.gte=2000-01-01" +
+				"&primary_release_date.lte=2024-06-15",
+		},
+		{
+			name:  "year range stricter than released before",
+			query: queryParams{Year: "2000,2010", ReleasedBefore: "2024-06-15"},
+			want: "https://api.themoviedb.org/3/discover/movie?primary_release_date.gte=2000-01-01" +
+				"&primary_release_date.lte=2010-12-31",
+		},
+		{
+			name:  "year upper bound stricter than released before",
+			query: queryParams{Year: "2000,lte", ReleasedBefore: "2024-06-15"},
+			want:  "https://api.themoviedb.org/3/discover/movie?primary_release_date.lte=2000-01-01",
+		},
 		// Vote Average
 		{
 			name: "valid vote average gte",
@@ -524,6 +1403,27 @@ func TestUnitDiscover(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		// Runtime
+		{
+			name:  "valid runtime range",
+			query: queryParams{Runtime: "90,120"},
+			want:  "https://api.themoviedb.org/3/discover/movie?with_runtime.gte=90&with_runtime.lte=120",
+		},
+		{
+			name:  "valid runtime comparison",
+			query: queryParams{Runtime: "150,gte"},
+			want:  "https://api.themoviedb.org/3/discover/movie?with_runtime.gte=150",
+		},
+		{
+			name:    "invalid runtime format",
+			query:   queryParams{Runtime: "90"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid non numeric runtime",
+			query:   queryParams{Runtime: "long,gte"},
+			wantErr: true,
+		},
 		// With Genres
 		{
 			name: "one valid with genre",
@@ -553,6 +1453,111 @@ func TestUnitDiscover(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "many valid with genres matching any",
+			query: queryParams{
+				WithGenres:  "drama,history",
+				GenresMatch: "any",
+			},
+			want: "https://api.themoviedb.org/3/discover/movie?with_genres=18|36",
+		},
+		{
+			name: "many valid with genres matching all",
+			query: queryParams{
+				WithGenres:  "drama,history",
+				GenresMatch: "all",
+			},
+			want: "https://api.themoviedb.org/3/discover/movie?with_genres=18,36",
+		},
+		{
+			name: "invalid genres match",
+			query: queryParams{
+				WithGenres:  "drama,history",
+				GenresMatch: "some",
+			},
+			wantErr: true,
+		},
+		{
+			name: "with genres expression",
+			query: queryParams{
+				WithGenres: "animation|science-fiction,drama",
+			},
+			want: "https://api.themoviedb.org/3/discover/movie?with_genres=16|878,18",
+		},
+		{
+			name: "with genres expression matching any",
+			query: queryParams{
+				WithGenres:  "animation|science-fiction,drama",
+				GenresMatch: "any",
+			},
+			want: "https://api.themoviedb.org/3/discover/movie?with_genres=16|878|18",
+		},
+		{
+			name: "invalid genre in an alternative",
+			query: queryParams{
+				WithGenres: "animation|invalid,drama",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid genre in a group",
+			query: queryParams{
+				WithGenres: "animation|science-fiction,invalid",
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty alternative",
+			query: queryParams{
+				WithGenres: "animation|,drama",
+			},
+			wantErr: true,
+		},
+		{
+			name: "any genre wildcard",
+			query: queryParams{
+				WithGenres: "any",
+				Year:       "2000",
+			},
+			want: "https://api.themoviedb.org/3/discover/movie?primary_release_year=2000",
+		},
+		{
+			name: "star genre wildcard",
+			query: queryParams{
+				WithGenres:  "*",
+				GenresMatch: "any",
+			},
+			want: "https://api.themoviedb.org/3/discover/movie?",
+		},
+		{
+			name: "empty with genres",
+			query: queryParams{
+				WithGenres: ",",
+			},
+			want: "https://api.themoviedb.org/3/discover/movie?",
+		},
+		{
+			name: "duplicate with genres",
+			query: queryParams{
+				WithGenres: "drama,drama,comedy",
+			},
+			want: "https://api.themoviedb.org/3/discover/movie?with_genres=18,35",
+		},
+		{
+			name: "duplicate alternatives in with genres matching any",
+			query: queryParams{
+				WithGenres:  "drama|comedy,drama",
+				GenresMatch: "any",
+			},
+			want: "https://api.themoviedb.org/3/discover/movie?with_genres=18|35",
+		},
+		{
+			name: "empty tokens in with genres",
+			query: queryParams{
+				WithGenres: "drama,,comedy,",
+			},
+			want: "https://api.themoviedb.org/3/discover/movie?with_genres=18,35",
+		},
 		// Without Genres
 		{
 			name: "one valid without genre",
@@ -569,93 +1574,857 @@ func TestUnitDiscover(t *testing.T) {
 			want: "https://api.themoviedb.org/3/discover/movie?without_genres=18,36",
 		},
 		{
-			name: "one invalid without genre",
-			query: queryParams{
-				WithoutGenres: "invalid",
-			},
-			wantErr: true,
+			name: "one invalid without genre",
+			query: queryParams{
+				WithoutGenres: "invalid",
+			},
+			wantErr: true,
+		},
+		{
+			name: "one invalid without genre in many without genres",
+			query: queryParams{
+				WithoutGenres: "drama,invalid",
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate without genres",
+			query: queryParams{
+				WithoutGenres: "horror,,horror",
+			},
+			want: "https://api.themoviedb.org/3/discover/movie?without_genres=27",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			urlBuilder := newURLBuilder()
+			// Act
+			got, err := urlBuilder.discover(tc.query)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+			} else {
+				assertNoError(t, err)
+				assertURL(t, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUnitDiscover_ValidationField(t *testing.T) {
+	testCases := []struct {
+		name      string
+		query     queryParams
+		wantField string
+	}{
+		{name: "bad year", query: queryParams{Year: "20000"}, wantField: "year"},
+		{name: "bad year range", query: queryParams{Year: "2000,1800"}, wantField: "year"},
+		{name: "bad genre", query: queryParams{WithGenres: "drama,invalid"}, wantField: "genres"},
+		{name: "bad excluded genre", query: queryParams{WithoutGenres: "invalid"}, wantField: "without-genres"},
+		{name: "bad genres match", query: queryParams{WithGenres: "drama", GenresMatch: "some"}, wantField: "genres-match"},
+		{name: "bad language", query: queryParams{Language: "english"}, wantField: "language"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			_, err := newURLBuilder().discover(tc.query)
+			// Assert
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected a validation error, but got %v", err)
+			}
+			if validationErr.Field != tc.wantField || validationErr.Message == "" {
+				t.Errorf("expected a message for the field %q, but got %+v", tc.wantField, validationErr)
+			}
+		})
+	}
+}
+
+func TestUnitValidateYear_Clock(t *testing.T) {
+	plus13 := time.FixedZone("UTC+13", 13*60*60)
+	testCases := []struct {
+		name     string
+		now      time.Time
+		location *time.Location
+		year     string
+		wantErr  bool
+	}{
+		{name: "current year at call time", now: time.Date(2040, 6, 1, 0, 0, 0, 0, time.UTC), year: "2040"},
+		{name: "next year rejected", now: time.Date(2040, 6, 1, 0, 0, 0, 0, time.UTC), year: "2041", wantErr: true},
+		{name: "past clock lowers the bound", now: time.Date(1999, 6, 1, 0, 0, 0, 0, time.UTC), year: "2000", wantErr: true},
+		{
+			name:     "new year not reached in UTC",
+			now:      time.Date(2040, 12, 31, 23, 30, 0, 0, time.UTC),
+			location: time.UTC,
+			year:     "2041",
+			wantErr:  true,
+		},
+		{
+			name:     "new year reached in a time zone ahead",
+			now:      time.Date(2040, 12, 31, 23, 30, 0, 0, time.UTC),
+			location: plus13,
+			year:     "2041",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			originalNow, originalLocation := nowFunc, yearLocation
+			t.Cleanup(func() { nowFunc, yearLocation = originalNow, originalLocation })
+			nowFunc = func() time.Time { return tc.now }
+			if tc.location != nil {
+				yearLocation = tc.location
+			}
+			// Act
+			_, err := validateYear(tc.year)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+			} else {
+				assertNoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUnitToday(t *testing.T) {
+	testCases := []struct {
+		name     string
+		location *time.Location
+		want     string
+	}{
+		{name: "UTC", location: time.UTC, want: "2040-12-31"},
+		{name: "time zone ahead", location: time.FixedZone("UTC+13", 13*60*60), want: "2041-01-01"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			originalNow, originalLocation := nowFunc, yearLocation
+			t.Cleanup(func() { nowFunc, yearLocation = originalNow, originalLocation })
+			nowFunc = func() time.Time { return time.Date(2040, 12, 31, 23, 30, 0, 0, time.UTC) }
+			yearLocation = tc.location
+			// Act
+			got := today()
+			// Assert
+			if got != tc.want {
+				t.Errorf("expected today %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUniFetchTMDBResponse(t *testing.T) {
+	testCases := []struct {
+		name           string
+		apiKey         string
+		wantErr        bool
+		wantClientEr   bool
+		wantServerErr  bool
+		wantNetworkErr bool
+		wantRequestErr bool
+		wantJSONErr    bool
+		shapeBody      string
+	}{
+		{name: "get results"},
+		{name: "client error", apiKey: "invalid_api_key", wantErr: true, wantClientEr: true},
+		{name: "server error", wantErr: true, wantServerErr: true},
+		{name: "network error", wantErr: true, wantNetworkErr: true},
+		{name: "request error", wantErr: true, wantRequestErr: true},
+		{name: "JSON format error", wantErr: true, wantJSONErr: true},
+		{name: "wrong shape object", wantErr: true, shapeBody: `{"id": 119, "name": "Not a page"}`},
+		{name: "page out of range", wantErr: true, shapeBody: `{"page": 3, "results": [], "total_pages": 2}`},
+		{name: "movie without id", wantErr: true, shapeBody: `{"page": 1, "results": [{"title": "No ID"}]}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			var (
+				hc      *httpClient
+				tmdbRes tmdbResponse
+				err     error
+			)
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.wantClientEr {
+					if r.Header.Get("Authorization") != "Bearer valid_api_key" {
+						w.WriteHeader(401)
+						return
+					}
+				}
+				if tc.wantServerErr {
+					w.WriteHeader(501)
+					w.Write([]byte(`{"error": "Invalid service"}`))
+					return
+				}
+				if tc.wantJSONErr {
+					w.Write([]byte("Invalid JSON format"))
+					return
+				}
+				if tc.shapeBody != "" {
+					w.Write([]byte(tc.shapeBody))
+					return
+				}
+				byt, _ := json.Marshal(fakeResPage1)
+				w.Write(byt)
+			}))
+			t.Cleanup(ts.Close)
+			hc = newHTTPClient(tc.apiKey)
+			// Act
+			if tc.wantRequestErr {
+				tmdbRes, err = fetchTMDBResponse(context.Background(), hc, ":invalid_url")
+			} else if tc.wantNetworkErr {
+				tmdbRes, err = fetchTMDBResponse(context.Background(), hc, "http://0.0.0.0:9999") // Non-routable IP
+			} else {
+				tmdbRes, err = fetchTMDBResponse(context.Background(), hc, ts.URL)
+			}
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				if tc.shapeBody != "" && !errors.Is(err, errUnexpectedShape) {
+					t.Errorf("expected an unexpected response shape error, but got %v", err)
+				}
+			} else {
+				assertNoError(t, err)
+				assertResponse(t, fakeResPage1, tmdbRes)
+			}
+		})
+	}
+}
+
+func TestUnitTestUniTFetchTMDBResponse_Retry(t *testing.T) {
+	// Arrange
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requireAPIKey(t, w, r)
+		attempts++
+		if attempts < 2 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(429)
+			return
+		}
+		byt, _ := json.Marshal(fakeResPage1)
+		w.Write(byt)
+	}))
+	t.Cleanup(ts.Close)
+	hc := newHTTPClient("valid_api_key")
+	hc.NewBackOff = noDelayBackOff
+	// Act
+	tmdbRes, err := fetchTMDBResponse(context.Background(), hc, ts.URL)
+	// Assert
+	assertNoError(t, err)
+	assertResponse(t, fakeResPage1, tmdbRes)
+}
+
+func TestUnitFetchTMDBResponse_RetryScenarios(t *testing.T) {
+	testCases := []struct {
+		name         string
+		statuses     []int
+		retryBudget  int
+		wantAttempts int
+		wantErr      bool
+		wantErrIs    error
+	}{
+		{name: "rate limited twice", statuses: []int{429, 429, 200}, retryBudget: 10, wantAttempts: 3},
+		{name: "retry budget exhausted", statuses: []int{429, 429, 429, 200}, retryBudget: 2, wantAttempts: 3,
+			wantErr: true, wantErrIs: errRetryBudget},
+		{name: "server error not retried", statuses: []int{500, 200}, retryBudget: 10, wantAttempts: 1,
+			wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			attempts := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tc.statuses[attempts]
+				attempts++
+				if status != http.StatusOK {
+					w.Header().Set("Retry-After", "60")
+					w.WriteHeader(status)
+					return
+				}
+				byt, _ := json.Marshal(fakeResPage1)
+				w.Write(byt)
+			}))
+			t.Cleanup(ts.Close)
+			hc := newHTTPClient("valid_api_key")
+			hc.NewBackOff = noDelayBackOff
+			ctx := withRetryBudget(context.Background(), tc.retryBudget)
+			// Act
+			start := time.Now()
+			_, err := fetchTMDBResponse(ctx, hc, ts.URL)
+			// Assert
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("expected retries without delay, but took %s", elapsed)
+			}
+			if attempts != tc.wantAttempts {
+				t.Errorf("expected %d attempts, but got %d", tc.wantAttempts, attempts)
+			}
+			if !tc.wantErr {
+				assertNoError(t, err)
+				return
+			}
+			assertNotNil(t, err)
+			if tc.wantErrIs != nil && !errors.Is(err, tc.wantErrIs) {
+				t.Errorf("expected %v, but got %v", tc.wantErrIs, err)
+			}
+		})
+	}
+}
+
+func TestUnitRetryAfterBackOff(t *testing.T) {
+	// Arrange
+	retryAfter := time.Duration(0)
+	b := &retryAfterBackOff{BackOff: backoff.NewConstantBackOff(time.Second), RetryAfter: &retryAfter}
+	// Act
+	first, second := b.NextBackOff(), b.NextBackOff()
+	// Assert
+	if first != 0 {
+		t.Errorf("expected the Retry-After delay 0s, but got %s", first)
+	}
+	if second != time.Second {
+		t.Errorf("expected the fallback delay %s once Retry-After is consumed, but got %s", time.Second, second)
+	}
+}
+
+func TestUnitURLBuilder_SetVersion(t *testing.T) {
+	testCases := []struct {
+		name          string
+		version       int
+		wantWatchlist string
+		wantErr       bool
+	}{
+		{name: "version 3", version: 3, wantWatchlist: "https://api.themoviedb.org/3/account/42/watchlist/movies?"},
+		{name: "version 4", version: 4, wantWatchlist: "https://api.themoviedb.org/4/account/4bc889a8/movie/watchlist?"},
+		{name: "unknown version", version: 2, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ub := newURLBuilder()
+			accountID := map[int]string{3: "42", 4: "4bc889a8"}[tc.version]
+			// Act
+			err := ub.setVersion(tc.version)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				if ub.BaseURL != "https://api.themoviedb.org/3" {
+					t.Errorf("expected the base URL left unchanged, but got %q", ub.BaseURL)
+				}
+				return
+			}
+			assertNoError(t, err)
+			if got := ub.watchlistMovies(accountID); got != tc.wantWatchlist {
+				t.Errorf("expected %q, but got %q", tc.wantWatchlist, got)
+			}
+		})
+	}
+}
+
+func TestUnitAccountObjectID(t *testing.T) {
+	testCases := []struct {
+		name    string
+		token   string
+		want    string
+		wantErr bool
+	}{
+		{name: "v4 access token", token: fakeV4Token("4bc889a8"), want: "4bc889a8"},
+		{name: "v3 api key", token: "valid_api_key", wantErr: true},
+		{name: "malformed payload", token: "header.not base64!.signature", wantErr: true},
+		{name: "missing subject", token: fakeV4Token(""), wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got, err := accountObjectID(tc.token)
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				return
+			}
+			assertNoError(t, err)
+			if got != tc.want {
+				t.Errorf("expected %q, but got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUnitExplainUnauthorized(t *testing.T) {
+	unauthorized := &statusError{StatusCode: http.StatusUnauthorized, Status: "401 Unauthorized"}
+	testCases := []struct {
+		name        string
+		token       string
+		wantExpired bool
+	}{
+		{name: "expired token", token: fakeExpiringToken(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)), wantExpired: true},
+		{name: "valid token", token: fakeExpiringToken(time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC))},
+		{name: "token without expiry", token: fakeV4Token("4bc889a8")},
+		{name: "v3 api key", token: "valid_api_key"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			previous := nowFunc
+			nowFunc = func() time.Time { return time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC) }
+			t.Cleanup(func() { nowFunc = previous })
+			// Act
+			err := explainUnauthorized(tc.token, unauthorized)
+			// Assert
+			var expired *expiredTokenError
+			if errors.As(err, &expired) != tc.wantExpired {
+				t.Fatalf("expected an expired token error %v, but got %v", tc.wantExpired, err)
+			}
+			if !tc.wantExpired {
+				if err != unauthorized {
+					t.Errorf("expected the status error unchanged, but got %v", err)
+				}
+				return
+			}
+			want := "your TMDB access token appears to have expired on 2024-01-02; please regenerate it."
+			if err.Error() != want {
+				t.Errorf("expected %q, but got %q", want, err.Error())
+			}
+			if !errors.Is(err, unauthorized) {
+				t.Errorf("expected the status error to be wrapped, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestUnitUnmarshalMovies(t *testing.T) {
+	testCases := []struct {
+		name    string
+		input   string
+		wantIDs []int
+		wantErr bool
+	}{
+		{name: "plain array", input: `[{"id": 1, "title": "A"}, {"id": 2, "title": "B"}]`, wantIDs: []int{1, 2}},
+		{
+			name:    "TMDB wrapper",
+			input:   `{"page": 1, "results": [{"id": 3}], "total_pages": 1, "total_results": 1}`,
+			wantIDs: []int{3},
+		},
+		{name: "surrounding whitespace", input: "\n  [{\"id\": 4}]\n", wantIDs: []int{4}},
+		{name: "empty results", input: `{"results": []}`, wantIDs: []int{}},
+		{name: "object without results", input: `{"page": 1}`, wantErr: true},
+		{name: "malformed array", input: `[{"id": 1},`, wantErr: true},
+		{name: "malformed object", input: `{"results": [`, wantErr: true},
+		{name: "not json", input: `id,title`, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			got, err := UnmarshalMovies([]byte(tc.input))
+			// Assert
+			if tc.wantErr {
+				assertNotNil(t, err)
+				return
+			}
+			assertNoError(t, err)
+			assertMovieIDs(t, tc.wantIDs, got)
+		})
+	}
+}
+
+func TestUnitDecodeResultsStream(t *testing.T) {
+	// Arrange
+	const total = 5000
+	pr, pw := io.Pipe()
+	firstEmitted := make(chan struct{})
+	go func() {
+		fmt.Fprint(pw, `{"page": 1, "results": [`)
+		for i := 1; i <= total; i++ {
+			if i > 1 {
+				fmt.Fprint(pw, ",")
+			}
+			fmt.Fprintf(pw, `{"id": %d, "title": "Movie %d"}`, i, i)
+			if i == 1 {
+				// Hold the rest of the body until the first movie is emitted
+				select {
+				case <-firstEmitted:
+				case <-time.After(time.Second):
+					pw.CloseWithError(errors.New("first movie not emitted before the end of the body"))
+					return
+				}
+			}
+		}
+		fmt.Fprintf(pw, `], "total_pages": 1, "total_results": %d, "unknown": {"a": [1, 2]}}`, total)
+		pw.Close()
+	}()
+	count := 0
+	// Act
+	page, err := decodeResultsStream(pr, func(m movie) {
+		count++
+		if count == 1 {
+			close(firstEmitted)
+		}
+		if m.ID != count {
+			t.Errorf("expected movie %d, but got %d", count, m.ID)
+		}
+	})
+	// Assert
+	assertNoError(t, err)
+	if count != total {
+		t.Errorf("expected %d movies, but got %d", total, count)
+	}
+	if page.Page != 1 || page.TotalPages != 1 || page.TotalResults != total || page.Results != nil {
+		t.Errorf("expected the page fields without results, but got %+v", page)
+	}
+}
+
+func TestUnitDecodeResultsStream_Errors(t *testing.T) {
+	testCases := []struct {
+		name       string
+		body       string
+		wantCount  int
+		wantErr    error
+		wantAPIErr bool
+	}{
+		{name: "not an object", body: `[{"id": 1}]`, wantErr: errUnexpectedShape},
+		{name: "results not an array", body: `{"results": {"id": 1}}`, wantErr: errUnexpectedShape},
+		{name: "null results", body: `{"page": 1, "results": null, "total_pages": 0}`},
+		{name: "absent results", body: `{"page": 1, "total_pages": 0}`},
+		{name: "truncated body", body: `{"results": [{"id": 1}, {"id": 2`, wantCount: 1, wantErr: io.ErrUnexpectedEOF},
+		{
+			name:       "failure envelope",
+			body:       `{"success": false, "status_code": 7, "status_message": "Invalid API key"}`,
+			wantAPIErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			count := 0
+			// Act
+			_, err := decodeResultsStream(strings.NewReader(tc.body), func(movie) { count++ })
+			// Assert
+			if count != tc.wantCount {
+				t.Errorf("expected %d movies, but got %d", tc.wantCount, count)
+			}
+			var apiErr *apiError
+			switch {
+			case tc.wantAPIErr:
+				if !errors.As(err, &apiErr) {
+					t.Errorf("expected a TMDB API error, but got %v", err)
+				}
+			case tc.wantErr != nil:
+				if !errors.Is(err, tc.wantErr) {
+					t.Errorf("expected %v, but got %v", tc.wantErr, err)
+				}
+			default:
+				assertNoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUnitFetchKeptMovies(t *testing.T) {
+	testCases := []struct {
+		name    string
+		keep    func(movie) bool
+		wantIDs []int
+		wantErr error
+	}{
+		{name: "all kept", wantErr: errUnexpectedShape},
+		{name: "rejected dropped", keep: func(m movie) bool { return m.ID == 1 || m.ID == 3 }, wantIDs: []int{1, 3}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// The movie without an ID only fails the page when kept
+				w.Write([]byte(`{"page": 1, "results": [{"id": 1}, {"id": 2}, {"title": "No ID"}, {"id": 3}],` +
+					` "total_pages": 1, "total_results": 4}`))
+			}))
+			t.Cleanup(ts.Close)
+			// Act
+			got, err := fetchKeptMovies(context.Background(), newHTTPClient("valid_api_key"), ts.URL, tc.keep)
+			// Assert
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Errorf("expected %v, but got %v", tc.wantErr, err)
+				}
+				return
+			}
+			assertNoError(t, err)
+			assertMovieIDs(t, tc.wantIDs, got.Results)
+			if got.TotalResults != 4 {
+				t.Errorf("expected TMDB's 4 total results, but got %d", got.TotalResults)
+			}
+		})
+	}
+}
+
+func TestUnitFetchTMDBResponse_FailureEnvelope(t *testing.T) {
+	testCases := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{
+			name:    "failure envelope",
+			body:    `{"success": false, "status_code": 34, "status_message": "The resource you requested could not be found."}`,
+			wantErr: "could not be found",
+		},
+		{
+			name: "success flag with results",
+			body: `{"success": true, "page": 1, "results": [{"id": 1, "title": "Epic Journey Begins"}], "total_pages": 1}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tc.body)) // Always 200 OK
+			}))
+			t.Cleanup(ts.Close)
+			// Act
+			got, err := fetchTMDBResponse(context.Background(), newHTTPClient("valid_api_key"), ts.URL)
+			// Assert
+			if tc.wantErr != "" {
+				var apiErr *apiError
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("expected a TMDB API error, but got %v", err)
+				}
+				assertContains(t, err.Error(), []string{tc.wantErr, "34"})
+				return
+			}
+			assertNoError(t, err)
+			assertMovieIDs(t, []int{1}, got.Results)
+		})
+	}
+}
+
+func TestUnitDo_Methods(t *testing.T) {
+	type echo struct {
+		Method string `json:"method"`
+		Body   string `json:"body"`
+	}
+	testCases := []struct {
+		name    string
+		method  string
+		payload any
+		want    echo
+	}{
+		{name: "get without body", method: http.MethodGet, want: echo{Method: "GET"}},
+		{
+			name:    "post with json body",
+			method:  http.MethodPost,
+			payload: watchlistRequest{MediaType: "movie", MediaID: 603, Watchlist: true},
+			want:    echo{Method: "POST", Body: `{"media_type":"movie","media_id":603,"watchlist":true}`},
+		},
+		{
+			name:    "delete with json body",
+			method:  http.MethodDelete,
+			payload: map[string]int{"value": 8},
+			want:    echo{Method: "DELETE", Body: `{"value":8}`},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requireAPIKey(t, w, r)
+				body, _ := io.ReadAll(r.Body)
+				byt, _ := json.Marshal(echo{Method: r.Method, Body: string(body)})
+				w.Write(byt)
+			}))
+			t.Cleanup(ts.Close)
+			// Act
+			var got echo
+			err := newHTTPClient("valid_api_key").do(context.Background(), tc.method, ts.URL, tc.payload, &got)
+			// Assert
+			assertNoError(t, err)
+			if got != tc.want {
+				t.Errorf("want %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUnitDo_RetriesWithBody(t *testing.T) {
+	// Arrange
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"success": true, "status_code": 1, "status_message": "Success."}`))
+	}))
+	t.Cleanup(ts.Close)
+	hc := newHTTPClient("valid_api_key")
+	hc.NewBackOff = noDelayBackOff
+	// Act
+	var status statusResponse
+	err := hc.do(context.Background(), http.MethodPost, ts.URL, map[string]int{"value": 8}, &status)
+	// Assert
+	assertNoError(t, err)
+	if len(bodies) != 2 || bodies[0] != bodies[1] || bodies[1] != `{"value":8}` {
+		t.Errorf("want the same body on each attempt, got %q", bodies)
+	}
+}
+
+func TestUnitFetchTMDBResponse_Locale(t *testing.T) {
+	testCases := []struct {
+		name   string
+		locale string
+	}{
+		{name: "no locale"},
+		{name: "locale", locale: "fr-FR"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			var got string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get("Accept-Language")
+				byt, _ := json.Marshal(fakeResPage1)
+				w.Write(byt)
+			}))
+			t.Cleanup(ts.Close)
+			hc := newHTTPClient("valid_api_key")
+			hc.Locale = tc.locale
+			// Act
+			_, err := fetchTMDBResponse(context.Background(), hc, ts.URL)
+			// Assert
+			assertNoError(t, err)
+			if got != tc.locale {
+				t.Errorf("expected Accept-Language %q, but got %q", tc.locale, got)
+			}
+		})
+	}
+}
+
+func TestUnitFetchTMDBResponse_NoRetry(t *testing.T) {
+	// Arrange
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(429)
+	}))
+	t.Cleanup(ts.Close)
+	hc := newHTTPClient("valid_api_key")
+	hc.NoRetry = true
+	start := time.Now()
+	// Act
+	_, err := fetchTMDBResponse(context.Background(), hc, ts.URL)
+	// Assert
+	assertNotNil(t, err)
+	if attempts != 1 {
+		t.Errorf("expected a single attempt, but got %d", attempts)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected an immediate error, but waited %s", elapsed)
+	}
+}
+
+func TestUnitFetchTMDBResponse_Timeouts(t *testing.T) {
+	testCases := []struct {
+		name           string
+		unroutable     bool
+		delay          time.Duration
+		connectTimeout time.Duration
+		totalTimeout   time.Duration
+		maxElapsed     time.Duration
+		wantErr        bool
+	}{
+		{
+			name:           "connect timeout on an unroutable address",
+			unroutable:     true,
+			connectTimeout: 100 * time.Millisecond,
+			maxElapsed:     2 * time.Second,
+			wantErr:        true,
+		},
+		{
+			name:           "total timeout on a slow response",
+			delay:          5 * time.Second,
+			connectTimeout: 100 * time.Millisecond,
+			totalTimeout:   100 * time.Millisecond,
+			maxElapsed:     2 * time.Second,
+			wantErr:        true,
 		},
 		{
-			name: "one invalid without genre in many without genres",
-			query: queryParams{
-				WithoutGenres: "drama,invalid",
-			},
-			wantErr: true,
+			name:           "slow response within the total timeout",
+			delay:          300 * time.Millisecond,
+			connectTimeout: 100 * time.Millisecond,
+			totalTimeout:   5 * time.Second,
+			maxElapsed:     5 * time.Second,
 		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// Arrange
-			urlBuilder := newURLBuilder()
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(tc.delay):
+				case <-r.Context().Done():
+					return
+				}
+				byt, _ := json.Marshal(fakeResPage1)
+				w.Write(byt)
+			}))
+			t.Cleanup(ts.Close)
+			url := ts.URL
+			if tc.unroutable {
+				url = "http://10.255.255.1/discover/movie"
+			}
+			hc := newHTTPClient("valid_api_key")
+			hc.NoRetry = true
+			hc.NetworkRetries = 0
+			hc.Client.Transport = newTransport(tc.connectTimeout)
+			hc.TotalTimeout = tc.totalTimeout
 			// Act
-			got, err := urlBuilder.discover(tc.query)
+			start := time.Now()
+			_, err := fetchTMDBResponse(context.Background(), hc, url)
 			// Assert
+			if elapsed := time.Since(start); elapsed > tc.maxElapsed {
+				t.Errorf("expected to finish within %s, but took %s", tc.maxElapsed, elapsed)
+			}
 			if tc.wantErr {
 				assertNotNil(t, err)
 			} else {
 				assertNoError(t, err)
-				assertURL(t, tc.want, got)
 			}
 		})
 	}
 }
 
-func TestUniFetchTMDBResponse(t *testing.T) {
+func TestUnitFetchTMDBResponse_NetworkRetry(t *testing.T) {
 	testCases := []struct {
 		name           string
-		apiKey         string
+		networkRetries int
+		wantAttempts   int
 		wantErr        bool
-		wantClientEr   bool
-		wantServerErr  bool
-		wantNetworkErr bool
-		wantRequestErr bool
-		wantJSONErr    bool
 	}{
-		{name: "get results"},
-		{name: "client error", apiKey: "invalid_api_key", wantErr: true, wantClientEr: true},
-		{name: "server error", wantErr: true, wantServerErr: true},
-		{name: "network error", wantErr: true, wantNetworkErr: true},
-		{name: "request error", wantErr: true, wantRequestErr: true},
-		{name: "JSON format error", wantErr: true, wantJSONErr: true},
+		{name: "retry transient failure", networkRetries: 1, wantAttempts: 2},
+		{name: "no network retries", networkRetries: 0, wantAttempts: 1, wantErr: true},
 	}
-
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// Arrange
-			var (
-				hc      *httpClient
-				tmdbRes tmdbResponse
-				err     error
-			)
 			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				if tc.wantClientEr {
-					if r.Header.Get("Authorization") != "Bearer valid_api_key" {
-						w.WriteHeader(401)
-						return
-					}
-				}
-				if tc.wantServerErr {
-					w.WriteHeader(501)
-					w.Write([]byte(`{"error": "Invalid service"}`))
-					return
-				}
-				if tc.wantJSONErr {
-					w.Write([]byte("Invalid JSON format"))
-					return
-				}
 				byt, _ := json.Marshal(fakeResPage1)
 				w.Write(byt)
 			}))
 			t.Cleanup(ts.Close)
-			hc = newHTTPClient(tc.apiKey)
+			attempts := 0
+			hc := newHTTPClient("valid_api_key")
+			hc.NetworkRetries = tc.networkRetries
+			hc.NewBackOff = noDelayBackOff
+			hc.Client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				attempts++
+				if attempts == 1 {
+					return nil, fmt.Errorf("connection reset by peer")
+				}
+				return http.DefaultTransport.RoundTrip(r)
+			})
 			// Act
-			if tc.wantRequestErr {
-				tmdbRes, err = fetchTMDBResponse(hc, ":invalid_url")
-			} else if tc.wantNetworkErr {
-				tmdbRes, err = fetchTMDBResponse(hc, "http://0.0.0.0:9999") // Non-routable IP
-			} else {
-				tmdbRes, err = fetchTMDBResponse(hc, ts.URL)
-			}
+			tmdbRes, err := fetchTMDBResponse(context.Background(), hc, ts.URL)
 			// Assert
 			if tc.wantErr {
 				assertNotNil(t, err)
@@ -663,33 +2432,13 @@ func TestUniFetchTMDBResponse(t *testing.T) {
 				assertNoError(t, err)
 				assertResponse(t, fakeResPage1, tmdbRes)
 			}
+			if attempts != tc.wantAttempts {
+				t.Errorf("expected %d attempts, but got %d", tc.wantAttempts, attempts)
+			}
 		})
 	}
 }
 
-func TestUnitTestUniTFetchTMDBResponse_Retry(t *testing.T) {
-	// Arrange
-	attempts := 0
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		requireAPIKey(t, w, r)
-		attempts++
-		if attempts < 2 {
-			w.Header().Set("Retry-After", "1")
-			w.WriteHeader(429)
-			return
-		}
-		byt, _ := json.Marshal(fakeResPage1)
-		w.Write(byt)
-	}))
-	t.Cleanup(ts.Close)
-	hc := newHTTPClient("valid_api_key")
-	// Act
-	tmdbRes, err := fetchTMDBResponse(hc, ts.URL)
-	// Assert
-	assertNoError(t, err)
-	assertResponse(t, fakeResPage1, tmdbRes)
-}
-
 func TestUnitAsyncFetchMovies(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -754,7 +2503,7 @@ func TestUnitAsyncFetchMovies(t *testing.T) {
 			t.Cleanup(func() { ts.Close() })
 			hc := newHTTPClient("valid_api_key")
 			// Act
-			got, err := asyncFetchMovies(hc, ts.URL+"?", tc.maxItems)
+			got, _, err := asyncFetchMovies(context.Background(), hc, ts.URL+"?", tc.maxItems, nil)
 			// Assert
 			if tc.wantErr {
 				assertNotNil(t, err)
@@ -766,6 +2515,358 @@ func TestUnitAsyncFetchMovies(t *testing.T) {
 	}
 }
 
+func TestUnitAsyncFetchMovies_OnPage(t *testing.T) {
+	testCases := []struct {
+		name      string
+		maxItems  int
+		keep      func(movie) bool
+		batchSize int
+		wantPages []int
+		wantTotal int
+	}{
+		{name: "single page", maxItems: 10, wantPages: []int{1}, wantTotal: 2},
+		{name: "parallel pages", maxItems: 40, wantPages: []int{1, 2}, wantTotal: 2},
+		{name: "pages capped by the results", maxItems: 100, batchSize: 2, wantPages: []int{1, 2}, wantTotal: 2},
+		{
+			name:      "sequential top-up",
+			maxItems:  10,
+			keep:      func(m movie) bool { return m.ID > 15 },
+			wantPages: []int{1, 2},
+			wantTotal: 2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := newFakeTMDBServer(t)
+			hc := newHTTPClient("valid_api_key")
+			hc.BatchSize = tc.batchSize
+			var inCallback atomic.Bool
+			var pages []int
+			hc.OnPage = func(page, total int) {
+				if !inCallback.CompareAndSwap(false, true) {
+					t.Error("expected calls not to overlap")
+				}
+				defer inCallback.Store(false)
+				if total != tc.wantTotal {
+					t.Errorf("expected %d total pages for page %d, but got %d", tc.wantTotal, page, total)
+				}
+				pages = append(pages, page) // Unsynchronized, for the race detector to catch overlaps
+			}
+			// Act
+			_, _, err := asyncFetchMovies(context.Background(), hc, ts.URL+"?", tc.maxItems, tc.keep)
+			// Assert
+			assertNoError(t, err)
+			slices.Sort(pages)
+			if !slices.Equal(pages, tc.wantPages) {
+				t.Errorf("expected one call per page %v, but got %v", tc.wantPages, pages)
+			}
+		})
+	}
+}
+
+func TestUnitAsyncFetchMovies_OnDuplicates(t *testing.T) {
+	testCases := []struct {
+		name     string
+		maxItems int
+		keep     func(movie) bool
+		wantIDs  []int
+	}{
+		{name: "parallel pages", maxItems: 40, wantIDs: []int{19, 20}},
+		{name: "sequential top-up", maxItems: 10, keep: func(m movie) bool { return m.ID > 15 }, wantIDs: []int{19, 20}},
+		{name: "single page", maxItems: 10, wantIDs: nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				res := fakeResPage1
+				if r.URL.Query().Get("page") == "2" {
+					// The last two movies of page 1 shifted to page 2
+					res = tmdbResponse{Page: 2, Results: append(fakeMovieList[18:20:20], fakeResPage2.Results...),
+						TotalPages: 2, TotalResults: fakeResPage1.TotalResults}
+				}
+				byt, _ := json.Marshal(res)
+				w.Write(byt)
+			}))
+			t.Cleanup(ts.Close)
+			hc := newHTTPClient("valid_api_key")
+			var got []int
+			calls := 0
+			hc.OnDuplicates = func(ids []int) {
+				calls++
+				got = ids
+			}
+			// Act
+			_, _, err := asyncFetchMovies(context.Background(), hc, ts.URL+"?", tc.maxItems, tc.keep)
+			// Assert
+			assertNoError(t, err)
+			if calls != 1 || !slices.Equal(got, tc.wantIDs) {
+				t.Errorf("expected a single call with the IDs %v, but got %d calls with %v", tc.wantIDs, calls, got)
+			}
+		})
+	}
+}
+
+func TestUnitAsyncFetchMovies_Progressive(t *testing.T) {
+	testCases := []struct {
+		name      string
+		maxItems  int
+		keep      func(movie) bool
+		wantIDs   []int
+		wantPages []string
+	}{
+		{
+			name:      "filter discards most of page 1",
+			maxItems:  10,
+			keep:      func(m movie) bool { return m.ID > 15 },
+			wantIDs:   []int{16, 17, 18, 19, 20, 21, 22, 23, 24, 25},
+			wantPages: []string{"1", "2"},
+		},
+		{
+			name:      "first page is enough",
+			maxItems:  5,
+			keep:      func(m movie) bool { return m.ID > 10 },
+			wantIDs:   []int{11, 12, 13, 14, 15},
+			wantPages: []string{"1"},
+		},
+		{
+			name:      "pages run out",
+			maxItems:  10,
+			keep:      func(m movie) bool { return m.ID%10 == 0 },
+			wantIDs:   []int{10, 20, 30, 40},
+			wantPages: []string{"1", "2"},
+		},
+		{
+			name:      "more items than results",
+			maxItems:  100,
+			wantIDs:   movieIDs(fakeMovieList),
+			wantPages: []string{"1", "2"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			ts := newFakeTMDBServer(t)
+			hc := newHTTPClient("valid_api_key")
+			// Act
+			got, _, err := asyncFetchMovies(context.Background(), hc, ts.URL+"?", tc.maxItems, tc.keep)
+			// Assert
+			assertNoError(t, err)
+			assertMovieIDs(t, tc.wantIDs, got)
+			if pages := ts.requestedPages(); !reflect.DeepEqual(tc.wantPages, pages) {
+				t.Errorf("expected requested pages %v, but got %v", tc.wantPages, pages)
+			}
+		})
+	}
+}
+
+func TestUnitAsyncFetchMovies_MaxPages(t *testing.T) {
+	// Arrange
+	ts := newFakeTMDBServer(t)
+	hc := newHTTPClient("valid_api_key")
+	hc.MaxPages = 1
+	// Act
+	got, _, err := asyncFetchMovies(context.Background(), hc, ts.URL+"/discover/movie?", 40,
+		func(m movie) bool { return m.ID%2 == 0 })
+	// Assert
+	assertNoError(t, err)
+	if pages := ts.requestedPages(); !reflect.DeepEqual(pages, []string{"1"}) {
+		t.Errorf("expected only the first page, but requested %v", pages)
+	}
+	if len(got) != 10 {
+		t.Errorf("expected the 10 matches of the first page, but got %d", len(got))
+	}
+}
+
+func TestUnitAsyncFetchMovies_RetryBudget(t *testing.T) {
+	testCases := []struct {
+		name         string
+		budget       int
+		wantRequests int
+	}{
+		{name: "no retry", budget: 0, wantRequests: 1 + 4},
+		{name: "budget shared by the pages", budget: 3, wantRequests: 1 + 4 + 3},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			var requests atomic.Int64
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+				if page > 1 { // Every page but the first is rate limited, forever
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				results := make(movies, resultsPerPage)
+				for i := range results {
+					results[i] = movie{ID: i + 1}
+				}
+				byt, _ := json.Marshal(tmdbResponse{Page: page, Results: results, TotalPages: 5})
+				w.Write(byt)
+			}))
+			t.Cleanup(ts.Close)
+			hc := newHTTPClient("valid_api_key")
+			hc.RetryBudget = tc.budget
+			// Act
+			_, _, err := asyncFetchMovies(context.Background(), hc, ts.URL+"?", 100, nil)
+			// Assert
+			if !errors.Is(err, errRetryBudget) {
+				t.Fatalf("expected a retry budget error, but got %v", err)
+			}
+			if got := int(requests.Load()); got != tc.wantRequests {
+				t.Errorf("expected %d requests, but got %d", tc.wantRequests, got)
+			}
+		})
+	}
+}
+
+func TestUnitAsyncFetchMovies_FetchTimeout(t *testing.T) {
+	// Arrange
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	t.Cleanup(ts.Close)
+	hc := newHTTPClient("valid_api_key")
+	hc.FetchTimeout = 50 * time.Millisecond
+	start := time.Now()
+	// Act
+	_, _, err := asyncFetchMovies(context.Background(), hc, ts.URL+"?", 100, nil)
+	// Assert
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline error, but got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected the fetch to give up after its timeout, but it took %s", elapsed)
+	}
+}
+
+func TestUnitAsyncFetchMovies_BatchSize(t *testing.T) {
+	testCases := []struct {
+		name         string
+		batchSize    int
+		batchDelay   time.Duration
+		wantParallel int
+		wantMinTime  time.Duration
+	}{
+		{name: "all at once", wantParallel: 6},
+		{name: "waves of two", batchSize: 2, wantParallel: 2},
+		{
+			name:         "waves of four with delay",
+			batchSize:    4,
+			batchDelay:   50 * time.Millisecond,
+			wantParallel: 4,
+			wantMinTime:  50 * time.Millisecond,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			var mu sync.Mutex
+			inFlight, maxInFlight := 0, 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+				if page > 1 {
+					mu.Lock()
+					inFlight++
+					maxInFlight = max(maxInFlight, inFlight)
+					mu.Unlock()
+					time.Sleep(30 * time.Millisecond)
+					mu.Lock()
+					inFlight--
+					mu.Unlock()
+				}
+				results := make(movies, resultsPerPage)
+				for i := range results {
+					results[i] = movie{ID: page*100 + i}
+				}
+				byt, _ := json.Marshal(tmdbResponse{Page: page, Results: results, TotalPages: 7})
+				w.Write(byt)
+			}))
+			t.Cleanup(ts.Close)
+			hc := newHTTPClient("valid_api_key")
+			hc.BatchSize = tc.batchSize
+			hc.BatchDelay = tc.batchDelay
+			start := time.Now()
+			// Act
+			got, _, err := asyncFetchMovies(context.Background(), hc, ts.URL+"/discover/movie?", 7*resultsPerPage, nil)
+			// Assert
+			assertNoError(t, err)
+			if len(got) != 7*resultsPerPage {
+				t.Errorf("expected %d movies, but got %d", 7*resultsPerPage, len(got))
+			}
+			if maxInFlight != tc.wantParallel {
+				t.Errorf("expected waves of %d requests, but got up to %d in flight", tc.wantParallel, maxInFlight)
+			}
+			if elapsed := time.Since(start); elapsed < tc.wantMinTime {
+				t.Errorf("expected a pause of at least %s between waves, but took %s", tc.wantMinTime, elapsed)
+			}
+		})
+	}
+}
+
+func TestUnitAsyncFetchMovies_RequestIDLogs(t *testing.T) {
+	// Arrange
+	ts := newFakeTMDBServer(t)
+	var buf bytes.Buffer
+	hc := newHTTPClient("valid_api_key")
+	hc.Logger = log.New(&buf, "", 0)
+	// Act
+	_, _, err := asyncFetchMovies(context.Background(), hc, ts.URL+"?", 40, nil)
+	// Assert
+	assertNoError(t, err)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	requestIDs := make(map[string]bool)
+	pages := make(map[string]bool)
+	re := regexp.MustCompile(`^\[req=([0-9a-f]+) page=(\d+)\] `)
+	for _, line := range lines {
+		match := re.FindStringSubmatch(line)
+		if match == nil {
+			t.Fatalf("expected log line to carry request ID and page, got %q", line)
+		}
+		requestIDs[match[1]] = true
+		pages[match[2]] = true
+	}
+	if len(requestIDs) != 1 {
+		t.Errorf("expected one shared request ID, but got %v", requestIDs)
+	}
+	if !pages["1"] || !pages["2"] {
+		t.Errorf("expected log lines for pages 1 and 2, but got %v", pages)
+	}
+}
+
+func TestUnitAsyncFetchMovies_Interrupted(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requireAPIKey(t, w, r)
+		if r.URL.Query().Get("page") == "1" {
+			byt, _ := json.Marshal(fakeResPage1)
+			w.Write(byt)
+			return
+		}
+		cancel() // Interrupt while the remaining pages are in flight
+		<-r.Context().Done()
+	}))
+	t.Cleanup(ts.Close)
+	hc := newHTTPClient("valid_api_key")
+	// Act
+	got, _, err := asyncFetchMovies(ctx, hc, ts.URL+"?", 40, nil)
+	// Assert
+	if !errors.Is(err, errInterrupted) {
+		t.Errorf("expected error %v, but got %v", errInterrupted, err)
+	}
+	if !reflect.DeepEqual(fakeMovieList[:20], got) {
+		t.Errorf("expected partial movies %+v, but got %+v", fakeMovieList[:20], got)
+	}
+}
+
 func BenchmarkAsyncFetchMovies(b *testing.B) {
 	testCases := []struct {
 		maxItems int
@@ -783,10 +2884,38 @@ func BenchmarkAsyncFetchMovies(b *testing.B) {
 	defer ts.Close()
 	for i := 0; i < b.N; i++ {
 		for _, tc := range testCases {
-			_, err := asyncFetchMovies(hc, ts.URL+"?", tc.maxItems)
+			_, _, err := asyncFetchMovies(context.Background(), hc, ts.URL+"?", tc.maxItems, nil)
 			if err != nil {
 				b.Fatalf("failed to fetch movies: %v", err)
 			}
 		}
 	}
 }
+
+func BenchmarkAsyncFetchMovies_SinglePage(b *testing.B) {
+	hc := newHTTPClient("valid_api_key")
+	// A short last page, so neither path returns before its page handling
+	page := tmdbResponse{Page: 1, Results: fakeMovieList[:resultsPerPage-1], TotalPages: 1, TotalResults: resultsPerPage - 1}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		byt, _ := json.Marshal(page)
+		w.Write(byt)
+	}))
+	defer ts.Close()
+	for _, fastPath := range []bool{true, false} {
+		name := "general path"
+		if fastPath {
+			name = "fast path"
+		}
+		b.Run(name, func(b *testing.B) {
+			previous := singlePageFastPath
+			singlePageFastPath = fastPath
+			b.Cleanup(func() { singlePageFastPath = previous })
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := asyncFetchMovies(context.Background(), hc, ts.URL+"?", resultsPerPage, nil); err != nil {
+					b.Fatalf("failed to fetch movies: %v", err)
+				}
+			}
+		})
+	}
+}